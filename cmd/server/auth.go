@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/rbac"
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+)
+
+// Scopes that the routes of the api require
+const (
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeAuditRead     = "audit:read"
+	ScopeAPIKeysAdmin  = "apikeys:admin"
+	ScopeMetricsRead   = "metrics:read"
+)
+
+// knownScopes are the scopes an api key can be granted
+var knownScopes = []string{ScopeProductsRead, ScopeProductsWrite, ScopeAuditRead, ScopeAPIKeysAdmin, ScopeMetricsRead}
+
+// defaultTokenScopes are granted to TOKEN_ENV when TOKEN_SCOPES is not set, it can do everything
+var defaultTokenScopes = knownScopes
+
+// LoadTokenScopes reads from TOKEN_SCOPES the space separated scopes granted to TOKEN_ENV
+func LoadTokenScopes() []string {
+	if scopes := strings.Fields(os.Getenv("TOKEN_SCOPES")); len(scopes) > 0 {
+		return scopes
+	}
+	return defaultTokenScopes
+}
+
+// LoadVerifier reads the keys of the bearer JWTs from the env. It returns nil
+// when none is set, then only TOKEN_ENV authenticates.
+//
+// JWT_HS256_SECRET is the HS256 secret, JWT_PUBLIC_KEYS the comma separated
+// PEM files and JWT_JWKS_FILE a JWKS file with the RS256 and ES256 keys.
+// JWT_ISSUER and JWT_AUDIENCE are required with any key.
+func LoadVerifier() (*auth.Verifier, error) {
+	secret := os.Getenv("JWT_HS256_SECRET")
+
+	keys := auth.NewKeySet()
+	for _, path := range strings.Split(os.Getenv("JWT_PUBLIC_KEYS"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if err := keys.LoadPEM(path); err != nil {
+			return nil, fmt.Errorf("invalid JWT_PUBLIC_KEYS: %w", err)
+		}
+	}
+
+	if path := os.Getenv("JWT_JWKS_FILE"); path != "" {
+		if err := keys.LoadJWKS(path); err != nil {
+			return nil, fmt.Errorf("invalid JWT_JWKS_FILE: %w", err)
+		}
+	}
+
+	if secret == "" && keys.Len() == 0 {
+		return nil, nil
+	}
+
+	leeway, err := time.ParseDuration(getEnv("JWT_LEEWAY", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_LEEWAY: %w", err)
+	}
+
+	config := auth.Config{
+		Secret:   []byte(secret),
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+		Leeway:   leeway,
+	}
+	if keys.Len() > 0 {
+		config.Keys = keys
+	}
+
+	return auth.NewVerifier(config)
+}
+
+// defaultRBACConfig is a function that returns the roles used when RBAC_CONFIG
+// is not set: TOKEN_ENV is an admin, the other authenticated callers are
+// editors and the anonymous ones viewers
+func defaultRBACConfig() rbac.Config {
+	config := rbac.DefaultConfig()
+	config.Subjects[middleware.TokenSubject] = []string{rbac.RoleAdmin}
+	config.Authenticated = []string{rbac.RoleEditor}
+	config.Anonymous = []string{rbac.RoleViewer}
+	return config
+}
+
+// LoadAuthorizer reads from RBAC_CONFIG the JSON file with the roles and who
+// has them, which the service of the products enforces
+func LoadAuthorizer() (products.Authorizer, error) {
+	config := defaultRBACConfig()
+
+	if path := os.Getenv("RBAC_CONFIG"); path != "" {
+		loaded, err := rbac.LoadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RBAC_CONFIG: %w", err)
+		}
+		config = loaded
+	}
+
+	authorizer, err := rbac.NewPolicy(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RBAC_CONFIG: %w", err)
+	}
+	return authorizer, nil
+}
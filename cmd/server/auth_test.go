@@ -0,0 +1,477 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/apikeys"
+	"github.com/burgosfacundo/ApiGo.git/internal/audit"
+	"github.com/burgosfacundo/ApiGo.git/internal/rbac"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/burgosfacundo/ApiGo.git/pkg/ratelimit"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Settings of the tokens of the tests
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "products-api"
+	testSecret   = "0123456789abcdef0123456789abcdef"
+)
+
+// testKeys is a struct that contains the keys that sign the tokens of the tests
+type testKeys struct {
+	rsa   *rsa.PrivateKey
+	ecdsa *ecdsa.PrivateKey
+
+	// rsaPEM is the path of the public RSA key
+	rsaPEM string
+}
+
+// newTestKeys is a function that generates the keys and configures the env of
+// LoadVerifier: the HS256 secret, the RSA key as a PEM file and the ECDSA key in a JWKS file
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa key: %v", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa key: %v", err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal rsa key: %v", err)
+	}
+	rsaPEM := filepath.Join(dir, "rsa-1.pem")
+	if err := os.WriteFile(rsaPEM, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write pem: %v", err)
+	}
+
+	coordinate := func(value []byte) string {
+		padded := make([]byte, 32)
+		copy(padded[32-len(value):], value)
+		return base64.RawURLEncoding.EncodeToString(padded)
+	}
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "EC",
+		"kid": "ec-1",
+		"use": "sig",
+		"crv": "P-256",
+		"x":   coordinate(ecdsaKey.X.Bytes()),
+		"y":   coordinate(ecdsaKey.Y.Bytes()),
+	}}})
+	if err != nil {
+		t.Fatalf("marshal jwks: %v", err)
+	}
+	jwksPath := filepath.Join(dir, "jwks.json")
+	if err := os.WriteFile(jwksPath, jwks, 0o600); err != nil {
+		t.Fatalf("write jwks: %v", err)
+	}
+
+	t.Setenv("JWT_HS256_SECRET", testSecret)
+	t.Setenv("JWT_PUBLIC_KEYS", rsaPEM)
+	t.Setenv("JWT_JWKS_FILE", jwksPath)
+	t.Setenv("JWT_ISSUER", testIssuer)
+	t.Setenv("JWT_AUDIENCE", testAudience)
+
+	return testKeys{rsa: rsaKey, ecdsa: ecdsaKey, rsaPEM: rsaPEM}
+}
+
+// testClaims is a function that returns valid claims of the subject with the scopes
+func testClaims(subject, scope string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"sub":   subject,
+		"iss":   testIssuer,
+		"aud":   testAudience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"scope": scope,
+	}
+}
+
+// sign is a function that returns the token with the claims signed by the method and key
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed
+}
+
+// bearer is a function that returns the status and the body of a request with the bearer token
+func bearer(engine http.Handler, method, path, token string) (int, string) {
+	return bearerWithBody(engine, method, path, "{}", token)
+}
+
+// bearerWithBody is a function that returns the status and the body of a
+// request with the body and the bearer token
+func bearerWithBody(engine http.Handler, method, path, body, token string) (int, string) {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestLoadVerifier(t *testing.T) {
+	// Without keys only TOKEN_ENV authenticates
+	if verifier, err := LoadVerifier(); verifier != nil || err != nil {
+		t.Fatalf("no keys: got %v, %v", verifier, err)
+	}
+
+	t.Setenv("JWT_HS256_SECRET", testSecret)
+	if _, err := LoadVerifier(); err == nil {
+		t.Fatal("no issuer nor audience: got no error")
+	}
+
+	t.Setenv("JWT_ISSUER", testIssuer)
+	t.Setenv("JWT_AUDIENCE", testAudience)
+	t.Setenv("JWT_HS256_SECRET", "short")
+	if _, err := LoadVerifier(); err == nil {
+		t.Fatal("short secret: got no error")
+	}
+}
+
+func TestBearerAuthentication(t *testing.T) {
+	keys := newTestKeys(t)
+
+	verifier, err := LoadVerifier()
+	if err != nil || verifier == nil {
+		t.Fatalf("load verifier: %v", err)
+	}
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional, Verifier: verifier})
+
+	rsaPublic, err := os.ReadFile(keys.rsaPEM)
+	if err != nil {
+		t.Fatalf("read pem: %v", err)
+	}
+
+	expired := testClaims("alice", ScopeProductsWrite)
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	noExpiration := testClaims("alice", ScopeProductsWrite)
+	delete(noExpiration, "exp")
+	otherIssuer := testClaims("alice", ScopeProductsWrite)
+	otherIssuer["iss"] = "https://other.example.com"
+	otherAudience := testClaims("alice", ScopeProductsWrite)
+	otherAudience["aud"] = "other-api"
+	scpArray := testClaims("alice", "")
+	scpArray["scp"] = []string{ScopeProductsRead, ScopeProductsWrite}
+
+	cases := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "HS256", token: sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), testClaims("alice", ScopeProductsWrite)), want: http.StatusBadRequest},
+		{name: "RS256 by file name", token: sign(t, jwt.SigningMethodRS256, "rsa-1", keys.rsa, testClaims("alice", ScopeProductsWrite)), want: http.StatusBadRequest},
+		{name: "RS256 without kid", token: sign(t, jwt.SigningMethodRS256, "", keys.rsa, testClaims("alice", ScopeProductsWrite)), want: http.StatusBadRequest},
+		{name: "ES256 from JWKS", token: sign(t, jwt.SigningMethodES256, "ec-1", keys.ecdsa, testClaims("alice", ScopeProductsWrite)), want: http.StatusBadRequest},
+		{name: "scp array", token: sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), scpArray), want: http.StatusBadRequest},
+		{name: "missing scope", token: sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), testClaims("alice", ScopeProductsRead)), want: http.StatusForbidden},
+		{name: "expired", token: sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), expired), want: http.StatusUnauthorized},
+		{name: "no expiration", token: sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), noExpiration), want: http.StatusUnauthorized},
+		{name: "other issuer", token: sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), otherIssuer), want: http.StatusUnauthorized},
+		{name: "other audience", token: sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), otherAudience), want: http.StatusUnauthorized},
+		{name: "unknown kid", token: sign(t, jwt.SigningMethodES256, "ec-2", keys.ecdsa, testClaims("alice", ScopeProductsWrite)), want: http.StatusUnauthorized},
+		{name: "wrong secret", token: sign(t, jwt.SigningMethodHS256, "", []byte(strings.Repeat("x", 32)), testClaims("alice", ScopeProductsWrite)), want: http.StatusUnauthorized},
+		{name: "public key as secret", token: sign(t, jwt.SigningMethodHS256, "rsa-1", rsaPublic, testClaims("alice", ScopeProductsWrite)), want: http.StatusUnauthorized},
+		{name: "none", token: sign(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType, testClaims("alice", ScopeProductsWrite)), want: http.StatusUnauthorized},
+		{name: "malformed", token: "not-a-jwt", want: http.StatusUnauthorized},
+	}
+
+	for _, c := range cases {
+		// The body is empty, so a valid token gets to the validation of the product
+		if status, body := bearer(engine, http.MethodPut, "/api/v1/product/1", c.token); status != c.want {
+			t.Errorf("%s: got %d %s, want %d", c.name, status, body, c.want)
+		}
+	}
+}
+
+func TestBearerSubjectIsAudited(t *testing.T) {
+	newTestKeys(t)
+
+	verifier, err := LoadVerifier()
+	if err != nil {
+		t.Fatalf("load verifier: %v", err)
+	}
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional, Verifier: verifier})
+
+	// Only an admin deletes
+	claims := testClaims("alice", ScopeProductsWrite+" "+ScopeAuditRead)
+	claims["roles"] = []string{rbac.RoleAdmin}
+	token := sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims)
+	if status, body := bearer(engine, http.MethodDelete, "/api/v1/product/1", token); status != http.StatusOK {
+		t.Fatalf("delete: got %d %s", status, body)
+	}
+
+	// The audit log has the subject of the token as the actor
+	status, body := bearer(engine, http.MethodGet, "/api/v1/audit?actor=jwt:alice", token)
+	if status != http.StatusOK {
+		t.Fatalf("audit: got %d %s", status, body)
+	}
+
+	var response struct {
+		Data []audit.Entry `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil || len(response.Data) != 1 || response.Data[0].ProductID != "1" {
+		t.Fatalf("audit: got %s, %v", body, err)
+	}
+}
+
+// apiKeyRequest is a function that returns the status and the body of a request
+// with the body, authenticated by TOKEN_ENV or by the api key
+func apiKeyRequest(engine http.Handler, method, path, body, token, key string) (int, string) {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("token", token)
+	}
+	if key != "" {
+		request.Header.Set("X-API-Key", key)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder.Code, recorder.Body.String()
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	// The admin creates a key that can only write
+	status, body := apiKeyRequest(engine, http.MethodPost, "/api/v1/apikeys", `{"name":"ci","scopes":["products:write"]}`, testToken, "")
+	if status != http.StatusCreated {
+		t.Fatalf("create: got %d %s", status, body)
+	}
+
+	var created struct {
+		Key    apikeys.Key `json:"key"`
+		Secret string      `json:"secret"`
+	}
+	if err := json.Unmarshal([]byte(body), &created); err != nil || created.Secret == "" || strings.Contains(body, `"hash"`) {
+		t.Fatalf("create: got %s, %v", body, err)
+	}
+
+	if status, body := apiKeyRequest(engine, http.MethodPost, "/api/v1/apikeys", `{"name":"ci","scopes":["products:write"]}`, testToken, ""); status != http.StatusConflict {
+		t.Fatalf("create with a name in use: got %d %s", status, body)
+	}
+
+	// The empty body gets to the validation of the product with the key, the
+	// key can't manage the keys and a wrong key is rejected
+	if status, body := apiKeyRequest(engine, http.MethodPut, "/api/v1/product/1", "{}", "", created.Secret); status != http.StatusBadRequest {
+		t.Fatalf("write with the key: got %d %s", status, body)
+	}
+	if status, body := apiKeyRequest(engine, http.MethodGet, "/api/v1/apikeys", "", "", created.Secret); status != http.StatusForbidden {
+		t.Fatalf("list with the key: got %d %s", status, body)
+	}
+	if status, body := apiKeyRequest(engine, http.MethodPut, "/api/v1/product/1", "{}", "", created.Secret+"x"); status != http.StatusUnauthorized {
+		t.Fatalf("write with a wrong key: got %d %s", status, body)
+	}
+
+	// Both secrets work during the overlap of the rotation
+	path := "/api/v1/apikeys/" + created.Key.ID
+	status, body = apiKeyRequest(engine, http.MethodPost, path+"/rotate", `{"overlap":"1h"}`, testToken, "")
+	if status != http.StatusOK {
+		t.Fatalf("rotate: got %d %s", status, body)
+	}
+
+	var rotated struct {
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal([]byte(body), &rotated); err != nil || rotated.Secret == created.Secret {
+		t.Fatalf("rotate: got %s, %v", body, err)
+	}
+	for _, secret := range []string{created.Secret, rotated.Secret} {
+		if status, body := apiKeyRequest(engine, http.MethodPut, "/api/v1/product/1", "{}", "", secret); status != http.StatusBadRequest {
+			t.Fatalf("write during the overlap: got %d %s", status, body)
+		}
+	}
+
+	if status, body := apiKeyRequest(engine, http.MethodPost, path+"/rotate", `{"overlap":"soon"}`, testToken, ""); status != http.StatusBadRequest {
+		t.Fatalf("rotate with a wrong overlap: got %d %s", status, body)
+	}
+
+	// A revoked key stops working at once and records when it was last used
+	status, body = apiKeyRequest(engine, http.MethodDelete, path, "", testToken, "")
+	if status != http.StatusOK || !strings.Contains(body, `"revoked_at"`) || !strings.Contains(body, `"last_used_at"`) {
+		t.Fatalf("revoke: got %d %s", status, body)
+	}
+	if status, body := apiKeyRequest(engine, http.MethodPut, "/api/v1/product/1", "{}", "", rotated.Secret); status != http.StatusUnauthorized {
+		t.Fatalf("write with a revoked key: got %d %s", status, body)
+	}
+
+	if status, body := apiKeyRequest(engine, http.MethodGet, "/api/v1/apikeys/missing", "", testToken, ""); status != http.StatusNotFound {
+		t.Fatalf("get missing: got %d %s", status, body)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	newTestKeys(t)
+
+	verifier, err := LoadVerifier()
+	if err != nil {
+		t.Fatalf("load verifier: %v", err)
+	}
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional, Verifier: verifier})
+
+	// tokenOf is a function that returns a token that can write with the roles
+	tokenOf := func(subject string, roles ...string) string {
+		claims := testClaims(subject, ScopeProductsWrite)
+		if len(roles) > 0 {
+			claims["roles"] = roles
+		}
+		return sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), claims)
+	}
+	product := func(code string, published bool) string {
+		return fmt.Sprintf(`{"name":"Agua","quantity":1,"code_value":%q,"is_published":%t,"expiration":"2099-01-01T00:00:00Z","price":1}`, code, published)
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		want   int
+	}{
+		{name: "editor by default creates", method: http.MethodPost, path: "/api/v1/product", body: product("abcd1", false), token: tokenOf("alice"), want: http.StatusCreated},
+		{name: "editor can't publish", method: http.MethodPost, path: "/api/v1/product", body: product("abcd2", true), token: tokenOf("alice"), want: http.StatusForbidden},
+		{name: "viewer can't create", method: http.MethodPost, path: "/api/v1/product", body: product("abcd3", false), token: tokenOf("bob", rbac.RoleViewer), want: http.StatusForbidden},
+		{name: "publisher publishes", method: http.MethodPost, path: "/api/v1/product", body: product("abcd4", true), token: tokenOf("carol", rbac.RolePublisher), want: http.StatusCreated},
+		{name: "editor updates a published product", method: http.MethodPut, path: "/api/v1/product/1", body: product("abcd5", true), token: tokenOf("alice"), want: http.StatusOK},
+		{name: "editor can't delete", method: http.MethodDelete, path: "/api/v1/product/2", body: "", token: tokenOf("alice", rbac.RolePublisher), want: http.StatusForbidden},
+		{name: "admin deletes", method: http.MethodDelete, path: "/api/v1/product/2", body: "", token: tokenOf("dave", rbac.RoleAdmin), want: http.StatusOK},
+		{name: "unknown role grants nothing", method: http.MethodPut, path: "/api/v1/product/1", body: product("abcd6", true), token: tokenOf("erin", "owner"), want: http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		status, body := bearerWithBody(engine, c.method, c.path, c.body, c.token)
+		if status != c.want {
+			t.Errorf("%s: got %d %s, want %d", c.name, status, body, c.want)
+		}
+		if c.want == http.StatusForbidden && !strings.Contains(body, `"code":"forbidden"`) {
+			t.Errorf("%s: got %s, want the forbidden problem", c.name, body)
+		}
+	}
+}
+
+func TestJWTSubjectsAreNamespaced(t *testing.T) {
+	newTestKeys(t)
+
+	// TOKEN_ENV, an api key and a JWT subject are admins
+	path := filepath.Join(t.TempDir(), "rbac.json")
+	config := defaultRBACConfig()
+	config.Subjects["apikey:k1"] = []string{rbac.RoleAdmin}
+	config.Subjects["jwt:alice"] = []string{rbac.RoleAdmin}
+	content, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("RBAC_CONFIG", path)
+
+	verifier, err := LoadVerifier()
+	if err != nil {
+		t.Fatalf("load verifier: %v", err)
+	}
+	engine := newTestRouter(t, RouterConfig{
+		ReadAccess: middleware.AccessOptional,
+		Verifier:   verifier,
+		RateLimits: RateLimits{Product: ratelimit.Limit{Requests: 1, Period: time.Minute}},
+	})
+
+	tokenOf := func(subject string) string {
+		return sign(t, jwt.SigningMethodHS256, "", []byte(testSecret), testClaims(subject, ScopeProductsWrite))
+	}
+
+	// A JWT whose sub is the one of TOKEN_ENV or of an api key is only an editor
+	for _, subject := range []string{middleware.TokenSubject, "apikey:k1"} {
+		if status, body := bearer(engine, http.MethodDelete, "/api/v1/product/2", tokenOf(subject)); status != http.StatusForbidden {
+			t.Errorf("JWT with sub %s: got %d %s, want 403", subject, status, body)
+		}
+	}
+
+	// The bucket of TOKEN_ENV was not taken by the JWT that claimed its subject
+	if status, body := apiKeyRequest(engine, http.MethodDelete, "/api/v1/product/2", "", testToken, ""); status != http.StatusOK {
+		t.Errorf("TOKEN_ENV: got %d %s", status, body)
+	}
+
+	// The subjects of the JWTs are assigned roles with their prefix
+	if status, body := bearer(engine, http.MethodDelete, "/api/v1/product/1", tokenOf("alice")); status != http.StatusOK {
+		t.Errorf("jwt:alice: got %d %s", status, body)
+	}
+}
+
+func TestLoadAuthorizer(t *testing.T) {
+	// The example file has the roles used without RBAC_CONFIG
+	example, err := rbac.LoadConfig(filepath.Join("..", "..", "rbac.example.json"))
+	if err != nil || !reflect.DeepEqual(example, defaultRBACConfig()) {
+		t.Fatalf("example config: got %+v, %v, want %+v", example, err, defaultRBACConfig())
+	}
+
+	path := filepath.Join(t.TempDir(), "rbac.json")
+
+	for name, content := range map[string]string{
+		"unknown permission": `{"roles":{"viewer":["products:fly"]}}`,
+		"unknown role":       `{"roles":{"viewer":["products:read"]},"anonymous":["admin"]}`,
+		"unknown field":      `{"roles":{"viewer":["products:read"]},"anonymus":["viewer"]}`,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		t.Setenv("RBAC_CONFIG", path)
+		if _, err := LoadAuthorizer(); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+
+	// Without roles for the anonymous callers the reads are denied
+	if err := os.WriteFile(path, []byte(`{"roles":{"viewer":["products:read"]},"subjects":{"token":["viewer"]}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	if status, body := apiKeyRequest(engine, http.MethodGet, "/api/v1/product/1", "", "", ""); status != http.StatusForbidden {
+		t.Errorf("anonymous read: got %d %s", status, body)
+	}
+	if status, body := apiKeyRequest(engine, http.MethodGet, "/api/v1/product/1", "", testToken, ""); status != http.StatusOK {
+		t.Errorf("viewer read: got %d %s", status, body)
+	}
+	if status, body := apiKeyRequest(engine, http.MethodDelete, "/api/v1/product/1", "", testToken, ""); status != http.StatusForbidden {
+		t.Errorf("viewer delete: got %d %s", status, body)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+// defaultCacheControl makes caches revalidate every time, which is cheap
+// because the product reads answer 304 when nothing changed
+const defaultCacheControl = "no-cache"
+
+// CachePolicies is a struct that contains the Cache-Control policy of each
+// cacheable route
+type CachePolicies struct {
+	// Product is used by GET /product/:id and GET /product/code/:code
+	Product string
+
+	// ProductList is used by GET /product
+	ProductList string
+}
+
+// LoadCachePolicies reads the Cache-Control policies from the env
+func LoadCachePolicies() CachePolicies {
+	return CachePolicies{
+		Product:     getEnv("CACHE_CONTROL_PRODUCT", defaultCacheControl),
+		ProductList: getEnv("CACHE_CONTROL_PRODUCT_LIST", defaultCacheControl),
+	}
+}
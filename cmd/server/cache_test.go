@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+)
+
+// conditionalRequest is a function that returns the response of a request of
+// TOKEN_ENV with the body and the headers
+func conditionalRequest(engine http.Handler, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("token", testToken)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestProductNotModified(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	for _, path := range []string{"/api/v1/product/1", "/api/v1/product/code/123456789"} {
+		response := conditionalRequest(engine, http.MethodGet, path, "", nil)
+		etag, lastModified := response.Header().Get("ETag"), response.Header().Get("Last-Modified")
+		if response.Code != http.StatusOK || etag == "" || lastModified == "" || response.Header().Get("Cache-Control") != defaultCacheControl {
+			t.Fatalf("%s: got %d with headers %v", path, response.Code, response.Header())
+		}
+
+		// The copy of the client is still fresh by tag or by date
+		for _, headers := range []map[string]string{{"If-None-Match": etag}, {"If-Modified-Since": lastModified}} {
+			if response := conditionalRequest(engine, http.MethodGet, path, "", headers); response.Code != http.StatusNotModified ||
+				response.Header().Get("ETag") != etag || response.Body.Len() != 0 {
+				t.Fatalf("%s with %v: got %d with headers %v", path, headers, response.Code, response.Header())
+			}
+		}
+	}
+
+	// A new product with the code of a deleted one is in the same version,
+	// but the copy of the deleted one is not fresh
+	etag := conditionalRequest(engine, http.MethodGet, "/api/v1/product/code/123456789", "", nil).Header().Get("ETag")
+	if response := conditionalRequest(engine, http.MethodDelete, "/api/v1/product/1", "", nil); response.Code != http.StatusOK {
+		t.Fatalf("delete: got %d %s", response.Code, response.Body)
+	}
+	body := `{"name":"Coco Cola Zero","quantity":1,"code_value":"123456789","expiration":"` + time.Now().Add(24*time.Hour).Format(time.RFC3339) + `","price":12}`
+	if response := conditionalRequest(engine, http.MethodPost, "/api/v1/product", body, nil); response.Code != http.StatusCreated {
+		t.Fatalf("create: got %d %s", response.Code, response.Body)
+	}
+
+	response := conditionalRequest(engine, http.MethodGet, "/api/v1/product/code/123456789", "", map[string]string{"If-None-Match": etag})
+	if response.Code != http.StatusOK || response.Header().Get("ETag") == etag || !strings.Contains(response.Body.String(), "Coco Cola Zero") {
+		t.Fatalf("new product: got %d with ETag %s, the deleted one had %s", response.Code, response.Header().Get("ETag"), etag)
+	}
+}
+
+func TestProductListNotModified(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	// The pages have no Last-Modified, only the hash of the page says if it changed
+	response := conditionalRequest(engine, http.MethodGet, "/api/v1/product?limit=2", "", nil)
+	etag := response.Header().Get("ETag")
+	if response.Code != http.StatusOK || etag == "" || response.Header().Get("Last-Modified") != "" {
+		t.Fatalf("list: got %d with headers %v", response.Code, response.Header())
+	}
+
+	if response := conditionalRequest(engine, http.MethodGet, "/api/v1/product?limit=2", "", map[string]string{"If-None-Match": etag}); response.Code != http.StatusNotModified {
+		t.Fatalf("same page: got %d", response.Code)
+	}
+
+	// Deleting a product of the page changes it, even if the rest wasn't updated since
+	since := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if response := conditionalRequest(engine, http.MethodDelete, "/api/v1/product/2", "", nil); response.Code != http.StatusOK {
+		t.Fatalf("delete: got %d %s", response.Code, response.Body)
+	}
+	for _, headers := range []map[string]string{{"If-None-Match": etag}, {"If-Modified-Since": since}} {
+		if response := conditionalRequest(engine, http.MethodGet, "/api/v1/product?limit=2", "", headers); response.Code != http.StatusOK {
+			t.Fatalf("page after the delete with %v: got %d", headers, response.Code)
+		}
+	}
+}
+
+func TestProductIfMatch(t *testing.T) {
+	writes := []struct {
+		method      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{method: http.MethodPut, contentType: "application/json", body: `{"name":"Agua","quantity":1,"code_value":"123456789","is_published":true,"expiration":"2099-01-01T00:00:00Z","price":1}`, want: http.StatusOK},
+		{method: http.MethodPatch, contentType: products.PatchMerge, body: `{"name":"Agua"}`, want: http.StatusOK},
+		{method: http.MethodDelete, want: http.StatusOK},
+	}
+
+	// ifMatch is a function that returns the If-Match of the case from the tags of the product 1
+	// before and after another write, and the one of the product 2
+	cases := []struct {
+		name    string
+		ifMatch func(stale, current, other string) string
+		match   bool
+	}{
+		{name: "current", ifMatch: func(stale, current, other string) string { return current }, match: true},
+		{name: "any", ifMatch: func(stale, current, other string) string { return "*" }, match: true},
+		{name: "one of the tags", ifMatch: func(stale, current, other string) string { return stale + ", " + current }, match: true},
+		{name: "stale", ifMatch: func(stale, current, other string) string { return stale }},
+		{name: "of another product", ifMatch: func(stale, current, other string) string { return other }},
+		{name: "weak", ifMatch: func(stale, current, other string) string { return "W/" + current }},
+		{name: "malformed", ifMatch: func(stale, current, other string) string { return strings.Trim(current, `"`) }},
+		{name: "not a version", ifMatch: func(stale, current, other string) string { return `"abc"` }},
+	}
+
+	for _, write := range writes {
+		for _, c := range cases {
+			engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+			stale := etagOf(t, engine, "/api/v1/product/1")
+			if response := conditionalRequest(engine, http.MethodPatch, "/api/v1/product/1", `{"quantity":3}`, map[string]string{"Content-Type": products.PatchMerge}); response.Code != http.StatusOK {
+				t.Fatalf("patch: got %d %s", response.Code, response.Body)
+			}
+			current, other := etagOf(t, engine, "/api/v1/product/1"), etagOf(t, engine, "/api/v1/product/2")
+
+			headers := map[string]string{"If-Match": c.ifMatch(stale, current, other)}
+			if write.contentType != "" {
+				headers["Content-Type"] = write.contentType
+			}
+			response := conditionalRequest(engine, write.method, "/api/v1/product/1", write.body, headers)
+
+			want := write.want
+			if !c.match {
+				want = http.StatusPreconditionFailed
+			}
+			if response.Code != want {
+				t.Errorf("%s %s: got %d %s, want %d", write.method, c.name, response.Code, response.Body, want)
+				continue
+			}
+
+			// A write that didn't match left the product as it was
+			if !c.match {
+				if !strings.Contains(response.Body.String(), `"code":"precondition_failed"`) || etagOf(t, engine, "/api/v1/product/1") != current {
+					t.Errorf("%s %s: got %s and the product changed", write.method, c.name, response.Body)
+				}
+			}
+		}
+	}
+}
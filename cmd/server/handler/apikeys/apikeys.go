@@ -0,0 +1,220 @@
+package apikeys
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/apikeys"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// Controller is a struct that contains the service of the api keys
+type Controller struct {
+	service apikeys.Service
+}
+
+// NewControllerAPIKeys is a function that loads the service into the controller
+func NewControllerAPIKeys(service apikeys.Service) *Controller {
+	return &Controller{service: service}
+}
+
+// SecretResponse is the body returned when a key gets a new secret, it's the
+// only time the secret is shown
+type SecretResponse struct {
+	Key    apikeys.Key `json:"key"`
+	Secret string      `json:"secret"`
+}
+
+// RotateRequest is the body of a rotation. Overlap is a duration like 1h30m,
+// 24h by default and 0 to stop the previous secret at once.
+type RotateRequest struct {
+	Overlap *string `json:"overlap,omitempty"`
+}
+
+// HandlerCreate is a function that calls the service for create an api key
+// @Summary Create an api key
+// @Description Create a named api key with its scopes and an optional expiration.
+// @Description The secret is only returned here, it's sent in the X-API-Key header.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param key body apikeys.CreateRequest true "Api key"
+// @Success 201 {object} SecretResponse
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope"
+// @Failure 409 {object} problem.Problem "Conflict"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Router /apikeys [post]
+func (c *Controller) HandlerCreate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the settings of the key
+		var request apikeys.CreateRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			problem.Abort(ctx, fmt.Errorf("%w: %v", apikeys.ErrInvalidRequest, err))
+			return
+		}
+
+		// We call the service to create the key
+		key, secret, err := c.service.Create(ctx, request)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the key and its secret
+		ctx.JSON(http.StatusCreated, SecretResponse{Key: key, Secret: secret})
+	}
+}
+
+// HandlerList is a function that calls the service for get every api key
+// @Summary Get the api keys
+// @Description Return every api key, the revoked ones included, without their secrets
+// @Tags API Keys
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param token header string true "TOKEN_ENV"
+// @Success 200 {array} apikeys.Key
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Router /apikeys [get]
+func (c *Controller) HandlerList() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We call the service to get the keys
+		list, err := c.service.List(ctx)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the keys
+		ctx.JSON(http.StatusOK, list)
+	}
+}
+
+// HandlerGet is a function that calls the service for get an api key by id
+// @Summary Get an api key
+// @Description Return an api key by id without its secrets
+// @Tags API Keys
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param id path string true "id"
+// @Success 200 {object} apikeys.Key
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope"
+// @Failure 404 {object} problem.Problem "Not Found"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Router /apikeys/{id} [get]
+func (c *Controller) HandlerGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We call the service to get the key
+		key, err := c.service.Get(ctx, ctx.Param("id"))
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the key
+		ctx.JSON(http.StatusOK, key)
+	}
+}
+
+// HandlerRotate is a function that calls the service for give an api key a new secret
+// @Summary Rotate an api key
+// @Description Give an api key a new secret. The previous one keeps working for the overlap, 24h by default and 30 days at most,
+// @Description so the clients can switch to the new one without downtime. The body is optional.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param id path string true "id"
+// @Param rotation body RotateRequest false "Overlap of the previous secret"
+// @Success 200 {object} SecretResponse
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope"
+// @Failure 404 {object} problem.Problem "Not Found"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Router /apikeys/{id}/rotate [post]
+func (c *Controller) HandlerRotate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the overlap, the body may be empty
+		var request RotateRequest
+		if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+			problem.Abort(ctx, fmt.Errorf("%w: %v", apikeys.ErrInvalidRequest, err))
+			return
+		}
+
+		overlap := apikeys.DefaultOverlap
+		if request.Overlap != nil {
+			parsed, err := time.ParseDuration(*request.Overlap)
+			if err != nil {
+				problem.Abort(ctx, fmt.Errorf("%w: overlap must be a duration like 24h", apikeys.ErrInvalidRequest))
+				return
+			}
+			overlap = parsed
+		}
+
+		// We call the service to rotate the key
+		key, secret, err := c.service.Rotate(ctx, ctx.Param("id"), overlap)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the key and its new secret
+		ctx.JSON(http.StatusOK, SecretResponse{Key: key, Secret: secret})
+	}
+}
+
+// HandlerRevoke is a function that calls the service for revoke an api key
+// @Summary Revoke an api key
+// @Description Make every secret of an api key stop working at once. The key is kept, revoked, to know whose requests it made.
+// @Tags API Keys
+// @Produce json
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param id path string true "id"
+// @Success 200 {object} apikeys.Key
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope"
+// @Failure 404 {object} problem.Problem "Not Found"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Router /apikeys/{id} [delete]
+func (c *Controller) HandlerRevoke() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We call the service to revoke the key
+		key, err := c.service.Revoke(ctx, ctx.Param("id"))
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the revoked key
+		ctx.JSON(http.StatusOK, key)
+	}
+}
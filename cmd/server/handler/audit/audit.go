@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/audit"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// Controller is a struct that contains the service of the audit log
+type Controller struct {
+	service audit.Service
+}
+
+// NewControllerAudit is a function that loads the service into the controller
+func NewControllerAudit(service audit.Service) *Controller {
+	return &Controller{service: service}
+}
+
+// ListResponse is the body returned by the audit log query
+type ListResponse struct {
+	Data   []audit.Entry `json:"data"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// parseQuery is a function that reads the filter and page params of the request
+func parseQuery(ctx *gin.Context) (audit.Query, error) {
+	query := audit.Query{
+		Actor:     ctx.Query("actor"),
+		ProductID: ctx.Query("product_id"),
+	}
+
+	for name, target := range map[string]*int{
+		"limit":  &query.Limit,
+		"offset": &query.Offset,
+	} {
+		if value := ctx.Query(name); value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return audit.Query{}, fmt.Errorf("%w: %s must be an integer", products.ErrInvalidQuery, name)
+			}
+			*target = number
+		}
+	}
+
+	for name, target := range map[string]**time.Time{
+		"from": &query.From,
+		"to":   &query.To,
+	} {
+		if value := ctx.Query(name); value != "" {
+			date, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return audit.Query{}, fmt.Errorf("%w: %s must be a RFC 3339 date", products.ErrInvalidQuery, name)
+			}
+			*target = &date
+		}
+	}
+
+	return query.Normalize()
+}
+
+// HandlerQuery is a function that calls the service for get the entries of the audit log
+// @Summary Query the audit log
+// @Description Return who created, updated, deleted, restored or purged which product, when, from which ip and the product before and after it.
+// @Description The entries are sorted from the oldest to the newest, from is inclusive and to is exclusive.
+// @Tags Audit
+// @Produce json
+// @Security BearerAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param actor query string false "subject that made the write"
+// @Param product_id query string false "id of the product"
+// @Param from query string false "RFC 3339 date of the first entry"
+// @Param to query string false "RFC 3339 date the entries are before"
+// @Param limit query int false "entries per page, 100 by default and 1000 at most"
+// @Param offset query int false "entries to skip"
+// @Success 200 {object} ListResponse
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Router /audit [get]
+func (c *Controller) HandlerQuery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the query
+		query, err := parseQuery(ctx)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We call the service to get the entries
+		list, err := c.service.Query(ctx, query)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the entries
+		ctx.JSON(http.StatusOK, ListResponse{Data: list, Limit: query.Limit, Offset: query.Offset})
+	}
+}
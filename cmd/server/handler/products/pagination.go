@@ -0,0 +1,147 @@
+package products
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/gin-gonic/gin"
+)
+
+// Pagination is a struct that contains the metadata of a page of products
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListResponse is the body returned by the products listing
+type ListResponse struct {
+	Data       []domain.Product `json:"data"`
+	Pagination Pagination       `json:"pagination"`
+}
+
+// parseQuery is a function that reads the filter, sort and page params of the request
+func parseQuery(ctx *gin.Context) (products.Query, error) {
+	var query products.Query
+	var err error
+
+	if query.Limit, err = intParam(ctx, "limit"); err != nil {
+		return products.Query{}, err
+	}
+	if query.Offset, err = intParam(ctx, "offset"); err != nil {
+		return products.Query{}, err
+	}
+	query.Cursor = ctx.Query("cursor")
+
+	if query.Sort, err = products.ParseSort(ctx.Query("sort")); err != nil {
+		return products.Query{}, err
+	}
+
+	filter := &query.Filter
+	filter.Name = ctx.Query("name")
+
+	if value := ctx.Query("is_published"); value != "" {
+		isPublished, err := strconv.ParseBool(value)
+		if err != nil {
+			return products.Query{}, fmt.Errorf("%w: is_published must be true or false", products.ErrInvalidQuery)
+		}
+		filter.IsPublished = &isPublished
+	}
+
+	for name, target := range map[string]**float64{
+		"price_min": &filter.MinPrice,
+		"price_max": &filter.MaxPrice,
+	} {
+		if value := ctx.Query(name); value != "" {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return products.Query{}, fmt.Errorf("%w: %s must be a number", products.ErrInvalidQuery, name)
+			}
+			*target = &number
+		}
+	}
+
+	for name, target := range map[string]**int{
+		"quantity_min": &filter.MinQuantity,
+		"quantity_max": &filter.MaxQuantity,
+	} {
+		if value := ctx.Query(name); value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return products.Query{}, fmt.Errorf("%w: %s must be an integer", products.ErrInvalidQuery, name)
+			}
+			*target = &number
+		}
+	}
+
+	for name, target := range map[string]**time.Time{
+		"expiration_before": &filter.ExpiresBefore,
+		"expiration_after":  &filter.ExpiresAfter,
+	} {
+		if value := ctx.Query(name); value != "" {
+			date, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return products.Query{}, fmt.Errorf("%w: %s must be a RFC 3339 date", products.ErrInvalidQuery, name)
+			}
+			*target = &date
+		}
+	}
+
+	return query.Normalize()
+}
+
+// intParam is a function that reads an optional integer param, 0 if it's missing
+func intParam(ctx *gin.Context, name string) (int, error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be an integer", products.ErrInvalidQuery, name)
+	}
+
+	return number, nil
+}
+
+// setLinkHeader is a function that sets the RFC 8288 Link header with the
+// first, previous and next pages of the listing
+func setLinkHeader(ctx *gin.Context, query products.Query, page products.Page) {
+	var links []string
+
+	link := func(rel string, change func(values url.Values)) {
+		values := ctx.Request.URL.Query()
+		values.Set("limit", strconv.Itoa(query.Limit))
+		values.Del("offset")
+		values.Del("cursor")
+		change(values)
+
+		target := url.URL{Path: ctx.Request.URL.Path, RawQuery: values.Encode()}
+		links = append(links, fmt.Sprintf("<%s>; rel=%q", target.String(), rel))
+	}
+
+	link("first", func(url.Values) {})
+
+	if query.Cursor == "" && query.Offset > 0 {
+		link("prev", func(values url.Values) {
+			if previous := query.Offset - query.Limit; previous > 0 {
+				values.Set("offset", strconv.Itoa(previous))
+			}
+		})
+	}
+
+	if page.NextCursor != "" {
+		link("next", func(values url.Values) {
+			values.Set("cursor", page.NextCursor)
+		})
+	}
+
+	ctx.Header("Link", strings.Join(links, ", "))
+}
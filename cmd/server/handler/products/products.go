@@ -1,13 +1,25 @@
 package products
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/burgosfacundo/ApiGo.git/internal/domain"
 	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/conditional"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
 	"github.com/gin-gonic/gin"
 )
 
+// Errors that can be returned when reading the body of a request
+var (
+	ErrMalformedBody        = errors.New("malformed request body")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
 // Controller is a struct that contains the service of Product objects
 type Controller struct {
 	service products.Service
@@ -18,29 +30,72 @@ func NewControllerProducts(service products.Service) *Controller {
 	return &Controller{service: service}
 }
 
+// bindProduct is a function that decodes the json body into a product. A
+// value with the wrong type is reported as a domain.ValidationError of its field.
+func bindProduct(ctx *gin.Context) (domain.Product, error) {
+	var product domain.Product
+
+	err := ctx.ShouldBindJSON(&product)
+
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	switch {
+	case err == nil:
+		return product, nil
+	case errors.As(err, &typeErr):
+		return domain.Product{}, &domain.ValidationError{Errors: []domain.FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s can't be a json %s", typeErr.Field, typeErr.Value),
+		}}}
+	case errors.As(err, &timeErr):
+		return domain.Product{}, &domain.ValidationError{Errors: []domain.FieldError{{
+			Field:   "expiration",
+			Rule:    "type",
+			Message: "expiration must be a RFC 3339 date",
+		}}}
+	default:
+		return domain.Product{}, fmt.Errorf("%w: %v", ErrMalformedBody, err)
+	}
+}
+
+// expectedVersion is a function that returns the version of the product the
+// If-Match header of the request expects, 0 for any version
+func (c *Controller) expectedVersion(ctx *gin.Context, id string) (int64, error) {
+	return conditional.ExpectedVersion(ctx, id, func() (int64, error) {
+		product, err := c.service.GetByID(ctx, id)
+		return product.Version, err
+	})
+}
+
 // HandlerCreate is a function that calls the service for create a Product in the db
 // @Summary Post new product
-// @Description Create a new product in the db
+// @Description Create a new product in the db, the id is generated by the server and the one sent is ignored
+// @Description A published product can only be created by a publisher.
 // @Tags Products
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param token header string true "TOKEN_ENV"
 // @Param product body domain.Product true "Product"
 // @Success 201 {object} domain.Product
-// @Failure 400 "Bad Request"
-// @Failure 500 "Internal Server Error"
+// @Header 201 {string} ETag "version of the product"
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 409 {object} problem.Problem "Conflict"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
 // @Router /product [post]
 func (c *Controller) HandlerCreate() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 
-		var productRequest domain.Product
-
 		// We receive the product
-		err := ctx.Bind(&productRequest)
+		productRequest, err := bindProduct(ctx)
 
 		// If we have an error return it
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, "bad request")
+			problem.Abort(ctx, err)
 			return
 		}
 
@@ -49,37 +104,90 @@ func (c *Controller) HandlerCreate() gin.HandlerFunc {
 
 		// If we have an error return it
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, "Internal server error")
+			problem.Abort(ctx, err)
 			return
 		}
 
 		// We return the product that was created
+		conditional.SetValidators(ctx, product.Id, product.Version, product.UpdatedAt)
 		ctx.JSON(http.StatusCreated, product)
 
 	}
 }
 
-// HandlerGetAll is a function that calls the service for get all the products in the db
+// HandlerGetAll is a function that calls the service for get a page of the products in the db
 // @Summary Get all the products
-// @Description Return list of all the products in the db
+// @Description Return a page of the products in the db that meet the filters, with the first, prev and next pages in the Link header.
+// @Description Pages are requested with limit and offset, or with limit and the next_cursor of the previous page.
 // @Tags Products
 // @Produces json
-// @Success 200 {object} []domain.Product
-// @Failure 500 "Internal Server Error"
+// @Security BearerAuth
+// @Param token header string false "TOKEN_ENV, required when AUTH_READS=required"
+// @Param name query string false "case insensitive substring of the name"
+// @Param is_published query bool false "published state"
+// @Param price_min query number false "minimum price, included"
+// @Param price_max query number false "maximum price, included"
+// @Param quantity_min query int false "minimum quantity, included"
+// @Param quantity_max query int false "maximum quantity, included"
+// @Param expiration_before query string false "RFC 3339 date the product expires before"
+// @Param expiration_after query string false "RFC 3339 date the product expires after"
+// @Param sort query string false "comma separated fields, - for descending order, like -price,name" default(id)
+// @Param limit query int false "size of the page, from 1 to 100" default(20)
+// @Param offset query int false "number of products to skip, ignored with cursor" default(0)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param If-None-Match header string false "ETag of the cached page"
+// @Success 200 {object} ListResponse
+// @Success 304 "Not Modified"
+// @Header 200,304 {string} ETag "hash of the page"
+// @Failure 400 {object} problem.Problem "Invalid Query"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
 // @Router /product [get]
 func (c *Controller) HandlerGetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		// We call the service to get all the products
-		listProducts, err := c.service.GetAll(ctx)
+		// We receive the page that was asked
+		query, err := parseQuery(ctx)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We call the service to get the page of products
+		page, err := c.service.List(ctx, query)
 
 		// If we have an error return it
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, "Internal server error")
+			problem.Abort(ctx, err)
 			return
 		}
 
-		// We return the list of products
-		ctx.JSON(http.StatusOK, listProducts)
+		// We render the page to know its entity tag
+		setLinkHeader(ctx, query, page)
+		body, err := json.Marshal(ListResponse{
+			Data: page.Items,
+			Pagination: Pagination{
+				Limit:      query.Limit,
+				Offset:     query.Offset,
+				Total:      page.Total,
+				NextCursor: page.NextCursor,
+			},
+		})
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We answer 304 if the client already has this page. The page has no
+		// Last-Modified, a product deleted from it doesn't change the last
+		// update of the rest, so only its hash tells if it changed.
+		if conditional.NotModified(ctx, conditional.HashETag(body), time.Time{}) {
+			return
+		}
+
+		// We return the page of products
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", body)
 	}
 }
 
@@ -88,9 +196,19 @@ func (c *Controller) HandlerGetAll() gin.HandlerFunc {
 // @Description Return a product in the db
 // @Tags Products
 // @Produce json
+// @Security BearerAuth
+// @Param token header string false "TOKEN_ENV, required when AUTH_READS=required"
 // @Param id path string true "id"
+// @Param If-None-Match header string false "ETag of the cached version"
+// @Param If-Modified-Since header string false "Last-Modified of the cached version"
 // @Success 200 {object} domain.Product
-// @Failure 404 "Product Not Found"
+// @Success 304 "Not Modified"
+// @Header 200,304 {string} ETag "version of the product"
+// @Header 200,304 {string} Last-Modified "last update of the product"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Product Not Found"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
 // @Router /product/{id} [get]
 func (c *Controller) HandlerGetByID() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -101,7 +219,54 @@ func (c *Controller) HandlerGetByID() gin.HandlerFunc {
 		// We call the service to update the product
 		product, err := c.service.GetByID(ctx, idParam)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusNotFound, "Product not found")
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We answer 304 if the client already has this version
+		if conditional.NotModified(ctx, conditional.ETag(product.Id, product.Version), product.UpdatedAt) {
+			return
+		}
+
+		// We return the product
+		ctx.JSON(http.StatusOK, product)
+	}
+}
+
+// HandlerGetByCode is a function that calls the service for get a product by code value
+// @Summary Get product by code
+// @Description Return the product with the code value in the db
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param token header string false "TOKEN_ENV, required when AUTH_READS=required"
+// @Param code path string true "code"
+// @Param If-None-Match header string false "ETag of the cached version"
+// @Param If-Modified-Since header string false "Last-Modified of the cached version"
+// @Success 200 {object} domain.Product
+// @Success 304 "Not Modified"
+// @Header 200,304 {string} ETag "version of the product"
+// @Header 200,304 {string} Last-Modified "last update of the product"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Product Not Found"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Router /product/code/{code} [get]
+func (c *Controller) HandlerGetByCode() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the code value of the product
+		codeParam := ctx.Param("code")
+
+		// We call the service to get the product
+		product, err := c.service.GetByCode(ctx, codeParam)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We answer 304 if the client already has this version
+		if conditional.NotModified(ctx, conditional.ETag(product.Id, product.Version), product.UpdatedAt) {
 			return
 		}
 
@@ -112,14 +277,24 @@ func (c *Controller) HandlerGetByID() gin.HandlerFunc {
 
 // HandlerUpdate is a function that calls the service for update a product by id
 // @Summary Update product
-// @Description Update a product in the db
+// @Description Update a product in the db. With If-Match the update only happens if the product is still in that version,
+// @Description the version of the body is ignored. Only a publisher can publish the product.
 // @Tags Products
 // @Produce json
+// @Security BearerAuth
+// @Param token header string true "TOKEN_ENV"
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being updated"
 // @Param product body domain.Product true "product"
 // @Success 200 {object} domain.Product
-// @Failure 400 "Bad Request"
-// @Failure 404 "Product Not Found"
+// @Header 200 {string} ETag "version of the product"
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Product Not Found"
+// @Failure 409 {object} problem.Problem "Conflict"
+// @Failure 412 {object} problem.Problem "Precondition Failed"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
 // @Router /product/{id} [put]
 func (c *Controller) HandlerUpdate() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -127,36 +302,115 @@ func (c *Controller) HandlerUpdate() gin.HandlerFunc {
 		// We receive the id of the product
 		idParam := ctx.Param("id")
 
-		var productRequest domain.Product
-
 		// We receive the new atributes of the product
-		err := ctx.Bind(&productRequest)
+		productRequest, err := bindProduct(ctx)
 
 		// If we have an error return it
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusBadRequest, "bad request")
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We receive the version the client expects, the one of the body is ignored
+		productRequest.Version, err = c.expectedVersion(ctx, idParam)
+		if err != nil {
+			problem.Abort(ctx, err)
 			return
 		}
 
 		// We call the service to update the product
 		product, err := c.service.Update(ctx, productRequest, idParam)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusNotFound, "Product not found")
+			problem.Abort(ctx, err)
 			return
 		}
 
 		// We return the product that was updated
+		conditional.SetValidators(ctx, product.Id, product.Version, product.UpdatedAt)
+		ctx.JSON(http.StatusOK, product)
+	}
+}
+
+// HandlerPatch is a function that calls the service for patch a product by id
+// @Summary Patch product
+// @Description Change only the fields named by the patch, with a JSON Merge Patch (RFC 7386) or a JSON Patch (RFC 6902).
+// @Description The id can't be patched. A patch that can't be applied, like a failed test operation, answers 422.
+// @Description Only a publisher can publish the product.
+// @Tags Products
+// @Accept application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being patched"
+// @Param patch body object true "merge patch object or array of patch operations"
+// @Success 200 {object} domain.Product
+// @Header 200 {string} ETag "version of the product"
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Product Not Found"
+// @Failure 409 {object} problem.Problem "Conflict"
+// @Failure 412 {object} problem.Problem "Precondition Failed"
+// @Failure 415 {object} problem.Problem "Unsupported Media Type"
+// @Failure 422 {object} problem.Problem "Unprocessable Patch"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Router /product/{id} [patch]
+func (c *Controller) HandlerPatch() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the id of the product
+		idParam := ctx.Param("id")
+
+		// We check the patch format by its media type
+		patchType := ctx.ContentType()
+		if patchType != products.PatchMerge && patchType != products.PatchJSON {
+			problem.Abort(ctx, fmt.Errorf("%w: use %s or %s", ErrUnsupportedMediaType, products.PatchMerge, products.PatchJSON))
+			return
+		}
+
+		// We receive the patch
+		document, err := ctx.GetRawData()
+		if err != nil {
+			problem.Abort(ctx, fmt.Errorf("%w: %v", ErrMalformedBody, err))
+			return
+		}
+
+		// We receive the version the client expects
+		version, err := c.expectedVersion(ctx, idParam)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We call the service to patch the product
+		product, err := c.service.Patch(ctx, idParam, products.Patch{Type: patchType, Document: document}, version)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the product that was patched
+		conditional.SetValidators(ctx, product.Id, product.Version, product.UpdatedAt)
 		ctx.JSON(http.StatusOK, product)
 	}
 }
 
 // HandlerDelete is a function that calls the service for delete a product by id
 // @Summary Delete product
-// @Description Delete a product in the db
+// @Description Move a product to the trash, where it can be restored until it's purged.
+// @Description With If-Match the delete only happens if the product is still in that version. Only an admin can delete.
 // @Tags Products
+// @Security BearerAuth
+// @Param token header string true "TOKEN_ENV"
 // @Param id path string true "id"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 200 "OK"
-// @Failure 404 "Product Not Found"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Product Not Found"
+// @Failure 412 {object} problem.Problem "Precondition Failed"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
 // @Router /product/{id} [delete]
 func (c *Controller) HandlerDelete() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -164,15 +418,107 @@ func (c *Controller) HandlerDelete() gin.HandlerFunc {
 		// We receive the id of the product
 		idParam := ctx.Param("id")
 
+		// We receive the version the client expects
+		version, err := c.expectedVersion(ctx, idParam)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
 		// We call the service to delete the product by id
-		err := c.service.Delete(ctx, idParam)
+		err = c.service.Delete(ctx, idParam, version)
 
 		// If we have an error return it
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusNotFound, "Product not found")
+			problem.Abort(ctx, err)
+			return
 		}
 
 		// We return the confirmation of the delete
 		ctx.JSON(http.StatusOK, "Product eliminated")
 	}
 }
+
+// HandlerTrash is a function that calls the service for get a page of the deleted products
+// @Summary Get the deleted products
+// @Description Return a page of the products in the trash that meet the filters, with the same parameters as GET /product
+// @Tags Products
+// @Produces json
+// @Security BearerAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param name query string false "case insensitive substring of the name"
+// @Param sort query string false "comma separated fields, - for descending order, like -price,name" default(id)
+// @Param limit query int false "size of the page, from 1 to 100" default(20)
+// @Param offset query int false "number of products to skip, ignored with cursor" default(0)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} ListResponse
+// @Failure 400 {object} problem.Problem "Invalid Query"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Router /product/trash [get]
+func (c *Controller) HandlerTrash() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// We receive the page that was asked
+		query, err := parseQuery(ctx)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We call the service to get the page of deleted products
+		page, err := c.service.Trash(ctx, query)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the page of deleted products
+		setLinkHeader(ctx, query, page)
+		ctx.JSON(http.StatusOK, ListResponse{
+			Data: page.Items,
+			Pagination: Pagination{
+				Limit:      query.Limit,
+				Offset:     query.Offset,
+				Total:      page.Total,
+				NextCursor: page.NextCursor,
+			},
+		})
+	}
+}
+
+// HandlerRestore is a function that calls the service for bring a deleted product back by id
+// @Summary Restore product
+// @Description Move a product from the trash back to the db. Only a publisher can restore a published product.
+// @Tags Products
+// @Produce json
+// @Security BearerAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param id path string true "id"
+// @Success 200 {object} domain.Product
+// @Header 200 {string} ETag "version of the product"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Product Not Found"
+// @Failure 409 {object} problem.Problem "Another product has its code value"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Router /product/{id}/restore [post]
+func (c *Controller) HandlerRestore() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the id of the product
+		idParam := ctx.Param("id")
+
+		// We call the service to restore the product
+		product, err := c.service.Restore(ctx, idParam)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the product that was restored
+		conditional.SetValidators(ctx, product.Id, product.Version, product.UpdatedAt)
+		ctx.JSON(http.StatusOK, product)
+	}
+}
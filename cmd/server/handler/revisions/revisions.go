@@ -0,0 +1,212 @@
+package revisions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+	"github.com/burgosfacundo/ApiGo.git/pkg/conditional"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrInvalidNumber is returned when a revision number is not a positive integer
+var ErrInvalidNumber = errors.New("invalid revision number")
+
+// Controller is a struct that contains the service of the revisions and the
+// one of the products, used to check the version of a rollback
+type Controller struct {
+	service  revisions.Service
+	products products.Service
+}
+
+// NewControllerRevisions is a function that loads the services into the controller
+func NewControllerRevisions(service revisions.Service, productsService products.Service) *Controller {
+	return &Controller{service: service, products: productsService}
+}
+
+// DiffResponse is a struct that contains the fields that changed between two revisions
+type DiffResponse struct {
+	From    int64                   `json:"from"`
+	To      int64                   `json:"to"`
+	Changes []revisions.FieldChange `json:"changes"`
+}
+
+// parseNumber is a function that reads a revision number
+func parseNumber(name, value string) (int64, error) {
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("%w: %s must be a positive integer", ErrInvalidNumber, name)
+	}
+	return number, nil
+}
+
+// HandlerList is a function that calls the service for get the revisions of a product
+// @Summary Get the revisions of a product
+// @Description Return every revision of a product sorted by number, the number is the version the write left
+// @Tags Revisions
+// @Produce json
+// @Security BearerAuth
+// @Param token header string false "TOKEN_ENV, required when AUTH_READS=required"
+// @Param id path string true "id"
+// @Success 200 {array} revisions.Revision
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Failure 500 {object} problem.Problem "Internal Server Error"
+// @Router /product/{id}/revisions [get]
+func (c *Controller) HandlerList() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We call the service to get the revisions of the product
+		list, err := c.service.List(ctx, ctx.Param("id"))
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the revisions
+		ctx.JSON(http.StatusOK, list)
+	}
+}
+
+// HandlerGet is a function that calls the service for get a revision of a product
+// @Summary Get a revision of a product
+// @Description Return the product as it was in a revision
+// @Tags Revisions
+// @Produce json
+// @Security BearerAuth
+// @Param token header string false "TOKEN_ENV, required when AUTH_READS=required"
+// @Param id path string true "id"
+// @Param number path int true "number"
+// @Success 200 {object} revisions.Revision
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Revision Not Found"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Router /product/{id}/revisions/{number} [get]
+func (c *Controller) HandlerGet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the number of the revision
+		number, err := parseNumber("number", ctx.Param("number"))
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We call the service to get the revision
+		revision, err := c.service.Get(ctx, ctx.Param("id"), number)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the revision
+		ctx.JSON(http.StatusOK, revision)
+	}
+}
+
+// HandlerDiff is a function that calls the service for compare two revisions of a product
+// @Summary Compare two revisions
+// @Description Return the fields of the product that changed from a revision to another
+// @Tags Revisions
+// @Produce json
+// @Security BearerAuth
+// @Param token header string false "TOKEN_ENV, required when AUTH_READS=required"
+// @Param id path string true "id"
+// @Param from query int true "number of the older revision"
+// @Param to query int true "number of the newer revision"
+// @Success 200 {object} DiffResponse
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Revision Not Found"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Router /product/{id}/revisions/diff [get]
+func (c *Controller) HandlerDiff() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the numbers of the revisions
+		from, err := parseNumber("from", ctx.Query("from"))
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		to, err := parseNumber("to", ctx.Query("to"))
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We call the service to compare them
+		changes, err := c.service.Diff(ctx, ctx.Param("id"), from, to)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the changes
+		ctx.JSON(http.StatusOK, DiffResponse{From: from, To: to, Changes: changes})
+	}
+}
+
+// HandlerRollback is a function that calls the service for roll a product back to a revision
+// @Summary Roll back a product
+// @Description Write the fields of a revision over the product as a new update, which is recorded as a new revision.
+// @Description With If-Match the rollback only happens if the product is still in that version.
+// @Tags Revisions
+// @Produce json
+// @Security BearerAuth
+// @Param token header string true "TOKEN_ENV"
+// @Param id path string true "id"
+// @Param number path int true "number"
+// @Param If-Match header string false "ETag of the version being rolled back"
+// @Success 200 {object} domain.Product
+// @Header 200 {string} ETag "version of the product"
+// @Failure 400 {object} problem.Problem "Bad Request"
+// @Failure 401 {object} problem.Problem "Unauthorized"
+// @Failure 403 {object} problem.Problem "Insufficient Scope or Role"
+// @Failure 404 {object} problem.Problem "Product or Revision Not Found"
+// @Failure 409 {object} problem.Problem "Conflict"
+// @Failure 412 {object} problem.Problem "Precondition Failed"
+// @Failure 429 {object} problem.Problem "Too Many Requests"
+// @Router /product/{id}/revisions/{number}/rollback [post]
+func (c *Controller) HandlerRollback() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+
+		// We receive the id of the product and the number of the revision
+		idParam := ctx.Param("id")
+		number, err := parseNumber("number", ctx.Param("number"))
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We receive the version the client expects
+		version, err := conditional.ExpectedVersion(ctx, idParam, func() (int64, error) {
+			product, err := c.products.GetByID(ctx, idParam)
+			return product.Version, err
+		})
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We call the service to roll the product back
+		product, err := c.service.Rollback(ctx, idParam, number, version)
+		if err != nil {
+			problem.Abort(ctx, err)
+			return
+		}
+
+		// We return the product as it is now
+		conditional.SetValidators(ctx, product.Id, product.Version, product.UpdatedAt)
+		ctx.JSON(http.StatusOK, product)
+	}
+}
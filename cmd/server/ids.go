@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+)
+
+// NewIDGenerator builds the generator of product ids selected by ID_GENERATOR.
+// The sequential generator continues after the highest numeric id in the
+// repository, the trashed products included, and in the revisions, which keep
+// the ids of the purged products so they are not handed out again.
+func NewIDGenerator(ctx context.Context, repository products.Repository, revisionStore revisions.Store) (products.IDGenerator, error) {
+	kind := getEnv("ID_GENERATOR", products.IDGeneratorUUIDv7)
+
+	var start uint64
+	if kind == products.IDGeneratorSequential {
+		listProducts, err := repository.GetAll(ctx)
+		if err != nil && !errors.Is(err, products.ErrEmpty) {
+			return nil, err
+		}
+
+		// The trashed products keep their ids until they are purged
+		query := products.Query{Limit: products.MaxLimit}
+		for {
+			page, err := repository.Trash(ctx, query)
+			if err != nil {
+				return nil, err
+			}
+			listProducts = append(listProducts, page.Items...)
+
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+
+		ids, err := revisionStore.ProductIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range listProducts {
+			ids = append(ids, product.Id)
+		}
+
+		for _, value := range ids {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err == nil && id > start {
+				start = id
+			}
+		}
+	}
+
+	return products.NewIDGenerator(kind, start)
+}
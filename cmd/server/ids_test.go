@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+)
+
+func TestNewIDGeneratorContinuesTheSequence(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("ID_GENERATOR", products.IDGeneratorSequential)
+
+	product := func(id string) domain.Product {
+		return domain.Product{Id: id, Name: "Agua", Quantity: 1, CodeValue: "code-" + id, Expiration: time.Now().Add(time.Hour), Price: 1}
+	}
+
+	// The highest id is in the trash and the ids that are not numbers are skipped
+	repository := products.NewMemoryRepository([]domain.Product{product("2"), product("7"), product("abc")})
+	if _, err := repository.Delete(ctx, "7", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	generator, err := NewIDGenerator(ctx, repository, revisions.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("new id generator: %v", err)
+	}
+	if id, err := generator.NewID(); err != nil || id != "8" {
+		t.Fatalf("got %q, %v, want 8", id, err)
+	}
+
+	// An empty repository starts at 1
+	generator, err = NewIDGenerator(ctx, products.NewMemoryRepository(nil), revisions.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("new id generator of an empty repository: %v", err)
+	}
+	if id, err := generator.NewID(); err != nil || id != "1" {
+		t.Fatalf("got %q, %v, want 1", id, err)
+	}
+}
+
+func TestNewIDGeneratorSkipsThePurgedIds(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("ID_GENERATOR", products.IDGeneratorSequential)
+
+	repository, revisionStore := products.NewMemoryRepository(nil), revisions.NewMemoryStore()
+
+	// start builds the service the way main does after every restart
+	start := func() products.Service {
+		generator, err := NewIDGenerator(ctx, repository, revisionStore)
+		if err != nil {
+			t.Fatalf("new id generator: %v", err)
+		}
+		return products.NewServiceProduct(repository,
+			products.WithIDGenerator(generator),
+			products.WithListener(revisions.NewRecorder(revisionStore)),
+		)
+	}
+
+	service := start()
+	created, err := service.Create(ctx, domain.Product{Name: "Agua", Quantity: 1, CodeValue: "code-1", Expiration: time.Now().Add(time.Hour), Price: 1})
+	if err != nil || created.Id != "1" {
+		t.Fatalf("create: got %+v, %v", created, err)
+	}
+	if err := service.Delete(ctx, created.Id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if purged, err := service.Purge(ctx, time.Now().Add(time.Minute)); err != nil || purged != 1 {
+		t.Fatalf("purge: got %d, %v", purged, err)
+	}
+
+	// The product is gone from the repository but its id is not handed out again
+	created, err = start().Create(ctx, domain.Product{Name: "Agua", Quantity: 1, CodeValue: "code-2", Expiration: time.Now().Add(time.Hour), Price: 1})
+	if err != nil || created.Id != "2" {
+		t.Fatalf("create after restart: got %+v, %v", created, err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/burgosfacundo/ApiGo.git/pkg/requestinfo"
+)
+
+// NewLogger reads from LOG_LEVEL the lowest level written, debug, info, warn
+// or error, and returns the logger that writes JSON lines to w. The lines
+// logged with the context of a request have its request_id.
+func NewLogger(w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+
+	return slog.New(requestinfo.NewLogHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))), nil
+}
+
+// LoadLoggerConfig reads the settings of the request log from the env.
+// LOG_SAMPLE_RATE is the fraction of the successful requests logged, LOG_HEADERS
+// logs the headers of the requests and LOG_REDACT_HEADERS adds comma separated
+// headers to the ones never logged.
+func LoadLoggerConfig() (middleware.LoggerConfig, error) {
+	sampleRate, err := strconv.ParseFloat(getEnv("LOG_SAMPLE_RATE", "1"), 64)
+	if err != nil || sampleRate < 0 || sampleRate > 1 {
+		return middleware.LoggerConfig{}, fmt.Errorf("invalid LOG_SAMPLE_RATE: must be a number from 0 to 1")
+	}
+
+	headers, err := strconv.ParseBool(getEnv("LOG_HEADERS", "false"))
+	if err != nil {
+		return middleware.LoggerConfig{}, fmt.Errorf("invalid LOG_HEADERS: %w", err)
+	}
+
+	redact := append([]string{}, middleware.DefaultRedactedHeaders...)
+	for _, name := range strings.Split(os.Getenv("LOG_REDACT_HEADERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			redact = append(redact, name)
+		}
+	}
+
+	return middleware.LoggerConfig{SampleRate: sampleRate, Headers: headers, Redact: redact}, nil
+}
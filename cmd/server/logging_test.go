@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+)
+
+// logLines is a function that returns the JSON lines written to the buffer
+func logLines(t *testing.T, buffer *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, fields)
+	}
+	buffer.Reset()
+	return lines
+}
+
+func TestRequestLog(t *testing.T) {
+	t.Setenv("LOG_HEADERS", "true")
+	t.Setenv("LOG_REDACT_HEADERS", "X-Secret")
+
+	requestLog, err := LoadLoggerConfig()
+	if err != nil {
+		t.Fatalf("load logger config: %v", err)
+	}
+
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&buffer)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional, Logger: logger, RequestLog: requestLog})
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/product/1?fields=name", nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	request.Header.Set("token", testToken)
+	request.Header.Set("X-Secret", "hidden")
+	request.Header.Set("X-Request-ID", "req-1")
+	request.Header.Set("Accept", "application/json")
+	engine.ServeHTTP(httptest.NewRecorder(), request)
+
+	lines := logLines(t, &buffer)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+
+	line := lines[0]
+	want := map[string]any{
+		"level":      "INFO",
+		"msg":        "request",
+		"method":     "GET",
+		"route":      "/api/v1/product/:id",
+		"path":       "/api/v1/product/1",
+		"status":     float64(http.StatusOK),
+		"client_ip":  "192.0.2.1",
+		"request_id": "req-1",
+		"subject":    "token",
+	}
+	for name, value := range want {
+		if line[name] != value {
+			t.Errorf("%s: got %v, want %v", name, line[name], value)
+		}
+	}
+	if size, ok := line["bytes"].(float64); !ok || size <= 0 {
+		t.Errorf("bytes: got %v", line["bytes"])
+	}
+	if _, ok := line["latency_ms"].(float64); !ok {
+		t.Errorf("latency_ms: got %v", line["latency_ms"])
+	}
+
+	headers, _ := line["headers"].(map[string]any)
+	if headers["Token"] != "[REDACTED]" || headers["X-Secret"] != "[REDACTED]" || headers["Accept"] != "application/json" {
+		t.Errorf("headers: got %v", headers)
+	}
+	if strings.Contains(buffer.String(), testToken) {
+		t.Error("the token was logged")
+	}
+}
+
+func TestRequestLogLevelAndSampling(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warn")
+
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&buffer)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	// Nothing successful is sampled, the failures are still logged
+	engine := newTestRouter(t, RouterConfig{
+		ReadAccess: middleware.AccessOptional,
+		Logger:     logger,
+		RequestLog: middleware.LoggerConfig{SampleRate: 0},
+	})
+
+	for _, path := range []string{"/api/v1/product/1", "/api/v1/product/missing", "/api/v1/nowhere"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	lines := logLines(t, &buffer)
+	if len(lines) != 2 || lines[0]["status"] != float64(http.StatusNotFound) || lines[0]["level"] != "WARN" || lines[1]["route"] != "" {
+		t.Fatalf("got %v", lines)
+	}
+
+	for name, value := range map[string]string{"LOG_LEVEL": "loud", "LOG_SAMPLE_RATE": "2", "LOG_HEADERS": "maybe"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			_, loggerErr := NewLogger(&buffer)
+			_, configErr := LoadLoggerConfig()
+			if loggerErr == nil && configErr == nil {
+				t.Errorf("%s=%s: got no error", name, value)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&buffer)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	// The services log with the default logger
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional, Logger: logger, RequestLog: middleware.LoggerConfig{SampleRate: 1}})
+
+	// request is a function that returns the request id answered to a read with the id
+	request := func(path, id string) string {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		if id != "" {
+			request.Header.Set("X-Request-ID", id)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder.Header().Get("X-Request-ID")
+	}
+
+	// The id of the client is kept, the missing and the unsafe ones are replaced
+	if id := request("/api/v1/product/1", "req-1"); id != "req-1" {
+		t.Errorf("sent id: got %q", id)
+	}
+	first, second := request("/api/v1/product/1", ""), request("/api/v1/product/1", "")
+	if first == "" || first == second {
+		t.Errorf("generated ids: got %q and %q", first, second)
+	}
+	for _, unsafe := range []string{"req 1", "req\n1", strings.Repeat("x", 129)} {
+		if id := request("/api/v1/product/1", unsafe); id == unsafe || id == "" {
+			t.Errorf("unsafe id %q: got %q", unsafe, id)
+		}
+	}
+	logLines(t, &buffer)
+
+	// The error of the service and the request line have the id of the request
+	request("/api/v1/product/missing", "req-2")
+
+	lines := logLines(t, &buffer)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %v", len(lines), lines)
+	}
+	if !strings.HasPrefix(lines[0]["msg"].(string), "[ProductsService][GetByID]") || lines[0]["request_id"] != "req-2" || lines[0]["error"] != "product not found" {
+		t.Errorf("service line: got %v", lines[0])
+	}
+	if lines[1]["msg"] != "request" || lines[1]["request_id"] != "req-2" {
+		t.Errorf("request line: got %v", lines[1])
+	}
+}
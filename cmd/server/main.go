@@ -1,20 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	handlerPing "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/ping"
-	handlerProduct "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/apikeys"
+	"github.com/burgosfacundo/ApiGo.git/internal/audit"
 	"github.com/burgosfacundo/ApiGo.git/internal/domain"
 	"github.com/burgosfacundo/ApiGo.git/internal/products"
-	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
-	"github.com/gin-gonic/gin"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+	"github.com/burgosfacundo/ApiGo.git/pkg/tracing"
 	"github.com/joho/godotenv"
-
-	_ "github.com/burgosfacundo/ApiGo.git/cmd/server/docs"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/otel"
 )
 
 // @title           Swagger Products API
@@ -32,6 +36,16 @@ import (
 // @host      localhost:8080
 // @BasePath  /api/v1
 
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 JWT as "Bearer <token>", the token header with TOKEN_ENV is also accepted
+
+// @securityDefinitions.apikey  APIKeyAuth
+// @in                          header
+// @name                        X-API-Key
+// @description                 Named api key created in /apikeys
+
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 func main() {
@@ -40,61 +54,131 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Loads the db into dinamyc memory
-	db := LoadStore()
+	// server migrate runs the schema migrations instead of the api
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := RunMigrate(context.Background(), os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// The logs are JSON lines, the ones of the services have the id of the request
+	logger, err := NewLogger(os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	// The server stops on SIGINT or SIGTERM, giving the requests in flight
+	// SHUTDOWN_TIMEOUT to finish. The deferred steps then run in reverse: the
+	// purge stops, the storage is closed and the spans are flushed.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownTimeout, err := LoadShutdownTimeout()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Ping.
-	controllerPing := handlerPing.NewControllerPing()
+	// The spans of the requests, the services and the repository are exported
+	// as set by TRACING_EXPORTER, and the calls to other services continue the trace
+	tracerProvider, err := NewTracerProvider(context.Background(), os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := tracerProvider.Shutdown(flushCtx); err != nil {
+			slog.Error("[Server][Shutdown] error flushing the spans", "error", err)
+		}
+	}()
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(tracing.Propagator)
 
 	// Products.
-	repository := products.NewMemoryRepository(db)
-	service := products.NewServiceProduct(repository)
-	controllerProduct := handlerProduct.NewControllerProducts(service)
+	storage, err := NewStorage(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer storage.Close()
 
-	engine := gin.Default()
-	/*
-		engine := gin.New()
-		engine.Use(gin.Recovery())
+	// The operations of the repository and the catalog are served in /metrics
+	registry := NewMetricsRegistry()
+	repository, err := InstrumentProducts(storage.Products, registry)
+	if err != nil {
+		log.Fatal(err)
+	}
+	repository = products.NewTracedRepository(repository, tracerProvider)
 
-		//Use the logger in the middleware and not gin's default logger
-		engine.Use(middleware.Logger())
-	*/
+	ids, err := NewIDGenerator(context.Background(), repository, storage.Revisions)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	// The roles of the callers are enforced by the service
+	authorizer, err := LoadAuthorizer()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// /api/v1 Group
-	group := engine.Group("/api/v1")
-	{
-		// /ping for testing
-		group.GET("/ping", controllerPing.HandlerPing())
+	// Every write of the products is recorded as a revision and in the audit log
+	service := products.NewTracedService(products.NewServiceProduct(repository,
+		products.WithIDGenerator(ids),
+		products.WithAuthorizer(authorizer),
+		products.WithListener(revisions.NewRecorder(storage.Revisions)),
+		products.WithListener(audit.NewRecorder(storage.Audit)),
+	), tracerProvider)
 
-		// /product group
-		grupoProduct := group.Group("/product")
-		{
-			// POST /product 	for create a new product
-			grupoProduct.POST("", middleware.Auth(), controllerProduct.HandlerCreate())
+	// Revisions.
+	revisionService := revisions.NewServiceRevisions(storage.Revisions, service, revisions.WithAuthorizer(authorizer))
 
-			// GET /product 	for get all the products
-			grupoProduct.GET("", controllerProduct.HandlerGetAll())
+	// Audit.
+	auditService := audit.NewServiceAudit(storage.Audit)
 
-			// GET /product/:id 	for get a single product for id
-			grupoProduct.GET("/:id", controllerProduct.HandlerGetByID())
+	// API keys.
+	apiKeyService := apikeys.NewServiceAPIKeys(storage.APIKeys, knownScopes)
 
-			// PUT /product/:id 	for edit a single product for id
-			grupoProduct.PUT("/:id", controllerProduct.HandlerUpdate())
+	// The trash is purged in the background while the server runs
+	stopPurge, err := StartPurge(ctx, service)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stopPurge()
 
-			// DELETE /product/:id 	for delete a single product for id
-			grupoProduct.DELETE("/:id", controllerProduct.HandlerDelete())
+	// Errors are answered as RFC 7807 problems
+	RegisterProblems()
 
-		}
+	config, err := LoadRouterConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 
+	// Every request is logged as a JSON line
+	config.Logger = logger
+	config.Metrics = registry
+
+	engine, err := NewRouter(Services{
+		Products:  service,
+		Revisions: revisionService,
+		Audit:     auditService,
+		APIKeys:   apiKeyService,
+	}, config)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// Run the engine in the port 8080
-	if err := engine.Run(":8080"); err != nil {
+	listener, err := net.Listen("tcp", ":8080")
+	if err != nil {
 		log.Fatal(err)
 	}
+	slog.Info("[Server][Serve] listening", "address", listener.Addr().String())
 
+	if err := Serve(ctx, &http.Server{Handler: engine}, listener, shutdownTimeout); err != nil {
+		slog.Error("[Server][Serve] error serving", "error", err)
+		return
+	}
+	slog.Info("[Server][Serve] stopped")
 }
 
 // LoadStore loads the db into dinamic memory
@@ -113,7 +197,7 @@ func LoadStore() []domain.Product {
 			Id:          "2",
 			Name:        "Pepsito",
 			Quantity:    10,
-			CodeValue:   "123456789",
+			CodeValue:   "234567891",
 			IsPublished: true,
 			Expiration:  time.Now(),
 			Price:       8.5,
@@ -122,7 +206,7 @@ func LoadStore() []domain.Product {
 			Id:          "3",
 			Name:        "Fantastica",
 			Quantity:    10,
-			CodeValue:   "123456789",
+			CodeValue:   "345678912",
 			IsPublished: true,
 			Expiration:  time.Now(),
 			Price:       5.5,
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// NewMetricsRegistry builds the registry of the metrics served in /metrics
+// with the ones of the Go runtime and the process
+func NewMetricsRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
+// InstrumentProducts returns the repository with the metrics of its operations
+// and registers the gauges of the catalog, which read the repository on every scrape
+func InstrumentProducts(repository products.Repository, registry *prometheus.Registry) (products.Repository, error) {
+	instrumented, err := products.NewInstrumentedRepository(repository, registry)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := registry.Register(products.NewCatalogCollector(repository)); err != nil {
+		return nil, err
+	}
+
+	return instrumented, nil
+}
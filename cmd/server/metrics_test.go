@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/burgosfacundo/ApiGo.git/pkg/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetrics(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{
+		ReadAccess: middleware.AccessOptional,
+		Metrics:    prometheus.NewRegistry(),
+	})
+
+	for _, path := range []string{"/api/v1/product/1", "/api/v1/product/2", "/api/v1/product/missing", "/nowhere/1", "/nowhere/2"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for _, method := range []string{"FOO", "BAR"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/api/v1/product/1", nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set("token", testToken)
+	engine.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("metrics: got %d %v", recorder.Code, recorder.Header())
+	}
+
+	// The requests are labeled by the template of their route, not by their path,
+	// and the methods that are not standard are all the same
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/api/v1/product/:id",status="200"} 2`,
+		`http_requests_total{method="GET",route="/api/v1/product/:id",status="404"} 1`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 2`,
+		`http_requests_total{method="other",route="unmatched",status="405"} 2`,
+		`http_request_duration_seconds_count{method="GET",route="/api/v1/product/:id",status="200"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics don't have %s", want)
+		}
+	}
+	if strings.Contains(body, "/nowhere") || strings.Contains(body, "FOO") {
+		t.Error("metrics have the path or the method of a client")
+	}
+}
+
+func TestMetricsRequireScope(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{
+		ReadAccess: middleware.AccessPublic,
+		RateLimits: RateLimits{Metrics: ratelimit.Limit{Requests: 2, Period: time.Minute}},
+	})
+
+	// The metrics are not public even when the reads are
+	if status, _ := apiKeyRequest(engine, http.MethodGet, "/metrics", "", "", ""); status != http.StatusUnauthorized {
+		t.Fatalf("no token: got %d, want 401", status)
+	}
+
+	// A key without metrics:read can't read them
+	status, body := apiKeyRequest(engine, http.MethodPost, "/api/v1/apikeys", `{"name":"reader","scopes":["products:read"]}`, testToken, "")
+	if status != http.StatusCreated {
+		t.Fatalf("create key: got %d %s", status, body)
+	}
+	var created struct {
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal([]byte(body), &created); err != nil {
+		t.Fatalf("create key: got %s, %v", body, err)
+	}
+	if status, _ := apiKeyRequest(engine, http.MethodGet, "/metrics", "", "", created.Secret); status != http.StatusForbidden {
+		t.Fatalf("key without the scope: got %d, want 403", status)
+	}
+
+	// The scrapers are limited like any other client
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if status, _ := apiKeyRequest(engine, http.MethodGet, "/metrics", "", testToken, ""); status != want {
+			t.Fatalf("scrape %d: got %d, want %d", i+1, status, want)
+		}
+	}
+}
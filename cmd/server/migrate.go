@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/migrations"
+)
+
+// migrateUsage is printed when the migrate subcommand is misused
+const migrateUsage = "usage: server migrate up | down [steps] | version"
+
+// RunMigrate runs the migrate subcommand against the db selected by STORAGE_DRIVER.
+//
+//	server migrate up           applies every pending migration
+//	server migrate down [steps] reverts the last steps migrations (default 1)
+//	server migrate version      prints the current and latest versions
+func RunMigrate(ctx context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New(migrateUsage)
+	}
+
+	driver := getEnv("STORAGE_DRIVER", StorageMemory)
+	if driver == StorageMemory {
+		return fmt.Errorf("STORAGE_DRIVER=%s has no schema to migrate", driver)
+	}
+
+	db, dialect, err := OpenDB(driver)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrations.NewMigrator(db, string(dialect))
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("applied %d migrations\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migrations\n", reverted)
+
+	case "version":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("current version %d, latest version %d\n", version, migrator.Latest())
+
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+)
+
+func TestPatchProduct(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+		code        string
+	}{
+		{name: "merge patch", contentType: products.PatchMerge, body: `{"name":"Coco Cola Light"}`, want: http.StatusOK},
+		{name: "merge patch with charset", contentType: products.PatchMerge + "; charset=utf-8", body: `{"quantity":5}`, want: http.StatusOK},
+		{name: "json patch", contentType: products.PatchJSON, body: `[{"op":"test","path":"/id","value":"1"},{"op":"replace","path":"/price","value":12}]`, want: http.StatusOK},
+		{name: "plain json", contentType: "application/json", body: `{"name":"Agua"}`, want: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+		{name: "no media type", contentType: "text/plain", body: `{"name":"Agua"}`, want: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+		{name: "merge patch of the id", contentType: products.PatchMerge, body: `{"id":"9"}`, want: http.StatusUnprocessableEntity, code: "unprocessable_patch"},
+		{name: "json patch of the id", contentType: products.PatchJSON, body: `[{"op":"replace","path":"/id","value":"9"}]`, want: http.StatusUnprocessableEntity, code: "unprocessable_patch"},
+		{name: "failed test", contentType: products.PatchJSON, body: `[{"op":"test","path":"/name","value":"Agua"},{"op":"replace","path":"/name","value":"Soda"}]`, want: http.StatusUnprocessableEntity, code: "unprocessable_patch"},
+		{name: "malformed merge patch", contentType: products.PatchMerge, body: `{"name":`, want: http.StatusBadRequest, code: "malformed_patch"},
+		{name: "malformed json patch", contentType: products.PatchJSON, body: `{"op":"replace"}`, want: http.StatusBadRequest, code: "malformed_patch"},
+		{name: "patch that breaks a rule", contentType: products.PatchMerge, body: `{"price":0}`, want: http.StatusBadRequest, code: "validation_failed"},
+	}
+	for _, c := range cases {
+		before := etagOf(t, engine, "/api/v1/product/1")
+
+		response := conditionalRequest(engine, http.MethodPatch, "/api/v1/product/1", c.body, map[string]string{"Content-Type": c.contentType})
+		if response.Code != c.want || !strings.Contains(response.Body.String(), c.code) {
+			t.Errorf("%s: got %d %s, want %d %s", c.name, response.Code, response.Body, c.want, c.code)
+			continue
+		}
+
+		// Only a patch that was applied makes a new version, with the same id
+		after := etagOf(t, engine, "/api/v1/product/1")
+		if c.want == http.StatusOK {
+			if after == before || response.Header().Get("ETag") != after || !strings.Contains(response.Body.String(), `"id":"1"`) {
+				t.Errorf("%s: got ETag %s, the product had %s and has %s", c.name, response.Header().Get("ETag"), before, after)
+			}
+		} else if after != before {
+			t.Errorf("%s: the product changed to %s", c.name, after)
+		}
+	}
+
+	// The patched fields are there and the rest are untouched
+	response := conditionalRequest(engine, http.MethodGet, "/api/v1/product/1", "", nil)
+	for _, member := range []string{`"name":"Coco Cola Light"`, `"quantity":5`, `"price":12`, `"code_value":"123456789"`} {
+		if !strings.Contains(response.Body.String(), member) {
+			t.Errorf("product after the patches: got %s, want %s", response.Body, member)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	handlerProduct "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/products"
+	handlerRevision "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/revisions"
+	"github.com/burgosfacundo/ApiGo.git/internal/apikeys"
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+	"github.com/burgosfacundo/ApiGo.git/pkg/conditional"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
+	"github.com/burgosfacundo/ApiGo.git/pkg/ratelimit"
+)
+
+// RegisterProblems registers how every known error is answered by the api.
+// The codes are part of the api contract, don't change them.
+func RegisterProblems() {
+	problem.Register(
+		problem.Mapping{
+			Target: domain.ErrValidation,
+			Status: http.StatusBadRequest,
+			Code:   "validation_failed",
+			Extend: func(err error, p *problem.Problem) {
+				var validationErr *domain.ValidationError
+				if errors.As(err, &validationErr) {
+					p.Errors = validationErr.Errors
+				}
+			},
+		},
+		problem.Mapping{
+			Target: handlerProduct.ErrMalformedBody,
+			Status: http.StatusBadRequest,
+			Code:   "malformed_body",
+		},
+		problem.Mapping{
+			Target: products.ErrMalformedPatch,
+			Status: http.StatusBadRequest,
+			Code:   "malformed_patch",
+		},
+		problem.Mapping{
+			Target: products.ErrInvalidQuery,
+			Status: http.StatusBadRequest,
+			Code:   "invalid_query",
+		},
+		problem.Mapping{
+			Target: handlerRevision.ErrInvalidNumber,
+			Status: http.StatusBadRequest,
+			Code:   "invalid_revision_number",
+		},
+		problem.Mapping{
+			Target: apikeys.ErrInvalidRequest,
+			Status: http.StatusBadRequest,
+			Code:   "invalid_api_key_request",
+		},
+		problem.Mapping{
+			Target: middleware.ErrInvalidToken,
+			Status: http.StatusUnauthorized,
+			Code:   "invalid_token",
+		},
+		problem.Mapping{
+			Target: auth.ErrUnauthenticated,
+			Status: http.StatusUnauthorized,
+			Code:   "authentication_required",
+		},
+		problem.Mapping{
+			Target: auth.ErrInsufficientScope,
+			Status: http.StatusForbidden,
+			Code:   "insufficient_scope",
+		},
+		problem.Mapping{
+			Target: products.ErrForbidden,
+			Status: http.StatusForbidden,
+			Code:   "forbidden",
+		},
+		problem.Mapping{
+			Target: handlerProduct.ErrUnsupportedMediaType,
+			Status: http.StatusUnsupportedMediaType,
+			Code:   "unsupported_media_type",
+		},
+		problem.Mapping{
+			Target: products.ErrInvalidPatch,
+			Status: http.StatusUnprocessableEntity,
+			Code:   "unprocessable_patch",
+		},
+		problem.Mapping{
+			Target: products.ErrNotFound,
+			Status: http.StatusNotFound,
+			Code:   "product_not_found",
+		},
+		problem.Mapping{
+			Target: revisions.ErrNotFound,
+			Status: http.StatusNotFound,
+			Code:   "revision_not_found",
+		},
+		problem.Mapping{
+			Target: apikeys.ErrNotFound,
+			Status: http.StatusNotFound,
+			Code:   "api_key_not_found",
+		},
+		problem.Mapping{
+			Target: products.ErrConflict,
+			Status: http.StatusConflict,
+			Code:   "product_conflict",
+		},
+		problem.Mapping{
+			Target: apikeys.ErrConflict,
+			Status: http.StatusConflict,
+			Code:   "api_key_conflict",
+		},
+		problem.Mapping{
+			Target: products.ErrVersionMismatch,
+			Status: http.StatusPreconditionFailed,
+			Code:   "precondition_failed",
+		},
+		problem.Mapping{
+			Target: conditional.ErrPreconditionFailed,
+			Status: http.StatusPreconditionFailed,
+			Code:   "precondition_failed",
+		},
+		problem.Mapping{
+			Target: ratelimit.ErrLimitExceeded,
+			Status: http.StatusTooManyRequests,
+			Code:   "rate_limit_exceeded",
+		},
+	)
+}
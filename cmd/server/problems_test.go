@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	handlerProduct "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+	"github.com/burgosfacundo/ApiGo.git/pkg/conditional"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
+)
+
+func TestRegisterProblems(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{err: &domain.ValidationError{Errors: []domain.FieldError{{Field: "name", Rule: "required"}}}, status: http.StatusBadRequest, code: "validation_failed"},
+		{err: handlerProduct.ErrMalformedBody, status: http.StatusBadRequest, code: "malformed_body"},
+		{err: products.ErrForbidden, status: http.StatusForbidden, code: "forbidden"},
+		{err: fmt.Errorf("wrapped: %w", products.ErrNotFound), status: http.StatusNotFound, code: "product_not_found"},
+		{err: revisions.ErrNotFound, status: http.StatusNotFound, code: "revision_not_found"},
+		{err: &products.ConflictError{Field: "code_value", Value: "123"}, status: http.StatusConflict, code: "product_conflict"},
+		{err: products.ErrVersionMismatch, status: http.StatusPreconditionFailed, code: "precondition_failed"},
+		{err: conditional.ErrPreconditionFailed, status: http.StatusPreconditionFailed, code: "precondition_failed"},
+		// An empty list is answered by the list routes, it's not a problem
+		{err: products.ErrEmpty, status: http.StatusInternalServerError, code: problem.CodeInternal},
+	}
+	for _, c := range cases {
+		got := problem.FromError(c.err)
+		if got.Status != c.status || got.Code != c.code {
+			t.Errorf("%v: got %d %s, want %d %s", c.err, got.Status, got.Code, c.status, c.code)
+		}
+	}
+
+	// The invalid fields are in the problem
+	got := problem.FromError(cases[0].err)
+	if fieldErrors, ok := got.Errors.([]domain.FieldError); !ok || len(fieldErrors) != 1 || fieldErrors[0].Field != "name" {
+		t.Fatalf("validation problem: got errors %+v", got.Errors)
+	}
+}
+
+func TestEmptyListIsNotAnError(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	// Nothing was deleted yet
+	response := conditionalRequest(engine, http.MethodGet, "/api/v1/product/trash", "", nil)
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"data":[]`) {
+		t.Fatalf("empty trash: got %d %s", response.Code, response.Body)
+	}
+
+	// Every product is deleted
+	for _, id := range []string{"1", "2", "3"} {
+		if response := conditionalRequest(engine, http.MethodDelete, "/api/v1/product/"+id, "", nil); response.Code != http.StatusOK {
+			t.Fatalf("delete %s: got %d %s", id, response.Code, response.Body)
+		}
+	}
+	response = conditionalRequest(engine, http.MethodGet, "/api/v1/product", "", nil)
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"data":[]`) {
+		t.Fatalf("empty list: got %d %s", response.Code, response.Body)
+	}
+}
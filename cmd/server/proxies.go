@@ -0,0 +1,20 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// LoadTrustedProxies reads from TRUSTED_PROXIES the comma separated ips or
+// CIDRs of the proxies whose X-Forwarded-For is believed. By default no proxy
+// is trusted and the client ip is the address of the connection, otherwise
+// any client could choose the ip written in the audit log.
+func LoadTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
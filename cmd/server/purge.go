@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/rbac"
+	"github.com/burgosfacundo/ApiGo.git/pkg/identity"
+)
+
+// StartPurge starts the job that purges the trash, configured by
+// TRASH_RETENTION and TRASH_PURGE_INTERVAL. It runs as a job of the server,
+// which the roles don't restrict and the audit log records as the system,
+// until ctx is done or stop is called. stop waits for a purge in progress, so
+// the storage can be closed after it.
+func StartPurge(ctx context.Context, service products.Service) (stop func(), err error) {
+	retention, err := time.ParseDuration(getEnv("TRASH_RETENTION", products.DefaultTrashRetention.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRASH_RETENTION: %w", err)
+	}
+
+	interval, err := time.ParseDuration(getEnv("TRASH_PURGE_INTERVAL", products.DefaultPurgeInterval.String()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRASH_PURGE_INTERVAL: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		products.RunPurge(identity.WithSubject(rbac.AsSystem(ctx), identity.System), service, retention, interval)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/ratelimit"
+)
+
+// Default limits of every client in each group of routes
+const (
+	defaultRateLimitProduct = "600/1m"
+	defaultRateLimitAudit   = "60/1m"
+	defaultRateLimitAPIKeys = "60/1m"
+	defaultRateLimitMetrics = "60/1m"
+	defaultRateLimitAuth    = "10/1m:20"
+)
+
+// RateLimits is a struct that contains the limit of every client in each group of routes
+type RateLimits struct {
+	// Product is used by the routes of /product, the revisions included
+	Product ratelimit.Limit
+
+	// Audit is used by GET /audit
+	Audit ratelimit.Limit
+
+	// APIKeys is used by the routes of /apikeys
+	APIKeys ratelimit.Limit
+
+	// Metrics is used by GET /metrics
+	Metrics ratelimit.Limit
+
+	// Auth is used by the failed authentications of every ip, in every route
+	Auth ratelimit.Limit
+}
+
+// LoadRateLimits reads the limits from RATE_LIMIT_PRODUCT, RATE_LIMIT_AUDIT,
+// RATE_LIMIT_APIKEYS, RATE_LIMIT_METRICS and RATE_LIMIT_AUTH, written as
+// requests/period[:burst] or off
+func LoadRateLimits() (RateLimits, error) {
+	var limits RateLimits
+
+	for name, target := range map[string]struct {
+		limit    *ratelimit.Limit
+		fallback string
+	}{
+		"RATE_LIMIT_PRODUCT": {&limits.Product, defaultRateLimitProduct},
+		"RATE_LIMIT_AUDIT":   {&limits.Audit, defaultRateLimitAudit},
+		"RATE_LIMIT_APIKEYS": {&limits.APIKeys, defaultRateLimitAPIKeys},
+		"RATE_LIMIT_METRICS": {&limits.Metrics, defaultRateLimitMetrics},
+		"RATE_LIMIT_AUTH":    {&limits.Auth, defaultRateLimitAuth},
+	} {
+		limit, err := ratelimit.ParseLimit(getEnv(name, target.fallback))
+		if err != nil {
+			return RateLimits{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*target.limit = limit
+	}
+
+	return limits, nil
+}
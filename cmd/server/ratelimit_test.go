@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/burgosfacundo/ApiGo.git/pkg/ratelimit"
+)
+
+// failingStore is a ratelimit.Store that always fails
+type failingStore struct{}
+
+// Take is a function that returns an error
+func (failingStore) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("store is down")
+}
+
+// Refund is a function that returns an error
+func (failingStore) Refund(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) error {
+	return errors.New("store is down")
+}
+
+// limitedRequest is a function that returns the response of a read of a product from the ip with the token
+func limitedRequest(engine http.Handler, path, ip, token string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.RemoteAddr = ip + ":1234"
+	if token != "" {
+		request.Header.Set("token", token)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRateLimit(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{
+		ReadAccess: middleware.AccessOptional,
+		RateLimits: RateLimits{
+			Product: ratelimit.Limit{Requests: 2, Period: time.Minute},
+			APIKeys: ratelimit.Limit{Requests: 1, Period: time.Minute},
+		},
+	})
+
+	for i, remaining := range []string{"1", "0"} {
+		response := limitedRequest(engine, "/api/v1/product/1", "192.0.2.1", "")
+		if response.Code != http.StatusOK || response.Header().Get("RateLimit-Limit") != "2" ||
+			response.Header().Get("RateLimit-Remaining") != remaining || response.Header().Get("RateLimit-Policy") != "2;w=60" {
+			t.Fatalf("request %d: got %d %v", i, response.Code, response.Header())
+		}
+	}
+
+	response := limitedRequest(engine, "/api/v1/product/1", "192.0.2.1", "")
+	if response.Code != http.StatusTooManyRequests || response.Header().Get("Retry-After") != "30" || response.Header().Get("RateLimit-Reset") != "60" {
+		t.Fatalf("request over the limit: got %d %v %s", response.Code, response.Header(), response.Body.String())
+	}
+
+	// Another ip and an authenticated client from the same ip have their own buckets
+	if response := limitedRequest(engine, "/api/v1/product/1", "192.0.2.2", ""); response.Code != http.StatusOK {
+		t.Fatalf("request from another ip: got %d", response.Code)
+	}
+	if response := limitedRequest(engine, "/api/v1/product/1", "192.0.2.1", testToken); response.Code != http.StatusOK {
+		t.Fatalf("request with the token: got %d", response.Code)
+	}
+
+	// Each group has its own limit and the routes without one are not limited
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if response := limitedRequest(engine, "/api/v1/apikeys", "192.0.2.1", testToken); response.Code != want {
+			t.Fatalf("api keys request %d: got %d, want %d", i, response.Code, want)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if response := limitedRequest(engine, "/api/v1/audit", "192.0.2.1", testToken); response.Code != http.StatusOK || response.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("audit request %d: got %d %v", i, response.Code, response.Header())
+		}
+	}
+}
+
+func TestRateLimitLetsThroughWhenTheStoreFails(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{
+		ReadAccess:     middleware.AccessOptional,
+		RateLimits:     RateLimits{Product: ratelimit.Limit{Requests: 1, Period: time.Minute}},
+		RateLimitStore: failingStore{},
+	})
+
+	for i := 0; i < 3; i++ {
+		if response := limitedRequest(engine, "/api/v1/product/1", "192.0.2.1", ""); response.Code != http.StatusOK {
+			t.Fatalf("request %d: got %d", i, response.Code)
+		}
+	}
+}
+
+func TestAuthFailureLimit(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{
+		ReadAccess: middleware.AccessOptional,
+		RateLimits: RateLimits{Auth: ratelimit.Limit{Requests: 2, Period: time.Minute}},
+	})
+
+	// The clients that authenticate or don't send credentials are never limited by it
+	for i := 0; i < 5; i++ {
+		if response := limitedRequest(engine, "/api/v1/product/trash", "192.0.2.2", testToken); response.Code != http.StatusOK {
+			t.Fatalf("request %d with the token: got %d", i, response.Code)
+		}
+		if response := limitedRequest(engine, "/api/v1/product/1", "192.0.2.2", ""); response.Code != http.StatusOK {
+			t.Fatalf("request %d without credentials: got %d", i, response.Code)
+		}
+	}
+
+	// After its failures the ip is answered 429, its credentials are not even checked
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if response := limitedRequest(engine, "/api/v1/product/trash", "192.0.2.1", "guess"); response.Code != want {
+			t.Fatalf("guess %d: got %d, want %d", i, response.Code, want)
+		}
+	}
+	response := limitedRequest(engine, "/metrics", "192.0.2.1", testToken)
+	if response.Code != http.StatusTooManyRequests || response.Header().Get("Retry-After") != "30" {
+		t.Fatalf("right token after the failures: got %d %v", response.Code, response.Header())
+	}
+
+	// Other ips keep their own failures
+	if response := limitedRequest(engine, "/api/v1/product/trash", "192.0.2.2", "guess"); response.Code != http.StatusUnauthorized {
+		t.Fatalf("guess from another ip: got %d", response.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+)
+
+// renameProduct is a function that updates the name of the product 1 and
+// returns it as it is after the update
+func renameProduct(t *testing.T, engine http.Handler, name string) domain.Product {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"name":%q,"quantity":10,"code_value":"123456789","is_published":true,"expiration":"2099-01-01T00:00:00Z","price":10.5}`, name)
+	response := conditionalRequest(engine, http.MethodPut, "/api/v1/product/1", body, nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("rename to %s: got %d %s", name, response.Code, response.Body)
+	}
+
+	var product domain.Product
+	if err := json.Unmarshal(response.Body.Bytes(), &product); err != nil {
+		t.Fatalf("rename to %s: %v", name, err)
+	}
+	return product
+}
+
+// etagOf is a function that returns the ETag of the product in path
+func etagOf(t *testing.T, engine http.Handler, path string) string {
+	t.Helper()
+
+	response := conditionalRequest(engine, http.MethodGet, path, "", nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("get %s: got %d %s", path, response.Code, response.Body)
+	}
+	return response.Header().Get("ETag")
+}
+
+func TestRevisions(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	first, second := renameProduct(t, engine, "Coco Cola Light"), renameProduct(t, engine, "Coco Cola Zero")
+
+	// Every write left a revision with the version it made
+	response := conditionalRequest(engine, http.MethodGet, "/api/v1/product/1/revisions", "", nil)
+	var list []revisions.Revision
+	if err := json.Unmarshal(response.Body.Bytes(), &list); err != nil || response.Code != http.StatusOK {
+		t.Fatalf("list: got %d %s, %v", response.Code, response.Body, err)
+	}
+	if len(list) != 2 || list[0].Number != first.Version || list[1].Number != second.Version || list[1].Product.Name != "Coco Cola Zero" {
+		t.Fatalf("list: got %+v", list)
+	}
+
+	// A product without writes has no revisions
+	if response := conditionalRequest(engine, http.MethodGet, "/api/v1/product/2/revisions", "", nil); response.Code != http.StatusOK || strings.TrimSpace(response.Body.String()) != "[]" {
+		t.Fatalf("list without revisions: got %d %s", response.Code, response.Body)
+	}
+
+	cases := []struct {
+		name string
+		path string
+		want int
+		body string
+	}{
+		{name: "get", path: fmt.Sprintf("/api/v1/product/1/revisions/%d", first.Version), want: http.StatusOK, body: `"name":"Coco Cola Light"`},
+		{name: "get missing", path: "/api/v1/product/1/revisions/99", want: http.StatusNotFound},
+		{name: "get of another product", path: fmt.Sprintf("/api/v1/product/2/revisions/%d", first.Version), want: http.StatusNotFound},
+		{name: "get not a number", path: "/api/v1/product/1/revisions/abc", want: http.StatusBadRequest},
+		{name: "get zero", path: "/api/v1/product/1/revisions/0", want: http.StatusBadRequest},
+		{
+			name: "diff",
+			path: fmt.Sprintf("/api/v1/product/1/revisions/diff?from=%d&to=%d", first.Version, second.Version),
+			want: http.StatusOK,
+			body: `{"field":"name","from":"Coco Cola Light","to":"Coco Cola Zero"}`,
+		},
+		{name: "diff without to", path: fmt.Sprintf("/api/v1/product/1/revisions/diff?from=%d", first.Version), want: http.StatusBadRequest},
+		{name: "diff of a missing revision", path: fmt.Sprintf("/api/v1/product/1/revisions/diff?from=%d&to=99", first.Version), want: http.StatusNotFound},
+	}
+	for _, c := range cases {
+		response := conditionalRequest(engine, http.MethodGet, c.path, "", nil)
+		if response.Code != c.want || !strings.Contains(response.Body.String(), c.body) {
+			t.Errorf("%s: got %d %s, want %d with %s", c.name, response.Code, response.Body, c.want, c.body)
+		}
+	}
+}
+
+func TestRevisionsRollback(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	first, second := renameProduct(t, engine, "Coco Cola Light"), renameProduct(t, engine, "Coco Cola Zero")
+	path := fmt.Sprintf("/api/v1/product/1/revisions/%d/rollback", first.Version)
+
+	// The product is not in the version of the tag, or the tag can't be read
+	for _, ifMatch := range []string{`"` + fmt.Sprint(first.Version) + `"`, `"abc`, etagOf(t, engine, "/api/v1/product/2")} {
+		if response := conditionalRequest(engine, http.MethodPost, path, "", map[string]string{"If-Match": ifMatch}); response.Code != http.StatusPreconditionFailed {
+			t.Fatalf("rollback with %s: got %d %s", ifMatch, response.Code, response.Body)
+		}
+	}
+	stale := etagOf(t, engine, "/api/v1/product/1")
+	renameProduct(t, engine, "Coco Cola Cherry")
+	if response := conditionalRequest(engine, http.MethodPost, path, "", map[string]string{"If-Match": stale}); response.Code != http.StatusPreconditionFailed {
+		t.Fatalf("rollback with a stale tag: got %d %s", response.Code, response.Body)
+	}
+
+	// With the current tag the rollback is a new update, recorded as a new revision
+	current := etagOf(t, engine, "/api/v1/product/1")
+	response := conditionalRequest(engine, http.MethodPost, path, "", map[string]string{"If-Match": current})
+	if response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"name":"Coco Cola Light"`) {
+		t.Fatalf("rollback: got %d %s", response.Code, response.Body)
+	}
+	etag := response.Header().Get("ETag")
+	if etag == "" || etag == current || etagOf(t, engine, "/api/v1/product/1") != etag {
+		t.Fatalf("rollback: got ETag %s, the product had %s", etag, current)
+	}
+
+	response = conditionalRequest(engine, http.MethodGet, "/api/v1/product/1/revisions", "", nil)
+	var list []revisions.Revision
+	if err := json.Unmarshal(response.Body.Bytes(), &list); err != nil || len(list) != 4 || list[3].Product.Name != "Coco Cola Light" || list[3].Number <= second.Version {
+		t.Fatalf("revisions after the rollback: got %+v, %v", list, err)
+	}
+
+	// Without If-Match the rollback happens in any version
+	path = fmt.Sprintf("/api/v1/product/1/revisions/%d/rollback", second.Version)
+	if response := conditionalRequest(engine, http.MethodPost, path, "", nil); response.Code != http.StatusOK || !strings.Contains(response.Body.String(), `"name":"Coco Cola Zero"`) {
+		t.Fatalf("rollback without If-Match: got %d %s", response.Code, response.Body)
+	}
+
+	if response := conditionalRequest(engine, http.MethodPost, "/api/v1/product/1/revisions/99/rollback", "", nil); response.Code != http.StatusNotFound {
+		t.Fatalf("rollback to a missing revision: got %d %s", response.Code, response.Body)
+	}
+}
+
+func TestRevisionsRequireRead(t *testing.T) {
+	// The anonymous callers can't read the products, so neither their revisions
+	path := filepath.Join(t.TempDir(), "rbac.json")
+	config := fmt.Sprintf(`{"roles":{"viewer":["products:read"],"admin":["*"]},"subjects":{%q:["admin"]}}`, middleware.TokenSubject)
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatalf("write rbac config: %v", err)
+	}
+	t.Setenv("RBAC_CONFIG", path)
+
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+	first, second := renameProduct(t, engine, "Coco Cola Light"), renameProduct(t, engine, "Coco Cola Zero")
+
+	for _, path := range []string{
+		"/api/v1/product/1",
+		"/api/v1/product/1/revisions",
+		fmt.Sprintf("/api/v1/product/1/revisions/%d", first.Version),
+		fmt.Sprintf("/api/v1/product/1/revisions/diff?from=%d&to=%d", first.Version, second.Version),
+	} {
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), `"code":"forbidden"`) {
+			t.Errorf("anonymous %s: got %d %s, want 403", path, recorder.Code, recorder.Body)
+		}
+
+		if response := conditionalRequest(engine, http.MethodGet, path, "", nil); response.Code != http.StatusOK {
+			t.Errorf("admin %s: got %d %s", path, response.Code, response.Body)
+		}
+	}
+}
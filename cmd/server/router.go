@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	handlerAPIKeys "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/apikeys"
+	handlerAudit "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/audit"
+	handlerPing "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/ping"
+	handlerProduct "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/products"
+	handlerRevision "github.com/burgosfacundo/ApiGo.git/cmd/server/handler/revisions"
+	"github.com/burgosfacundo/ApiGo.git/internal/apikeys"
+	"github.com/burgosfacundo/ApiGo.git/internal/audit"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+	"github.com/burgosfacundo/ApiGo.git/pkg/conditional"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
+	"github.com/burgosfacundo/ApiGo.git/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	_ "github.com/burgosfacundo/ApiGo.git/cmd/server/docs"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// Services is a struct that contains the services served by the api
+type Services struct {
+	Products  products.Service
+	Revisions revisions.Service
+	Audit     audit.Service
+	APIKeys   apikeys.Service
+}
+
+// RouterConfig is a struct that contains the settings of the routes
+type RouterConfig struct {
+	Cache          CachePolicies
+	TrustedProxies []string
+
+	// ReadAccess is the authentication of the reads of the api, the writes always require it
+	ReadAccess middleware.Access
+
+	// Verifier checks the bearer JWTs, nil to only accept TOKEN_ENV
+	Verifier *auth.Verifier
+
+	// TokenScopes are the scopes granted to TOKEN_ENV
+	TokenScopes []string
+
+	// RateLimits are the limits of every client in each group of routes, the
+	// zero ones don't limit. RateLimitStore keeps the buckets, in memory when nil.
+	RateLimits     RateLimits
+	RateLimitStore ratelimit.Store
+
+	// Logger writes a line per request, slog.Default() when nil
+	Logger     *slog.Logger
+	RequestLog middleware.LoggerConfig
+
+	// Metrics has the metrics served in /metrics, the ones of the requests are
+	// registered in it. A new registry is used when nil.
+	Metrics *prometheus.Registry
+
+	// Tracer starts the spans of the requests, the global provider when nil
+	Tracer trace.TracerProvider
+}
+
+// LoadRouterConfig reads the settings of the routes from the env
+func LoadRouterConfig() (RouterConfig, error) {
+	readAccess, err := middleware.ParseAccess(getEnv("AUTH_READS", string(middleware.AccessOptional)))
+	if err != nil {
+		return RouterConfig{}, fmt.Errorf("invalid AUTH_READS: %w", err)
+	}
+
+	verifier, err := LoadVerifier()
+	if err != nil {
+		return RouterConfig{}, err
+	}
+
+	rateLimits, err := LoadRateLimits()
+	if err != nil {
+		return RouterConfig{}, err
+	}
+
+	requestLog, err := LoadLoggerConfig()
+	if err != nil {
+		return RouterConfig{}, err
+	}
+
+	return RouterConfig{
+		Cache:          LoadCachePolicies(),
+		TrustedProxies: LoadTrustedProxies(),
+		ReadAccess:     readAccess,
+		Verifier:       verifier,
+		TokenScopes:    LoadTokenScopes(),
+		RateLimits:     rateLimits,
+		RequestLog:     requestLog,
+	}, nil
+}
+
+// NewRouter builds the engine with every route of the api. The routes of
+// /api/v1 are authenticated by the policy of the group: the writes require a
+// token, the reads get the configured access and the exceptions are listed here.
+// Each route also requires the scope of what it does.
+func NewRouter(services Services, config RouterConfig) (*gin.Engine, error) {
+	// Controllers.
+	controllerPing := handlerPing.NewControllerPing()
+	controllerProduct := handlerProduct.NewControllerProducts(services.Products)
+	controllerRevision := handlerRevision.NewControllerRevisions(services.Revisions, services.Products)
+	controllerAudit := handlerAudit.NewControllerAudit(services.Audit)
+	controllerAPIKeys := handlerAPIKeys.NewControllerAPIKeys(services.APIKeys)
+
+	engine := gin.New()
+
+	// The handlers pass their gin context to the services, which read the
+	// values stored in the request context like the identity
+	engine.ContextWithFallback = true
+
+	// The client ip is only taken from X-Forwarded-For behind a trusted proxy
+	if err := engine.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return nil, err
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	registry := config.Metrics
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+	metrics, err := middleware.Metrics(registry)
+	if err != nil {
+		return nil, err
+	}
+	tracer := config.Tracer
+	if tracer == nil {
+		tracer = otel.GetTracerProvider()
+	}
+	engine.Use(middleware.RequestID(), middleware.Tracing(tracer), metrics, middleware.Logger(logger, config.RequestLog), problem.Recovery(), middleware.ClientIP())
+	engine.HandleMethodNotAllowed = true
+	engine.NoRoute(problem.NoRoute())
+	engine.NoMethod(problem.NoMethod())
+
+	engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// The routes that don't follow the policy
+	policy := middleware.NewAuthPolicy(config.ReadAccess).
+		Public(http.MethodGet, "/api/v1/ping").
+		Protect(http.MethodGet, "/api/v1/product/trash").
+		Protect(http.MethodGet, "/api/v1/audit").
+		Protect(http.MethodGet, "/api/v1/apikeys").
+		Protect(http.MethodGet, "/api/v1/apikeys/:id").
+		Protect(http.MethodGet, "/metrics").
+		WithVerifier(config.Verifier).
+		WithTokenScopes(config.TokenScopes...).
+		WithKeys(apikeys.NewKeyAuthenticator(services.APIKeys))
+
+	// Every client is limited in each group after it's authenticated, and
+	// every ip in the failed authentications before it
+	limits := config.RateLimitStore
+	if limits == nil {
+		limits = ratelimit.NewMemoryStore()
+	}
+	authFailures := middleware.AuthFailureLimit(limits, config.RateLimits.Auth)
+
+	// GET /metrics 	for get the metrics in the Prometheus text format, they
+	// tell the size and the value of the catalog so only the scrapers can read them
+	engine.GET("/metrics",
+		authFailures,
+		policy.Middleware(),
+		middleware.RateLimit(limits, "metrics", config.RateLimits.Metrics),
+		auth.RequireScopes(ScopeMetricsRead),
+		gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})),
+	)
+
+	// /api/v1 Group
+	group := engine.Group("/api/v1")
+	group.Use(authFailures, policy.Middleware())
+
+	// The reads only ask for their scope when they require authentication
+	read := func(handlers ...gin.HandlerFunc) []gin.HandlerFunc {
+		if config.ReadAccess == middleware.AccessRequired {
+			return append([]gin.HandlerFunc{auth.RequireScopes(ScopeProductsRead)}, handlers...)
+		}
+		return handlers
+	}
+	write := auth.RequireScopes(ScopeProductsWrite)
+	{
+		// /ping for testing
+		group.GET("/ping", controllerPing.HandlerPing())
+
+		// /product group
+		grupoProduct := group.Group("/product", middleware.RateLimit(limits, "product", config.RateLimits.Product))
+		{
+			// POST /product 	for create a new product
+			grupoProduct.POST("", write, controllerProduct.HandlerCreate())
+
+			// GET /product 	for get all the products
+			grupoProduct.GET("", read(conditional.CacheControl(config.Cache.ProductList), controllerProduct.HandlerGetAll())...)
+
+			// GET /product/trash 	for get the deleted products
+			grupoProduct.GET("/trash", auth.RequireScopes(ScopeProductsRead), controllerProduct.HandlerTrash())
+
+			// GET /product/code/:code 	for get a single product for code value
+			grupoProduct.GET("/code/:code", read(conditional.CacheControl(config.Cache.Product), controllerProduct.HandlerGetByCode())...)
+
+			// GET /product/:id 	for get a single product for id
+			grupoProduct.GET("/:id", read(conditional.CacheControl(config.Cache.Product), controllerProduct.HandlerGetByID())...)
+
+			// PUT /product/:id 	for edit a single product for id
+			grupoProduct.PUT("/:id", write, controllerProduct.HandlerUpdate())
+
+			// PATCH /product/:id 	for edit some fields of a single product for id
+			grupoProduct.PATCH("/:id", write, controllerProduct.HandlerPatch())
+
+			// DELETE /product/:id 	for move a single product for id to the trash
+			grupoProduct.DELETE("/:id", write, controllerProduct.HandlerDelete())
+
+			// POST /product/:id/restore 	for bring a single product for id back from the trash
+			grupoProduct.POST("/:id/restore", write, controllerProduct.HandlerRestore())
+
+			// GET /product/:id/revisions 	for get the revisions of a single product for id
+			grupoProduct.GET("/:id/revisions", read(controllerRevision.HandlerList())...)
+
+			// GET /product/:id/revisions/diff 	for compare two revisions of a single product for id
+			grupoProduct.GET("/:id/revisions/diff", read(controllerRevision.HandlerDiff())...)
+
+			// GET /product/:id/revisions/:number 	for get a revision of a single product for id
+			grupoProduct.GET("/:id/revisions/:number", read(controllerRevision.HandlerGet())...)
+
+			// POST /product/:id/revisions/:number/rollback 	for roll a single product for id back to a revision
+			grupoProduct.POST("/:id/revisions/:number/rollback", write, controllerRevision.HandlerRollback())
+
+		}
+
+		// GET /audit 	for get who wrote which product, when and from where
+		group.GET("/audit", middleware.RateLimit(limits, "audit", config.RateLimits.Audit), auth.RequireScopes(ScopeAuditRead), controllerAudit.HandlerQuery())
+
+		// /apikeys group, only for the admins of the keys
+		grupoAPIKeys := group.Group("/apikeys", middleware.RateLimit(limits, "apikeys", config.RateLimits.APIKeys), auth.RequireScopes(ScopeAPIKeysAdmin))
+		{
+			// POST /apikeys 	for create a new api key
+			grupoAPIKeys.POST("", controllerAPIKeys.HandlerCreate())
+
+			// GET /apikeys 	for get all the api keys
+			grupoAPIKeys.GET("", controllerAPIKeys.HandlerList())
+
+			// GET /apikeys/:id 	for get a single api key for id
+			grupoAPIKeys.GET("/:id", controllerAPIKeys.HandlerGet())
+
+			// POST /apikeys/:id/rotate 	for give a single api key for id a new secret
+			grupoAPIKeys.POST("/:id/rotate", controllerAPIKeys.HandlerRotate())
+
+			// DELETE /apikeys/:id 	for revoke a single api key for id
+			grupoAPIKeys.DELETE("/:id", controllerAPIKeys.HandlerRevoke())
+		}
+	}
+
+	return engine, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/apikeys"
+	"github.com/burgosfacundo/ApiGo.git/internal/audit"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// testToken is the TOKEN_ENV of the route tests
+const testToken = "secret"
+
+// routeAccess is the access every route of the api must have with the
+// default AUTH_READS. A new route must be added here to pass the tests.
+var routeAccess = map[string]middleware.Access{
+	"GET /api/v1/ping":                                    middleware.AccessPublic,
+	"POST /api/v1/product":                                middleware.AccessRequired,
+	"GET /api/v1/product":                                 middleware.AccessOptional,
+	"GET /api/v1/product/trash":                           middleware.AccessRequired,
+	"GET /api/v1/product/code/:code":                      middleware.AccessOptional,
+	"GET /api/v1/product/:id":                             middleware.AccessOptional,
+	"PUT /api/v1/product/:id":                             middleware.AccessRequired,
+	"PATCH /api/v1/product/:id":                           middleware.AccessRequired,
+	"DELETE /api/v1/product/:id":                          middleware.AccessRequired,
+	"POST /api/v1/product/:id/restore":                    middleware.AccessRequired,
+	"GET /api/v1/product/:id/revisions":                   middleware.AccessOptional,
+	"GET /api/v1/product/:id/revisions/diff":              middleware.AccessOptional,
+	"GET /api/v1/product/:id/revisions/:number":           middleware.AccessOptional,
+	"POST /api/v1/product/:id/revisions/:number/rollback": middleware.AccessRequired,
+	"GET /api/v1/audit":                                   middleware.AccessRequired,
+	"POST /api/v1/apikeys":                                middleware.AccessRequired,
+	"GET /api/v1/apikeys":                                 middleware.AccessRequired,
+	"GET /api/v1/apikeys/:id":                             middleware.AccessRequired,
+	"POST /api/v1/apikeys/:id/rotate":                     middleware.AccessRequired,
+	"DELETE /api/v1/apikeys/:id":                          middleware.AccessRequired,
+}
+
+func TestMain(m *testing.M) {
+	// The errors are answered like in the server and the request log is silenced
+	RegisterProblems()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+
+	os.Exit(m.Run())
+}
+
+// newTestRouter is a function that returns the router over the sample products
+// in memory, TOKEN_ENV can do everything
+func newTestRouter(t *testing.T, config RouterConfig) *gin.Engine {
+	t.Helper()
+	t.Setenv("TOKEN_ENV", testToken)
+
+	config.Cache = LoadCachePolicies()
+	config.TokenScopes = defaultTokenScopes
+	if config.Logger == nil {
+		config.Logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	}
+
+	// The default roles are enforced and the writes are recorded as revisions
+	// and in the audit log like in the server
+	authorizer, err := LoadAuthorizer()
+	if err != nil {
+		t.Fatalf("load authorizer: %v", err)
+	}
+	revisionStore, auditStore := revisions.NewMemoryStore(), audit.NewMemoryStore()
+	service := products.NewServiceProduct(products.NewMemoryRepository(LoadStore()),
+		products.WithAuthorizer(authorizer),
+		products.WithListener(revisions.NewRecorder(revisionStore)),
+		products.WithListener(audit.NewRecorder(auditStore)),
+	)
+	engine, err := NewRouter(Services{
+		Products:  service,
+		Revisions: revisions.NewServiceRevisions(revisionStore, service, revisions.WithAuthorizer(authorizer)),
+		Audit:     audit.NewServiceAudit(auditStore),
+		APIKeys:   apikeys.NewServiceAPIKeys(apikeys.NewMemoryStore(), knownScopes),
+	}, config)
+	if err != nil {
+		t.Fatalf("new router: %v", err)
+	}
+
+	return engine
+}
+
+// apiRoutes is a function that returns the routes of the engine under /api/v1
+func apiRoutes(engine *gin.Engine) []gin.RouteInfo {
+	var routes []gin.RouteInfo
+	for _, route := range engine.Routes() {
+		if strings.HasPrefix(route.Path, "/api/v1") {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// status is a function that returns the status of a request to the route with the token
+func status(engine *gin.Engine, route gin.RouteInfo, token string) int {
+	path := strings.NewReplacer(":id", "1", ":code", "123456789", ":number", "1").Replace(route.Path)
+
+	request := httptest.NewRequest(route.Method, path, nil)
+	if token != "" {
+		request.Header.Set("token", token)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+// checkAccess is a function that asserts the route answers like the access says
+func checkAccess(t *testing.T, engine *gin.Engine, route gin.RouteInfo, access middleware.Access) {
+	t.Helper()
+	name := route.Method + " " + route.Path
+
+	withoutToken := status(engine, route, "")
+	wrongToken := status(engine, route, "wrong")
+	rightToken := status(engine, route, testToken)
+
+	if rightToken == http.StatusUnauthorized || rightToken == http.StatusForbidden {
+		t.Errorf("%s with the token: got %d", name, rightToken)
+	}
+
+	switch access {
+	case middleware.AccessPublic:
+		if withoutToken == http.StatusUnauthorized || wrongToken == http.StatusUnauthorized {
+			t.Errorf("%s is public: got %d without token and %d with a wrong one", name, withoutToken, wrongToken)
+		}
+	case middleware.AccessOptional:
+		if withoutToken == http.StatusUnauthorized || wrongToken != http.StatusUnauthorized {
+			t.Errorf("%s is optional: got %d without token and %d with a wrong one", name, withoutToken, wrongToken)
+		}
+	case middleware.AccessRequired:
+		if withoutToken != http.StatusUnauthorized || wrongToken != http.StatusUnauthorized {
+			t.Errorf("%s is required: got %d without token and %d with a wrong one", name, withoutToken, wrongToken)
+		}
+	}
+}
+
+func TestRoutesAuthPolicy(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessOptional})
+
+	routes := apiRoutes(engine)
+	if len(routes) != len(routeAccess) {
+		t.Errorf("got %d routes, the table has %d", len(routes), len(routeAccess))
+	}
+
+	for _, route := range routes {
+		access, ok := routeAccess[route.Method+" "+route.Path]
+		if !ok {
+			t.Errorf("%s %s has no access in the table", route.Method, route.Path)
+			continue
+		}
+		checkAccess(t, engine, route, access)
+	}
+}
+
+func TestRoutesReadsRequireAuth(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessRequired})
+
+	// Every route but the public ones requires the token
+	for _, route := range apiRoutes(engine) {
+		access := middleware.AccessRequired
+		if routeAccess[route.Method+" "+route.Path] == middleware.AccessPublic {
+			access = middleware.AccessPublic
+		}
+		checkAccess(t, engine, route, access)
+	}
+}
+
+func TestRoutesWritesIgnoreReadAccess(t *testing.T) {
+	engine := newTestRouter(t, RouterConfig{ReadAccess: middleware.AccessPublic})
+
+	// The writes still require the token when the reads are public
+	for _, route := range apiRoutes(engine) {
+		if route.Method == http.MethodGet {
+			continue
+		}
+		checkAccess(t, engine, route, middleware.AccessRequired)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultShutdownTimeout is how long the requests in flight have to finish
+// when the server stops and SHUTDOWN_TIMEOUT is not set
+const defaultShutdownTimeout = 30 * time.Second
+
+// LoadShutdownTimeout reads from SHUTDOWN_TIMEOUT how long the requests in
+// flight have to finish when the server stops
+func LoadShutdownTimeout() (time.Duration, error) {
+	timeout, err := time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", defaultShutdownTimeout.String()))
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid SHUTDOWN_TIMEOUT: must be a positive duration")
+	}
+	return timeout, nil
+}
+
+// Serve serves the connections of the listener until ctx is done, usually
+// by SIGINT or SIGTERM. Then it stops accepting new ones and waits up to
+// timeout for the requests in flight, the ones that don't finish are cut.
+func Serve(ctx context.Context, server *http.Server, listener net.Listener, timeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return err
+	}
+
+	// Serve returns ErrServerClosed as soon as Shutdown is called
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
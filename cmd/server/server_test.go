@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServeShutsDownGracefully(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	// The request is in flight when the server is told to stop
+	started, release := make(chan struct{}), make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, server, listener, time.Minute)
+	}()
+
+	response := make(chan string, 1)
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			response <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		response <- string(body)
+	}()
+
+	<-started
+	stop()
+
+	// Serve waits for the request, and new connections are refused meanwhile
+	select {
+	case err := <-served:
+		t.Fatalf("serve returned %v before the request finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if conn, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("a new connection was accepted while shutting down")
+	}
+
+	close(release)
+	if body := <-response; body != "done" {
+		t.Fatalf("got response %q, want done", body)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+}
+
+func TestLoadShutdownTimeout(t *testing.T) {
+	if timeout, err := LoadShutdownTimeout(); err != nil || timeout != defaultShutdownTimeout {
+		t.Fatalf("default: got %v, %v", timeout, err)
+	}
+
+	for _, value := range []string{"soon", "0s", "-1s"} {
+		t.Setenv("SHUTDOWN_TIMEOUT", value)
+		if _, err := LoadShutdownTimeout(); err == nil {
+			t.Errorf("%s: got no error", value)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/apikeys"
+	"github.com/burgosfacundo/ApiGo.git/internal/audit"
+	"github.com/burgosfacundo/ApiGo.git/internal/migrations"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/internal/revisions"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// Storage drivers that can be selected with STORAGE_DRIVER
+const (
+	StorageMemory   = "memory"
+	StorageSQLite   = "sqlite"
+	StoragePostgres = "postgres"
+)
+
+// defaultSQLitePath is the file used when SQLITE_PATH is not set
+const defaultSQLitePath = "products.db"
+
+// getEnv returns the value of the environment variable or the fallback if it's empty
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Storage is a struct that contains the stores selected by STORAGE_DRIVER
+type Storage struct {
+	Products  products.Repository
+	Revisions revisions.Store
+	Audit     audit.Store
+	APIKeys   apikeys.Store
+
+	// Close releases the resources held by the stores
+	Close func() error
+}
+
+// NewStorage builds the stores selected by STORAGE_DRIVER
+func NewStorage(ctx context.Context) (Storage, error) {
+	driver := getEnv("STORAGE_DRIVER", StorageMemory)
+	if driver == StorageMemory {
+		return NewMemoryStorage()
+	}
+
+	db, dialect, err := OpenDB(driver)
+	if err != nil {
+		return Storage{}, err
+	}
+
+	// The SQL schemas are migrated on startup unless AUTO_MIGRATE=false,
+	// in that case `server migrate up` has to be run before
+	if getEnv("AUTO_MIGRATE", "true") == "true" {
+		migrator, err := migrations.NewMigrator(db, string(dialect))
+		if err != nil {
+			db.Close()
+			return Storage{}, err
+		}
+
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			db.Close()
+			return Storage{}, err
+		}
+
+		if applied > 0 {
+			slog.InfoContext(ctx, "[Storage] applied migrations", "count", applied, "dialect", dialect)
+		}
+	}
+
+	return Storage{
+		Products:  products.NewSQLRepository(db, dialect),
+		Revisions: revisions.NewSQLStore(db, dialect),
+		Audit:     audit.NewSQLStore(db, dialect),
+		APIKeys:   apikeys.NewSQLStore(db, dialect),
+		Close:     db.Close,
+	}, nil
+}
+
+// NewMemoryStorage builds the memory stores. When MEMORY_SNAPSHOT_PATH is set
+// the products are loaded from that JSON file and every write is persisted,
+// with the revisions in the same path plus .revisions, the audit log plus
+// .audit and the api keys plus .apikeys. Otherwise the sample products of LoadStore are used.
+func NewMemoryStorage() (Storage, error) {
+	snapshotPath := os.Getenv("MEMORY_SNAPSHOT_PATH")
+	if snapshotPath == "" {
+		// Loads the db into dinamyc memory
+		return Storage{
+			Products:  products.NewMemoryRepository(LoadStore()),
+			Revisions: revisions.NewMemoryStore(),
+			Audit:     audit.NewMemoryStore(),
+			APIKeys:   apikeys.NewMemoryStore(),
+			Close:     func() error { return nil },
+		}, nil
+	}
+
+	interval, err := time.ParseDuration(getEnv("MEMORY_SNAPSHOT_INTERVAL", products.DefaultSnapshotInterval.String()))
+	if err != nil {
+		return Storage{}, fmt.Errorf("invalid MEMORY_SNAPSHOT_INTERVAL: %w", err)
+	}
+
+	repository, err := products.NewFileRepository(products.FileConfig{
+		SnapshotPath:     snapshotPath,
+		SnapshotInterval: interval,
+	})
+	if err != nil {
+		return Storage{}, err
+	}
+
+	revisionStore, err := revisions.NewFileStore(snapshotPath + ".revisions")
+	if err != nil {
+		repository.Close()
+		return Storage{}, err
+	}
+
+	auditStore, err := audit.NewFileStore(snapshotPath + ".audit")
+	if err != nil {
+		repository.Close()
+		revisionStore.Close()
+		return Storage{}, err
+	}
+
+	apiKeyStore, err := apikeys.NewFileStore(snapshotPath + ".apikeys")
+	if err != nil {
+		repository.Close()
+		revisionStore.Close()
+		auditStore.Close()
+		return Storage{}, err
+	}
+
+	return Storage{
+		Products:  repository,
+		Revisions: revisionStore,
+		Audit:     auditStore,
+		APIKeys:   apiKeyStore,
+		Close: func() error {
+			return errors.Join(repository.Close(), revisionStore.Close(), auditStore.Close())
+		},
+	}, nil
+}
+
+// OpenDB opens the sql connection of the driver and returns the dialect it speaks
+func OpenDB(driver string) (*sql.DB, products.Dialect, error) {
+	switch driver {
+	case StorageSQLite:
+		db, err := OpenSQLite(getEnv("SQLITE_PATH", defaultSQLitePath))
+		return db, products.DialectSQLite, err
+
+	case StoragePostgres:
+		dsn := os.Getenv("DATABASE_URL")
+		if dsn == "" {
+			return nil, "", fmt.Errorf("DATABASE_URL is required when STORAGE_DRIVER=%s", driver)
+		}
+		db, err := sql.Open("pgx", dsn)
+		return db, products.DialectPostgres, err
+
+	default:
+		return nil, "", fmt.Errorf("unknown STORAGE_DRIVER %q", driver)
+	}
+}
+
+// OpenSQLite opens the SQLite file in path
+func OpenSQLite(path string) (*sql.DB, error) {
+	// WAL lets readers work while a write is in progress and busy_timeout
+	// makes concurrent writers wait instead of failing with SQLITE_BUSY
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+
+	return sql.Open("sqlite", dsn)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+)
+
+func TestOpenSQLite(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "products.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+
+	// The readers don't wait for a write and the writers wait for each other
+	var journalMode string
+	var busyTimeout int
+	if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Fatalf("got journal mode %q, %v, want wal", journalMode, err)
+	}
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil || busyTimeout != 5000 {
+		t.Fatalf("got busy timeout %d, %v, want 5000", busyTimeout, err)
+	}
+}
+
+func TestNewStorageSQLite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "products.db")
+	t.Setenv("STORAGE_DRIVER", StorageSQLite)
+	t.Setenv("SQLITE_PATH", path)
+
+	// A db created by the SQLite repository before the migrations keeps its products
+	db, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS products (
+		id           TEXT PRIMARY KEY,
+		name         TEXT NOT NULL,
+		quantity     INTEGER NOT NULL,
+		code_value   TEXT NOT NULL,
+		is_published BOOLEAN NOT NULL,
+		expiration   TIMESTAMP NOT NULL,
+		price        REAL NOT NULL
+	)`)
+	if err == nil {
+		_, err = db.Exec("INSERT INTO products VALUES ('1', 'Agua', 1, '123', 1, ?, 1)", time.Now().Add(24*time.Hour).UTC())
+	}
+	db.Close()
+	if err != nil {
+		t.Fatalf("bootstrap: %v", err)
+	}
+
+	storage, err := NewStorage(ctx)
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+	if product, err := storage.Products.GetByID(ctx, "1"); err != nil || product.Name != "Agua" || product.Version != 1 {
+		t.Fatalf("get the product of the bootstrap: got %+v, %v", product, err)
+	}
+	if _, err := storage.Products.Create(ctx, domain.Product{
+		Id: "2", Name: "Soda", Quantity: 1, CodeValue: "456", Expiration: time.Now().Add(24 * time.Hour), Price: 2,
+	}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	storage.Close()
+
+	// The products are still there after opening the storage again
+	storage, err = NewStorage(ctx)
+	if err != nil {
+		t.Fatalf("reopen storage: %v", err)
+	}
+	defer storage.Close()
+
+	if list, err := storage.Products.GetAll(ctx); err != nil || len(list) != 2 {
+		t.Fatalf("get all after reopening: got %+v, %v", list, err)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Exporters of the spans that can be selected with TRACING_EXPORTER
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+// defaultServiceName is the service.name of the spans when OTEL_SERVICE_NAME is not set
+const defaultServiceName = "products-api"
+
+// NewTracerProvider builds the provider of the spans with the exporter selected
+// by TRACING_EXPORTER: none, stdout, which writes them to w as JSON, or otlp,
+// which sends them over HTTP to OTEL_EXPORTER_OTLP_ENDPOINT. TRACING_SAMPLE_RATIO
+// is the fraction of the traces started here that are sampled, the ones
+// continued from a traceparent follow the decision of the caller.
+//
+// With none the spans are not recorded, but the traceparent of the requests
+// is still propagated. The provider must be shut down to flush the spans.
+func NewTracerProvider(ctx context.Context, w io.Writer) (*sdktrace.TracerProvider, error) {
+	ratio, err := strconv.ParseFloat(getEnv("TRACING_SAMPLE_RATIO", "1"), 64)
+	if err != nil || ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("invalid TRACING_SAMPLE_RATIO: must be a number from 0 to 1")
+	}
+
+	var exporter sdktrace.SpanExporter
+	switch kind := getEnv("TRACING_EXPORTER", TracingNone); kind {
+	case TracingNone:
+		return sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())), nil
+
+	case TracingStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+
+	case TracingOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The attributes of OTEL_RESOURCE_ATTRIBUTES are kept, the service name is ours
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", getEnv("OTEL_SERVICE_NAME", defaultServiceName)),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/middleware"
+	"github.com/burgosfacundo/ApiGo.git/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// A traceparent of a caller, with its trace id and the id of its span
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+	testTraceparent = "00-" + testTraceID + "-" + testParentID + "-01"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	var buffer bytes.Buffer
+	logger, err := NewLogger(&buffer)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	engine := newTestRouter(t, RouterConfig{
+		ReadAccess: middleware.AccessOptional,
+		Tracer:     sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		Logger:     logger,
+		RequestLog: middleware.LoggerConfig{SampleRate: 1},
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/product/1", nil)
+	request.Header.Set("traceparent", testTraceparent)
+	engine.ServeHTTP(httptest.NewRecorder(), request)
+
+	// The span of the request continues the trace of the caller
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /api/v1/product/:id" || span.SpanContext.TraceID().String() != testTraceID ||
+		span.Parent.SpanID().String() != testParentID || !span.Parent.IsRemote() {
+		t.Fatalf("got span %s in trace %s with parent %s", span.Name, span.SpanContext.TraceID(), span.Parent.SpanID())
+	}
+	if !hasAttribute(span, attribute.Int("http.response.status_code", http.StatusOK)) ||
+		!hasAttribute(span, attribute.String("http.route", "/api/v1/product/:id")) {
+		t.Errorf("got attributes %v", span.Attributes)
+	}
+
+	// The request log has the trace to find its spans
+	if lines := logLines(t, &buffer); len(lines) != 1 || lines[0]["trace_id"] != testTraceID {
+		t.Errorf("got log lines %v", lines)
+	}
+
+	// Without a traceparent a new trace is started, the client errors are not errors of the span
+	exporter.Reset()
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+	spans = exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "GET unmatched" || spans[0].Parent.IsValid() || spans[0].Status.Code == codes.Error {
+		t.Fatalf("got spans %v", spans)
+	}
+}
+
+// hasAttribute is a function that reports if the span has the attribute
+func hasAttribute(span tracetest.SpanStub, want attribute.KeyValue) bool {
+	for _, kv := range span.Attributes {
+		if kv == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTracingClientPropagates(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	provider := sdktrace.NewTracerProvider()
+	ctx, span := provider.Tracer("test").Start(context.Background(), "call")
+	defer span.End()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	response, err := tracing.NewHTTPClient().Do(request)
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	response.Body.Close()
+
+	// The server called is a child of the span of the call
+	want := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	if traceparent != want {
+		t.Fatalf("got traceparent %q, want %q", traceparent, want)
+	}
+	if request.Header.Get("traceparent") != "" {
+		t.Fatal("the request of the caller was modified")
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	for _, exporter := range []string{TracingNone, TracingStdout} {
+		t.Setenv("TRACING_EXPORTER", exporter)
+		var buffer bytes.Buffer
+		provider, err := NewTracerProvider(context.Background(), &buffer)
+		if err != nil {
+			t.Fatalf("%s: %v", exporter, err)
+		}
+
+		_, span := provider.Tracer("test").Start(context.Background(), "exported")
+		span.End()
+		if err := provider.Shutdown(context.Background()); err != nil {
+			t.Fatalf("%s: shutdown: %v", exporter, err)
+		}
+
+		// Only stdout writes the spans, none still gives them a trace to propagate
+		if exported := strings.Contains(buffer.String(), `"Name":"exported"`); exported != (exporter == TracingStdout) || !span.SpanContext().IsValid() {
+			t.Errorf("%s: got %q", exporter, buffer.String())
+		}
+	}
+
+	t.Setenv("TRACING_EXPORTER", "zipkin")
+	if _, err := NewTracerProvider(context.Background(), &bytes.Buffer{}); err == nil {
+		t.Error("unknown exporter: got no error")
+	}
+
+	t.Setenv("TRACING_EXPORTER", TracingStdout)
+	t.Setenv("TRACING_SAMPLE_RATIO", "2")
+	if _, err := NewTracerProvider(context.Background(), &bytes.Buffer{}); err == nil {
+		t.Error("sample ratio over 1: got no error")
+	}
+}
@@ -0,0 +1,123 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errors that can be returned in the response
+var (
+	ErrNotFound       = errors.New("api key not found")
+	ErrConflict       = errors.New("api key already exists")
+	ErrInvalidRequest = errors.New("invalid api key request")
+	ErrInvalidKey     = errors.New("invalid api key")
+)
+
+// Prefix starts every api key, so a leaked one is easy to recognize
+const Prefix = "pk_"
+
+// secretBytes is the size of the random part of a key
+const secretBytes = 32
+
+// Key is a struct that represents a named api key. The key given to the client
+// is Prefix, the id, an underscore and a secret. Only the hashes of the
+// secrets are kept: the current one and, during the overlap of a rotation,
+// the previous ones until they expire.
+type Key struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Secrets    []Secret   `json:"secrets"`
+}
+
+// Secret is a struct that represents a secret of a key. ExpiresAt is set when
+// a rotation replaced it.
+type Secret struct {
+	Hash      string     `json:"hash,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Active is a function that returns if the key can be used at the time
+func (k Key) Active(at time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || at.Before(*k.ExpiresAt)
+}
+
+// Redacted is a function that returns the key without the hashes of its
+// secrets, as it's shown to the admins
+func (k Key) Redacted() Key {
+	secrets := make([]Secret, len(k.Secrets))
+	for i, secret := range k.Secrets {
+		secret.Hash = ""
+		secrets[i] = secret
+	}
+	k.Secrets = secrets
+	return k
+}
+
+// match is a function that returns if the secret is one of the key at the time
+func (k Key) match(secret string, at time.Time) bool {
+	hash := hashSecret(secret)
+
+	// We compare every secret so the time doesn't tell which one matched
+	matched := false
+	for _, stored := range k.Secrets {
+		equal := subtle.ConstantTimeCompare([]byte(hash), []byte(stored.Hash)) == 1
+		if equal && (stored.ExpiresAt == nil || at.Before(*stored.ExpiresAt)) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// newSecret is a function that returns a random secret and its hash
+func newSecret() (string, string, error) {
+	content := make([]byte, secretBytes)
+	if _, err := rand.Read(content); err != nil {
+		return "", "", err
+	}
+
+	secret := base64.RawURLEncoding.EncodeToString(content)
+	return secret, hashSecret(secret), nil
+}
+
+// hashSecret is a function that returns the hex SHA-256 of the secret. The
+// secrets are random, so a salt or a slow hash would add nothing.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// format is a function that returns the key given to the client
+func format(id, secret string) string {
+	return Prefix + id + "_" + secret
+}
+
+// parse is a function that returns the id and the secret of a key given to a client
+func parse(key string) (string, string, error) {
+	rest, ok := strings.CutPrefix(key, Prefix)
+	if !ok {
+		return "", "", ErrInvalidKey
+	}
+
+	// The ids have no underscore, the secrets may have
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return "", "", ErrInvalidKey
+	}
+
+	return id, secret, nil
+}
@@ -0,0 +1,302 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+)
+
+// Limits of the overlap of a rotation
+const (
+	DefaultOverlap = 24 * time.Hour
+	MaxOverlap     = 30 * 24 * time.Hour
+)
+
+// lastUsedResolution is how old the last use of a key must be to record a new
+// one, so a busy key doesn't write on every request
+const lastUsedResolution = time.Minute
+
+// maxNameLength is the longest name of a key
+const maxNameLength = 64
+
+// CreateRequest is a struct that contains the settings of a new key
+type CreateRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Service represents a contract with all the functions that need to be implemented.
+// The keys returned are redacted, the secret is only returned by Create and Rotate.
+type Service interface {
+	Create(ctx context.Context, request CreateRequest) (Key, string, error)
+	List(ctx context.Context) ([]Key, error)
+	Get(ctx context.Context, id string) (Key, error)
+	Rotate(ctx context.Context, id string, overlap time.Duration) (Key, string, error)
+	Revoke(ctx context.Context, id string) (Key, error)
+	Authenticate(ctx context.Context, secret string) (Key, error)
+}
+
+// service is a struct that contains the store of the keys and the scopes they can have
+type service struct {
+	store  Store
+	ids    products.IDGenerator
+	scopes map[string]bool
+
+	// mu serializes the changes of the keys, which read and then write them
+	mu sync.Mutex
+}
+
+// NewServiceAPIKeys is a function that loads the store and the scopes a key can be granted into the service
+func NewServiceAPIKeys(store Store, scopes []string) Service {
+	known := map[string]bool{}
+	for _, scope := range scopes {
+		known[scope] = true
+	}
+
+	return &service{store: store, ids: products.NewULIDGenerator(), scopes: known}
+}
+
+// validate is a function that checks the settings of a new key
+func (s *service) validate(request CreateRequest, now time.Time) error {
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxNameLength {
+		return fmt.Errorf("%w: name must have 1 to %d characters", ErrInvalidRequest, maxNameLength)
+	}
+
+	if len(request.Scopes) == 0 {
+		return fmt.Errorf("%w: scopes must not be empty", ErrInvalidRequest)
+	}
+	for _, scope := range request.Scopes {
+		if !s.scopes[scope] {
+			return fmt.Errorf("%w: unknown scope %q", ErrInvalidRequest, scope)
+		}
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidRequest)
+	}
+
+	return nil
+}
+
+// Create is a function that creates a key and returns it with the secret to give to the client
+func (s *service) Create(ctx context.Context, request CreateRequest) (Key, string, error) {
+	now := time.Now().UTC()
+
+	// We check the settings of the key
+	if err := s.validate(request, now); err != nil {
+		return Key{}, "", err
+	}
+
+	// We generate the id and the secret of the key
+	id, err := s.ids.NewID()
+	if err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][Create] error generating api key id", "error", err)
+		return Key{}, "", err
+	}
+	id = strings.ToLower(id)
+
+	secret, hash, err := newSecret()
+	if err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][Create] error generating api key secret", "error", err)
+		return Key{}, "", err
+	}
+
+	key := Key{
+		ID:        id,
+		Name:      strings.TrimSpace(request.Name),
+		Scopes:    append([]string{}, request.Scopes...),
+		CreatedAt: now,
+		Secrets:   []Secret{{Hash: hash, CreatedAt: now}},
+	}
+	if request.ExpiresAt != nil {
+		expiresAt := request.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+
+	// We call the store for create the key
+	if err := s.store.Create(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][Create] error creating api key", "error", err)
+		return Key{}, "", err
+	}
+
+	// We return the key and the secret, which is never shown again
+	return key.Redacted(), format(id, secret), nil
+}
+
+// List is a function that calls the store for return every key
+func (s *service) List(ctx context.Context) ([]Key, error) {
+	// We call the store for get the keys
+	list, err := s.store.List(ctx)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][List] error listing api keys", "error", err)
+		return nil, err
+	}
+
+	// We return the keys without the hashes
+	for i := range list {
+		list[i] = list[i].Redacted()
+	}
+	return list, nil
+}
+
+// Get is a function that calls the store for return a key by id
+func (s *service) Get(ctx context.Context, id string) (Key, error) {
+	// We call the store for get the key
+	key, err := s.store.Get(ctx, id)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][Get] error getting api key", "error", err)
+		return Key{}, err
+	}
+
+	// We return the key without the hashes
+	return key.Redacted(), nil
+}
+
+// Rotate is a function that gives a key a new secret. The previous secrets
+// keep working for the overlap, so the clients can switch without downtime.
+func (s *service) Rotate(ctx context.Context, id string, overlap time.Duration) (Key, string, error) {
+	if overlap < 0 || overlap > MaxOverlap {
+		return Key{}, "", fmt.Errorf("%w: overlap must be between 0 and %s", ErrInvalidRequest, MaxOverlap)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// We get the key to rotate
+	key, err := s.store.Get(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][Rotate] error getting api key", "error", err)
+		return Key{}, "", err
+	}
+
+	now := time.Now().UTC()
+	if !key.Active(now) {
+		return Key{}, "", fmt.Errorf("%w: the key is revoked or expired", ErrInvalidRequest)
+	}
+
+	secret, hash, err := newSecret()
+	if err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][Rotate] error generating api key secret", "error", err)
+		return Key{}, "", err
+	}
+
+	// The previous secrets expire at the end of the overlap, unless they do before.
+	// The ones already expired are dropped.
+	end := now.Add(overlap)
+	secrets := []Secret{{Hash: hash, CreatedAt: now}}
+	for _, previous := range key.Secrets {
+		if previous.ExpiresAt != nil && !now.Before(*previous.ExpiresAt) {
+			continue
+		}
+		if overlap == 0 {
+			continue
+		}
+		if previous.ExpiresAt == nil || end.Before(*previous.ExpiresAt) {
+			previous.ExpiresAt = &end
+		}
+		secrets = append(secrets, previous)
+	}
+	key.Secrets = secrets
+
+	// We call the store for update the key
+	if err := s.store.Update(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][Rotate] error updating api key", "error", err)
+		return Key{}, "", err
+	}
+
+	// We return the key and the new secret, which is never shown again
+	return key.Redacted(), format(id, secret), nil
+}
+
+// Revoke is a function that makes a key stop working at once, it's kept to
+// know who the subject of its requests was
+func (s *service) Revoke(ctx context.Context, id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// We get the key to revoke
+	key, err := s.store.Get(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "[APIKeysService][Revoke] error getting api key", "error", err)
+		return Key{}, err
+	}
+
+	// Revoking twice keeps the first time
+	if key.RevokedAt == nil {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+
+		if err := s.store.Update(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "[APIKeysService][Revoke] error updating api key", "error", err)
+			return Key{}, err
+		}
+	}
+
+	// We return the revoked key
+	return key.Redacted(), nil
+}
+
+// Authenticate is a function that returns the key of the secret given to a
+// client if it's active, and records that it was used
+func (s *service) Authenticate(ctx context.Context, secret string) (Key, error) {
+	id, value, err := parse(secret)
+	if err != nil {
+		return Key{}, err
+	}
+
+	key, err := s.store.Get(ctx, id)
+	if err != nil {
+		// An unknown id is just a wrong key
+		if errors.Is(err, ErrNotFound) {
+			return Key{}, ErrInvalidKey
+		}
+		slog.ErrorContext(ctx, "[APIKeysService][Authenticate] error getting api key", "error", err)
+		return Key{}, err
+	}
+
+	now := time.Now().UTC()
+	if !key.Active(now) || !key.match(value, now) {
+		return Key{}, ErrInvalidKey
+	}
+
+	// The last use is recorded once in a while, failing to do it doesn't reject the request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.store.Touch(ctx, id, now); err != nil {
+			slog.ErrorContext(ctx, "[APIKeysService][Authenticate] error recording api key use", "error", err)
+		}
+		key.LastUsedAt = &now
+	}
+
+	return key.Redacted(), nil
+}
+
+// NewKeyAuthenticator is a function that returns the authenticator of the api
+// keys for the auth policy. The subject of a request is apikey: and the id of the key.
+func NewKeyAuthenticator(service Service) auth.KeyAuthenticator {
+	return auth.KeyAuthenticatorFunc(func(ctx context.Context, secret string) (*auth.Claims, error) {
+		key, err := service.Authenticate(ctx, secret)
+		if errors.Is(err, ErrInvalidKey) {
+			return nil, fmt.Errorf("%w: %v", auth.ErrInvalidCredentials, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		claims := &auth.Claims{Scope: strings.Join(key.Scopes, " ")}
+		claims.Subject = "apikey:" + key.ID
+		return claims, nil
+	})
+}
@@ -0,0 +1,119 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testScopes are the scopes the keys of the tests can have
+var testScopes = []string{"products:read", "products:write"}
+
+func TestServiceCreateAndAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	service := NewServiceAPIKeys(NewMemoryStore(), testScopes)
+
+	for name, request := range map[string]CreateRequest{
+		"no name":       {Scopes: testScopes},
+		"no scopes":     {Name: "ci"},
+		"unknown scope": {Name: "ci", Scopes: []string{"admin"}},
+		"expired":       {Name: "ci", Scopes: testScopes, ExpiresAt: func() *time.Time { at := time.Now().Add(-time.Hour); return &at }()},
+	} {
+		if _, _, err := service.Create(ctx, request); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("create %s: got %v, want ErrInvalidRequest", name, err)
+		}
+	}
+
+	key, secret, err := service.Create(ctx, CreateRequest{Name: "ci", Scopes: []string{"products:read"}})
+	if err != nil || !strings.HasPrefix(secret, Prefix+key.ID+"_") || key.Secrets[0].Hash != "" {
+		t.Fatalf("create: got %+v, %q, %v", key, secret, err)
+	}
+
+	authenticated, err := service.Authenticate(ctx, secret)
+	if err != nil || authenticated.ID != key.ID || authenticated.LastUsedAt == nil {
+		t.Fatalf("authenticate: got %+v, %v", authenticated, err)
+	}
+
+	// The last use is recorded in the store
+	if stored, err := service.Get(ctx, key.ID); err != nil || stored.LastUsedAt == nil {
+		t.Fatalf("get after use: got %+v, %v", stored, err)
+	}
+
+	for name, wrong := range map[string]string{
+		"other secret": Prefix + key.ID + "_wrong",
+		"unknown id":   Prefix + "unknown_" + strings.TrimPrefix(secret, Prefix+key.ID+"_"),
+		"no prefix":    strings.TrimPrefix(secret, Prefix),
+		"empty":        "",
+	} {
+		if _, err := service.Authenticate(ctx, wrong); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("authenticate %s: got %v, want ErrInvalidKey", name, err)
+		}
+	}
+}
+
+func TestServiceRotateWithOverlap(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	service := NewServiceAPIKeys(store, testScopes)
+
+	key, first, err := service.Create(ctx, CreateRequest{Name: "ci", Scopes: testScopes})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Both secrets work during the overlap
+	rotated, second, err := service.Rotate(ctx, key.ID, time.Hour)
+	if err != nil || second == first || len(rotated.Secrets) != 2 || rotated.Secrets[1].ExpiresAt == nil {
+		t.Fatalf("rotate: got %+v, %v", rotated, err)
+	}
+	for _, secret := range []string{first, second} {
+		if _, err := service.Authenticate(ctx, secret); err != nil {
+			t.Fatalf("authenticate during the overlap: %v", err)
+		}
+	}
+
+	// We move the end of the overlap to the past
+	stored, _ := store.Get(ctx, key.ID)
+	past := time.Now().Add(-time.Second)
+	stored.Secrets[1].ExpiresAt = &past
+	if err := store.Update(ctx, stored); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if _, err := service.Authenticate(ctx, first); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("authenticate after the overlap: got %v, want ErrInvalidKey", err)
+	}
+	if _, err := service.Authenticate(ctx, second); err != nil {
+		t.Fatalf("authenticate new secret: %v", err)
+	}
+
+	// A rotation without overlap drops the previous secrets at once
+	rotated, third, err := service.Rotate(ctx, key.ID, 0)
+	if err != nil || len(rotated.Secrets) != 1 {
+		t.Fatalf("rotate without overlap: got %+v, %v", rotated, err)
+	}
+	if _, err := service.Authenticate(ctx, second); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("authenticate replaced secret: got %v, want ErrInvalidKey", err)
+	}
+
+	if _, _, err := service.Rotate(ctx, key.ID, MaxOverlap+time.Hour); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("rotate with a long overlap: got %v, want ErrInvalidRequest", err)
+	}
+
+	// A revoked key stops working and can't be rotated
+	revoked, err := service.Revoke(ctx, key.ID)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("revoke: got %+v, %v", revoked, err)
+	}
+	if _, err := service.Authenticate(ctx, third); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("authenticate revoked key: got %v, want ErrInvalidKey", err)
+	}
+	if _, _, err := service.Rotate(ctx, key.ID, time.Hour); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("rotate revoked key: got %v, want ErrInvalidRequest", err)
+	}
+	if _, err := service.Revoke(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("revoke missing: got %v, want ErrNotFound", err)
+	}
+}
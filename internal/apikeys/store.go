@@ -0,0 +1,149 @@
+package apikeys
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store represents a contract with all the functions that need to be implemented.
+// The names of the keys that are not revoked are unique, Create and Update
+// return ErrConflict for a name in use.
+type Store interface {
+	Create(ctx context.Context, key Key) error
+	Get(ctx context.Context, id string) (Key, error)
+	List(ctx context.Context) ([]Key, error)
+
+	// Update replaces every field of the key but LastUsedAt, which only Touch
+	// changes, so a key that was read before a request used it doesn't undo the use
+	Update(ctx context.Context, key Key) error
+
+	// Touch sets when the key was last used, unless it's already later
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+// memoryStore is a struct that contains the keys by id
+type memoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryStore is a function that returns an empty store in memory
+func NewMemoryStore() Store {
+	return &memoryStore{keys: map[string]Key{}}
+}
+
+// Create is a function that adds the key to the store
+func (s *memoryStore) Create(ctx context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.ID]; ok {
+		return ErrConflict
+	}
+	if s.nameTaken(key) {
+		return ErrConflict
+	}
+
+	s.keys[key.ID] = key
+	return nil
+}
+
+// nameTaken is a function that returns if another key that is not revoked
+// has the name of the key. It must be called with mu held.
+func (s *memoryStore) nameTaken(key Key) bool {
+	if key.RevokedAt != nil {
+		return false
+	}
+
+	for _, other := range s.keys {
+		if other.ID != key.ID && other.Name == key.Name && other.RevokedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Get is a function that returns a key by id
+func (s *memoryStore) Get(ctx context.Context, id string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrNotFound
+	}
+	return key, nil
+}
+
+// List is a function that returns every key sorted by creation
+func (s *memoryStore) List(ctx context.Context) ([]Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		list = append(list, key)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+
+	return list, nil
+}
+
+// Update is a function that replaces a key by id, but when it was last used
+func (s *memoryStore) Update(ctx context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.keys[key.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if s.nameTaken(key) {
+		return ErrConflict
+	}
+
+	key.LastUsedAt = stored.LastUsedAt
+	s.keys[key.ID] = key
+	return nil
+}
+
+// Touch is a function that sets when the key was last used
+func (s *memoryStore) Touch(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if key.LastUsedAt == nil || key.LastUsedAt.Before(at) {
+		key.LastUsedAt = &at
+		s.keys[id] = key
+	}
+	return nil
+}
+
+// put is a function that stores the key as it is
+func (s *memoryStore) put(key Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = key
+}
+
+// remove is a function that deletes a key by id
+func (s *memoryStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, id)
+}
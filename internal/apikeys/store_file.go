@@ -0,0 +1,137 @@
+package apikeys
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/filestore"
+)
+
+// FileStore is a memory store that persists the keys in a JSON file. Keys
+// change when they are rotated, revoked or used, so the whole file is
+// rewritten after every write.
+type FileStore struct {
+	memory *memoryStore
+	path   string
+
+	// mu serializes the writes so the file keeps the last state of the memory
+	mu sync.Mutex
+}
+
+// FileStore must keep implementing every method of Store
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore is a function that loads the keys of the file in path.
+// A missing file is an empty store.
+func NewFileStore(path string) (*FileStore, error) {
+	memory := NewMemoryStore().(*memoryStore)
+
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err == nil {
+		var keys []Key
+		if err := json.Unmarshal(content, &keys); err != nil {
+			return nil, fmt.Errorf("reading api keys %s: %w", path, err)
+		}
+		for _, key := range keys {
+			memory.keys[key.ID] = key
+		}
+	}
+
+	return &FileStore{memory: memory, path: path}, nil
+}
+
+// save is a function that writes every key in the file. It must be called with mu held.
+func (f *FileStore) save(ctx context.Context) error {
+	keys, err := f.memory.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	// Only the owner can read it, it has the hashes of the secrets
+	return filestore.WriteAtomic(f.path, content, 0o600)
+}
+
+// Create is a function that adds the key to the store and saves the file
+func (f *FileStore) Create(ctx context.Context, key Key) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.memory.Create(ctx, key); err != nil {
+		return err
+	}
+
+	// We undo the create if the file can't have it
+	if err := f.save(ctx); err != nil {
+		f.memory.remove(key.ID)
+		return err
+	}
+	return nil
+}
+
+// Get is a function that returns a key by id
+func (f *FileStore) Get(ctx context.Context, id string) (Key, error) {
+	return f.memory.Get(ctx, id)
+}
+
+// List is a function that returns every key sorted by creation
+func (f *FileStore) List(ctx context.Context) ([]Key, error) {
+	return f.memory.List(ctx)
+}
+
+// Update is a function that replaces a key by id, but when it was last used, and saves the file
+func (f *FileStore) Update(ctx context.Context, key Key) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, err := f.memory.Get(ctx, key.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := f.memory.Update(ctx, key); err != nil {
+		return err
+	}
+
+	// We undo the update if the file can't have it
+	if err := f.save(ctx); err != nil {
+		f.memory.put(previous)
+		return err
+	}
+	return nil
+}
+
+// Touch is a function that sets when the key was last used and saves the file
+func (f *FileStore) Touch(ctx context.Context, id string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, err := f.memory.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := f.memory.Touch(ctx, id, at); err != nil {
+		return err
+	}
+
+	// We undo the touch if the file can't have it
+	if err := f.save(ctx); err != nil {
+		f.memory.put(previous)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package apikeys
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+)
+
+// keyColumns is the list of columns that maps a Key, in the same order that scanKey reads them
+const keyColumns = "id, name, scopes, created_at, expires_at, last_used_at, revoked_at, secrets"
+
+// sqlStore is a struct that contains the sql connection of the api keys.
+// The schema is created by the internal/migrations package.
+type sqlStore struct {
+	db      *sql.DB
+	dialect products.Dialect
+}
+
+// NewSQLStore is a function that loads the sql connection into the store
+func NewSQLStore(db *sql.DB, dialect products.Dialect) Store {
+	return &sqlStore{db: db, dialect: dialect}
+}
+
+// scanKey is a function that reads a row with keyColumns into a Key
+func scanKey(row products.RowScanner) (Key, error) {
+	var key Key
+	var scopes, secrets string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&scopes,
+		&key.CreatedAt,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+		&secrets,
+	)
+	if err != nil {
+		return Key{}, err
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	key.ExpiresAt = nullTime(expiresAt)
+	key.LastUsedAt = nullTime(lastUsedAt)
+	key.RevokedAt = nullTime(revokedAt)
+
+	if err := json.Unmarshal([]byte(secrets), &key.Secrets); err != nil {
+		return Key{}, err
+	}
+
+	return key, nil
+}
+
+// nullTime is a function that returns the time of a nullable column, nil for NULL
+func nullTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	at := value.Time.UTC()
+	return &at
+}
+
+// timeArg is a function that returns the argument of a nullable time column
+func timeArg(at *time.Time) any {
+	if at == nil {
+		return nil
+	}
+	return at.UTC()
+}
+
+// args is a function that returns the values of keyColumns of the key
+func args(key Key) ([]any, error) {
+	secrets, err := json.Marshal(key.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{
+		key.ID,
+		key.Name,
+		strings.Join(key.Scopes, " "),
+		key.CreatedAt.UTC(),
+		timeArg(key.ExpiresAt),
+		timeArg(key.LastUsedAt),
+		timeArg(key.RevokedAt),
+		string(secrets),
+	}, nil
+}
+
+// Create is a function that inserts the key in the db
+func (s *sqlStore) Create(ctx context.Context, key Key) error {
+	values, err := args(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		s.dialect.Rebind("INSERT INTO api_keys ("+keyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		values...)
+	if _, ok := products.UniqueViolation(err); ok {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// Get is a function that returns a key by id
+func (s *sqlStore) Get(ctx context.Context, id string) (Key, error) {
+	row := s.db.QueryRowContext(ctx,
+		s.dialect.Rebind("SELECT "+keyColumns+" FROM api_keys WHERE id = ?"), id)
+
+	key, err := scanKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Key{}, ErrNotFound
+	}
+
+	return key, err
+}
+
+// List is a function that returns every key sorted by creation
+func (s *sqlStore) List(ctx context.Context) ([]Key, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+keyColumns+" FROM api_keys ORDER BY created_at, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Key{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, key)
+	}
+
+	return list, rows.Err()
+}
+
+// Update is a function that replaces a key by id, but when it was last used
+func (s *sqlStore) Update(ctx context.Context, key Key) error {
+	values, err := args(key)
+	if err != nil {
+		return err
+	}
+
+	// We don't change the id, it's the condition, nor the last use, only Touch moves it
+	result, err := s.db.ExecContext(ctx,
+		s.dialect.Rebind(`UPDATE api_keys SET name = ?, scopes = ?, created_at = ?, expires_at = ?,
+			revoked_at = ?, secrets = ? WHERE id = ?`),
+		values[1], values[2], values[3], values[4], values[6], values[7], key.ID)
+	if _, ok := products.UniqueViolation(err); ok {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Touch is a function that sets when the key was last used, unless it's already later
+func (s *sqlStore) Touch(ctx context.Context, id string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		s.dialect.Rebind("UPDATE api_keys SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)"),
+		at.UTC(), id, at.UTC())
+
+	return err
+}
@@ -0,0 +1,207 @@
+package apikeys
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/migrations/migrationstest"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+)
+
+// base is the creation time of the keys of the tests
+var base = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestKey is a function that returns a key created some minutes after base
+func newTestKey(id, name string, minute int) Key {
+	expiresAt := base.Add(24 * time.Hour)
+	return Key{
+		ID:        id,
+		Name:      name,
+		Scopes:    []string{"products:read", "products:write"},
+		CreatedAt: base.Add(time.Duration(minute) * time.Minute),
+		ExpiresAt: &expiresAt,
+		Secrets:   []Secret{{Hash: hashSecret("secret-" + id), CreatedAt: base}},
+	}
+}
+
+// testStoreContract checks the behaviour every Store implementation must share
+func testStoreContract(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	// We create out of order, the list is still sorted by creation
+	for _, key := range []Key{newTestKey("b", "deploy", 2), newTestKey("a", "ci", 1)} {
+		if err := store.Create(ctx, key); err != nil {
+			t.Fatalf("create %s: %v", key.ID, err)
+		}
+	}
+
+	if err := store.Create(ctx, newTestKey("a", "other", 3)); !errors.Is(err, ErrConflict) {
+		t.Fatalf("create existing id: got %v, want ErrConflict", err)
+	}
+	if err := store.Create(ctx, newTestKey("c", "ci", 3)); !errors.Is(err, ErrConflict) {
+		t.Fatalf("create existing name: got %v, want ErrConflict", err)
+	}
+
+	list, err := store.List(ctx)
+	if err != nil || len(list) != 2 || list[0].ID != "a" || list[1].ID != "b" {
+		t.Fatalf("list: got %+v, %v", list, err)
+	}
+
+	key, err := store.Get(ctx, "a")
+	if err != nil || key.Name != "ci" || len(key.Scopes) != 2 || key.Secrets[0].Hash != hashSecret("secret-a") ||
+		!key.CreatedAt.Equal(base.Add(time.Minute)) || key.ExpiresAt == nil || key.LastUsedAt != nil {
+		t.Fatalf("get: got %+v, %v", key, err)
+	}
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing: got %v, want ErrNotFound", err)
+	}
+
+	// A revoked key frees its name
+	revokedAt := base.Add(time.Hour)
+	key.RevokedAt = &revokedAt
+	if err := store.Update(ctx, key); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := store.Create(ctx, newTestKey("c", "ci", 3)); err != nil {
+		t.Fatalf("create name of a revoked key: %v", err)
+	}
+
+	renamed, _ := store.Get(ctx, "b")
+	renamed.Name = "ci"
+	if err := store.Update(ctx, renamed); !errors.Is(err, ErrConflict) {
+		t.Fatalf("update to a name in use: got %v, want ErrConflict", err)
+	}
+	if err := store.Update(ctx, newTestKey("missing", "missing", 4)); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update missing: got %v, want ErrNotFound", err)
+	}
+
+	// The last use only moves forward
+	later, earlier := base.Add(2*time.Hour), base.Add(time.Hour)
+	if err := store.Touch(ctx, "b", later); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	if err := store.Touch(ctx, "b", earlier); err != nil {
+		t.Fatalf("touch earlier: %v", err)
+	}
+
+	key, err = store.Get(ctx, "b")
+	if err != nil || key.LastUsedAt == nil || !key.LastUsedAt.Equal(later) {
+		t.Fatalf("get touched: got %+v, %v", key, err)
+	}
+
+	// An update of a key read before a use doesn't undo the use
+	stale := key
+	if err := store.Touch(ctx, "b", base.Add(3*time.Hour)); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	stale.Scopes = []string{"products:read"}
+	stale.LastUsedAt = nil
+	if err := store.Update(ctx, stale); err != nil {
+		t.Fatalf("update a stale key: %v", err)
+	}
+
+	key, err = store.Get(ctx, "b")
+	if err != nil || len(key.Scopes) != 1 || key.LastUsedAt == nil || !key.LastUsedAt.Equal(base.Add(3*time.Hour)) {
+		t.Fatalf("get after updating a stale key: got %+v, %v", key, err)
+	}
+
+	key, err = store.Get(ctx, "a")
+	if err != nil || key.RevokedAt == nil || !key.RevokedAt.Equal(revokedAt) {
+		t.Fatalf("get revoked: got %+v, %v", key, err)
+	}
+}
+
+// testStoreRotation checks that a store keeps the secrets of a rotation as
+// they were given: the new one first and the previous ones with the end of
+// the overlap. reopen returns the store as it's read after a restart.
+func testStoreRotation(t *testing.T, store Store, reopen func() Store) {
+	ctx := context.Background()
+
+	key := newTestKey("r", "rotated", 1)
+	if err := store.Create(ctx, key); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	end := base.Add(2 * time.Hour)
+	previous := key.Secrets[0]
+	previous.ExpiresAt = &end
+	key.Secrets = []Secret{{Hash: hashSecret("rotated"), CreatedAt: base.Add(time.Hour)}, previous}
+	if err := store.Update(ctx, key); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+
+	for name, store := range map[string]Store{"store": store, "reopened": reopen()} {
+		got, err := store.Get(ctx, "r")
+		if err != nil || len(got.Secrets) != 2 {
+			t.Fatalf("%s: got %+v, %v", name, got, err)
+		}
+
+		current, old := got.Secrets[0], got.Secrets[1]
+		if current.Hash != hashSecret("rotated") || current.ExpiresAt != nil || !current.CreatedAt.Equal(base.Add(time.Hour)) {
+			t.Fatalf("%s: got current secret %+v", name, current)
+		}
+		if old.Hash != hashSecret("secret-r") || old.ExpiresAt == nil || !old.ExpiresAt.Equal(end) {
+			t.Fatalf("%s: got previous secret %+v", name, old)
+		}
+
+		// Both secrets work during the overlap, only the new one after it
+		if !got.match("rotated", end.Add(time.Minute)) || !got.match("secret-r", end.Add(-time.Minute)) || got.match("secret-r", end) {
+			t.Fatalf("%s: the secrets don't match in their time", name)
+		}
+	}
+}
+
+func TestMemoryStoreContract(t *testing.T) {
+	testStoreContract(t, NewMemoryStore())
+}
+
+func TestFileStoreContract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json.apikeys")
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+	testStoreContract(t, store)
+
+	// The keys are still there after reopening the file
+	store, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen file store: %v", err)
+	}
+
+	if list, err := store.List(context.Background()); err != nil || len(list) != 3 || list[1].LastUsedAt == nil {
+		t.Fatalf("list after reopening: got %+v, %v", list, err)
+	}
+}
+
+func TestSQLiteStoreContract(t *testing.T) {
+	db := migrationstest.OpenSQLite(t)
+
+	testStoreContract(t, NewSQLStore(db, products.DialectSQLite))
+}
+
+func TestStoreRotation(t *testing.T) {
+	memory := NewMemoryStore()
+	testStoreRotation(t, memory, func() Store { return memory })
+
+	path := filepath.Join(t.TempDir(), "products.json.apikeys")
+	file, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+	testStoreRotation(t, file, func() Store {
+		reopened, err := NewFileStore(path)
+		if err != nil {
+			t.Fatalf("reopen file store: %v", err)
+		}
+		return reopened
+	})
+
+	db := migrationstest.OpenSQLite(t)
+	testStoreRotation(t, NewSQLStore(db, products.DialectSQLite), func() Store { return NewSQLStore(db, products.DialectSQLite) })
+}
@@ -0,0 +1,119 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/identity"
+	"github.com/burgosfacundo/ApiGo.git/pkg/requestinfo"
+)
+
+// Limits of the entries returned by a query
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// ErrConflict is returned when an entry with the same id exists
+var ErrConflict = errors.New("audit entry already exists")
+
+// Entry is a struct that represents a write of a product: who did it, when,
+// from where and the product before and after it.
+// Before is nil for a create, After keeps the product a delete trashed and
+// it's nil for a purge.
+type Entry struct {
+	ID        string          `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`
+	IP        string          `json:"ip"`
+	Action    string          `json:"action"`
+	ProductID string          `json:"product_id"`
+	Before    *domain.Product `json:"before"`
+	After     *domain.Product `json:"after"`
+}
+
+// Query is a struct that contains the conditions of the entries to return.
+// The zero value of each field means no condition, From is inclusive and To
+// is exclusive.
+type Query struct {
+	Actor     string
+	ProductID string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+// Normalize is a function that applies the defaults of the query and checks it.
+// The errors wrap products.ErrInvalidQuery, like the ones of the listings.
+func (q Query) Normalize() (Query, error) {
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+
+	if q.Limit < 1 || q.Limit > MaxLimit {
+		return Query{}, fmt.Errorf("%w: limit must be between 1 and %d", products.ErrInvalidQuery, MaxLimit)
+	}
+
+	if q.Offset < 0 {
+		return Query{}, fmt.Errorf("%w: offset must not be negative", products.ErrInvalidQuery)
+	}
+
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return Query{}, fmt.Errorf("%w: the time range is empty", products.ErrInvalidQuery)
+	}
+
+	return q, nil
+}
+
+// matches is a function that returns if the entry meets the conditions of the query
+func (q Query) matches(entry Entry) bool {
+	switch {
+	case q.Actor != "" && entry.Actor != q.Actor:
+		return false
+	case q.ProductID != "" && entry.ProductID != q.ProductID:
+		return false
+	case q.From != nil && entry.CreatedAt.Before(*q.From):
+		return false
+	case q.To != nil && !entry.CreatedAt.Before(*q.To):
+		return false
+	}
+	return true
+}
+
+// NewRecorder is a function that returns a listener of the products service
+// that appends an entry for every write, the purges included, by the subject
+// and from the client ip of the request.
+//
+// The log is best-effort: the entry is appended after the write, so when the
+// store fails the write is kept and the error is only logged by the service.
+func NewRecorder(store Store) products.Listener {
+	ids := products.NewULIDGenerator()
+
+	return products.ListenerFunc(func(ctx context.Context, change products.Change) error {
+		id, err := ids.NewID()
+		if err != nil {
+			return err
+		}
+
+		after := &change.Product
+		if change.Action == products.ActionPurge {
+			after = nil
+		}
+
+		return store.Append(ctx, Entry{
+			ID:        id,
+			CreatedAt: time.Now().UTC(),
+			Actor:     identity.Subject(ctx),
+			IP:        requestinfo.ClientIP(ctx),
+			Action:    change.Action,
+			ProductID: change.Product.Id,
+			Before:    change.Previous,
+			After:     after,
+		})
+	})
+}
@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Service represents a contract with all the functions that need to be implemented
+type Service interface {
+	Query(ctx context.Context, query Query) ([]Entry, error)
+}
+
+// service is a struct that contains the store of the audit log
+type service struct {
+	store Store
+}
+
+// NewServiceAudit is a function that loads the store into the service
+func NewServiceAudit(store Store) Service {
+	return &service{store: store}
+}
+
+// Query is a function that calls the store for return a page of the entries that meet the query
+func (s *service) Query(ctx context.Context, query Query) ([]Entry, error) {
+	// We call the store for get the entries
+	list, err := s.store.Query(ctx, query)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[AuditService][Query] error querying audit entries", "error", err)
+		return nil, err
+	}
+
+	// We return the entries
+	return list, nil
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"sync"
+)
+
+// Store represents a contract with all the functions that need to be implemented.
+// The log is append-only: entries are never changed or removed and Append
+// returns ErrConflict for an id that exists. Query returns the entries from
+// the oldest to the newest.
+type Store interface {
+	Append(ctx context.Context, entry Entry) error
+	Query(ctx context.Context, query Query) ([]Entry, error)
+}
+
+// memoryStore is a struct that contains the entries in the order they were appended
+type memoryStore struct {
+	mu      sync.RWMutex
+	entries []Entry
+	ids     map[string]struct{}
+}
+
+// NewMemoryStore is a function that returns an empty store in memory
+func NewMemoryStore() Store {
+	return &memoryStore{ids: map[string]struct{}{}}
+}
+
+// Append is a function that adds the entry to the store
+func (s *memoryStore) Append(ctx context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.add(entry)
+}
+
+// add keeps the entries sorted by time and id. It must be called with mu held.
+func (s *memoryStore) add(entry Entry) error {
+	if _, ok := s.ids[entry.ID]; ok {
+		return ErrConflict
+	}
+
+	// We search from the end, the entries almost always arrive in order
+	key := len(s.entries)
+	for key > 0 && entryLess(entry, s.entries[key-1]) {
+		key--
+	}
+
+	s.entries = append(s.entries, Entry{})
+	copy(s.entries[key+1:], s.entries[key:])
+	s.entries[key] = entry
+	s.ids[entry.ID] = struct{}{}
+
+	return nil
+}
+
+// entryLess is a function that returns if a goes before b in the log
+func entryLess(a, b Entry) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// Query is a function that returns a page of the entries that meet the query
+func (s *memoryStore) Query(ctx context.Context, query Query) ([]Entry, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := []Entry{}
+	skipped := 0
+	for _, entry := range s.entries {
+		if !query.matches(entry) {
+			continue
+		}
+		if skipped < query.Offset {
+			skipped++
+			continue
+		}
+		list = append(list, entry)
+		if len(list) == query.Limit {
+			break
+		}
+	}
+
+	return list, nil
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/filestore"
+)
+
+// FileStore is a memory store that persists every entry in an append-only
+// JSONL file. Entries never change, so the file is never rewritten.
+type FileStore struct {
+	memory *memoryStore
+
+	// mu serializes the appends so the file keeps the order of the memory
+	mu  sync.Mutex
+	log *filestore.Log
+}
+
+// FileStore must keep implementing every method of Store
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore is a function that loads the entries of the file in path
+// and opens it to append the new ones. A missing file is an empty store.
+// Only the owner can read the file, it holds the ips of the clients.
+func NewFileStore(path string) (*FileStore, error) {
+	memory := NewMemoryStore().(*memoryStore)
+
+	log, err := filestore.OpenLog(path, 0o600, func(entry Entry) error {
+		// An entry appended twice is the same entry
+		if err := memory.add(entry); err != nil && !errors.Is(err, ErrConflict) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{memory: memory, log: log}, nil
+}
+
+// Append is a function that writes the entry at the end of the file,
+// syncs it and adds it to the store
+func (f *FileStore) Append(ctx context.Context, entry Entry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.memory.mu.RLock()
+	_, exists := f.memory.ids[entry.ID]
+	f.memory.mu.RUnlock()
+	if exists {
+		return ErrConflict
+	}
+
+	if err := f.log.Append(entry); err != nil {
+		return err
+	}
+
+	return f.memory.Append(ctx, entry)
+}
+
+// Query is a function that returns a page of the entries that meet the query
+func (f *FileStore) Query(ctx context.Context, query Query) ([]Entry, error) {
+	return f.memory.Query(ctx, query)
+}
+
+// Close is a function that closes the file
+func (f *FileStore) Close() error {
+	return f.log.Close()
+}
@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+)
+
+// entryColumns is the list of columns that maps an Entry, in the same
+// order that scanEntry reads them
+const entryColumns = "id, created_at, actor, ip, action, product_id, before_state, after_state"
+
+// sqlStore is a struct that contains the sql connection of the audit log.
+// The schema is created by the internal/migrations package, which also makes
+// the table reject updates and deletes.
+type sqlStore struct {
+	db      *sql.DB
+	dialect products.Dialect
+}
+
+// NewSQLStore is a function that loads the sql connection into the store
+func NewSQLStore(db *sql.DB, dialect products.Dialect) Store {
+	return &sqlStore{db: db, dialect: dialect}
+}
+
+// scanEntry is a function that reads a row with entryColumns into an Entry
+func scanEntry(row products.RowScanner) (Entry, error) {
+	var entry Entry
+	var before, after sql.NullString
+
+	err := row.Scan(
+		&entry.ID,
+		&entry.CreatedAt,
+		&entry.Actor,
+		&entry.IP,
+		&entry.Action,
+		&entry.ProductID,
+		&before,
+		&after,
+	)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if entry.Before, err = decodeProduct(before); err != nil {
+		return Entry{}, err
+	}
+	if entry.After, err = decodeProduct(after); err != nil {
+		return Entry{}, err
+	}
+
+	return entry, nil
+}
+
+// encodeProduct is a function that returns the json of the product, NULL for nil
+func encodeProduct(product *domain.Product) (sql.NullString, error) {
+	if product == nil {
+		return sql.NullString{}, nil
+	}
+
+	content, err := json.Marshal(product)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(content), Valid: true}, nil
+}
+
+// decodeProduct is a function that reads the json of a product, nil for NULL
+func decodeProduct(content sql.NullString) (*domain.Product, error) {
+	if !content.Valid {
+		return nil, nil
+	}
+
+	var product domain.Product
+	if err := json.Unmarshal([]byte(content.String), &product); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// Append is a function that inserts the entry in the db
+func (s *sqlStore) Append(ctx context.Context, entry Entry) error {
+	before, err := encodeProduct(entry.Before)
+	if err != nil {
+		return err
+	}
+
+	after, err := encodeProduct(entry.After)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		s.dialect.Rebind("INSERT INTO audit_entries ("+entryColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		entry.ID,
+		entry.CreatedAt.UTC(),
+		entry.Actor,
+		entry.IP,
+		entry.Action,
+		entry.ProductID,
+		before,
+		after,
+	)
+	if _, ok := products.UniqueViolation(err); ok {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// Query is a function that returns a page of the entries that meet the query
+func (s *sqlStore) Query(ctx context.Context, query Query) ([]Entry, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	// We build the conditions of the query
+	conditions := []string{}
+	args := []any{}
+	if query.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, query.Actor)
+	}
+	if query.ProductID != "" {
+		conditions = append(conditions, "product_id = ?")
+		args = append(args, query.ProductID)
+	}
+	if query.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, query.From.UTC())
+	}
+	if query.To != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, query.To.UTC())
+	}
+
+	statement := "SELECT " + entryColumns + " FROM audit_entries"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	statement += " ORDER BY created_at, id LIMIT ? OFFSET ?"
+	args = append(args, query.Limit, query.Offset)
+
+	rows, err := s.db.QueryContext(ctx, s.dialect.Rebind(statement), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Entry{}
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, entry)
+	}
+
+	return list, rows.Err()
+}
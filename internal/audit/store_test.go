@@ -0,0 +1,292 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/migrations/migrationstest"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/identity"
+	"github.com/burgosfacundo/ApiGo.git/pkg/requestinfo"
+)
+
+// base is the time of the first entry of the tests
+var base = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// newTestEntry is a function that returns an update of a product by an actor
+func newTestEntry(id string, minute int, actor, productID string) Entry {
+	before := domain.Product{Id: productID, Name: "before", Version: 1}
+	after := domain.Product{Id: productID, Name: "after", Version: 2}
+
+	return Entry{
+		ID:        id,
+		CreatedAt: base.Add(time.Duration(minute) * time.Minute),
+		Actor:     actor,
+		IP:        "10.0.0.1",
+		Action:    products.ActionUpdate,
+		ProductID: productID,
+		Before:    &before,
+		After:     &after,
+	}
+}
+
+// ids is a function that returns the ids of the entries
+func ids(entries []Entry) []string {
+	list := []string{}
+	for _, entry := range entries {
+		list = append(list, entry.ID)
+	}
+	return list
+}
+
+// testStoreContract checks the behaviour every Store implementation must share
+func testStoreContract(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	// We append out of order, the entries are still sorted by time
+	entries := []Entry{
+		newTestEntry("b", 2, "alice", "1"),
+		newTestEntry("a", 1, "bob", "1"),
+		newTestEntry("c", 3, "alice", "2"),
+	}
+	created := newTestEntry("d", 4, "bob", "2")
+	created.Action, created.Before = products.ActionCreate, nil
+	entries = append(entries, created)
+
+	for _, entry := range entries {
+		if err := store.Append(ctx, entry); err != nil {
+			t.Fatalf("append %s: %v", entry.ID, err)
+		}
+	}
+
+	if err := store.Append(ctx, newTestEntry("a", 5, "bob", "1")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("append existing id: got %v, want ErrConflict", err)
+	}
+
+	list, err := store.Query(ctx, Query{})
+	if err != nil || len(list) != 4 || list[0].ID != "a" || list[3].ID != "d" {
+		t.Fatalf("query all: got %v, %v", ids(list), err)
+	}
+	if list[0].Before.Name != "before" || list[0].After.Name != "after" || list[0].IP != "10.0.0.1" || !list[0].CreatedAt.Equal(base.Add(time.Minute)) {
+		t.Fatalf("query all: got %+v", list[0])
+	}
+	if list[3].Before != nil || list[3].After == nil {
+		t.Fatalf("create entry: got before %v, after %v", list[3].Before, list[3].After)
+	}
+
+	from, to := base.Add(2*time.Minute), base.Add(4*time.Minute)
+	cases := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{name: "actor", query: Query{Actor: "alice"}, want: []string{"b", "c"}},
+		{name: "product", query: Query{ProductID: "2"}, want: []string{"c", "d"}},
+		{name: "range", query: Query{From: &from, To: &to}, want: []string{"b", "c"}},
+		{name: "actor and product", query: Query{Actor: "bob", ProductID: "1"}, want: []string{"a"}},
+		{name: "page", query: Query{Limit: 2, Offset: 1}, want: []string{"b", "c"}},
+		{name: "nothing", query: Query{Actor: "carol"}, want: []string{}},
+	}
+	for _, c := range cases {
+		list, err := store.Query(ctx, c.query)
+		if err != nil || len(list) != len(c.want) {
+			t.Fatalf("query %s: got %v, %v, want %v", c.name, ids(list), err, c.want)
+		}
+		for i := range list {
+			if list[i].ID != c.want[i] {
+				t.Fatalf("query %s: got %v, want %v", c.name, ids(list), c.want)
+			}
+		}
+	}
+
+	if _, err := store.Query(ctx, Query{From: &to, To: &from}); !errors.Is(err, products.ErrInvalidQuery) {
+		t.Fatalf("query empty range: got %v, want ErrInvalidQuery", err)
+	}
+}
+
+func TestMemoryStoreContract(t *testing.T) {
+	testStoreContract(t, NewMemoryStore())
+}
+
+func TestFileStoreContract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json.audit")
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+	testStoreContract(t, store)
+	store.Close()
+
+	// The entries are still there after reopening the file
+	store, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen file store: %v", err)
+	}
+	defer store.Close()
+
+	if list, err := store.Query(context.Background(), Query{}); err != nil || len(list) != 4 {
+		t.Fatalf("query after reopening: got %v, %v", ids(list), err)
+	}
+}
+
+func TestFileStoreAfterCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json.audit")
+
+	// The log was cut in the middle of the entry after "a"
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+	if err := store.Append(context.Background(), newTestEntry("a", 1, "alice", "1")); err != nil {
+		t.Fatalf("append a: %v", err)
+	}
+	store.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if err := os.WriteFile(path, append(content, `{"id":"b","created_at":`...), 0o600); err != nil {
+		t.Fatalf("write torn entry: %v", err)
+	}
+
+	for _, id := range []string{"c", "d"} {
+		store, err := NewFileStore(path)
+		if err != nil {
+			t.Fatalf("reopen before %s: %v", id, err)
+		}
+		err = store.Append(context.Background(), newTestEntry(id, 2, "alice", "1"))
+		store.Close()
+		if err != nil {
+			t.Fatalf("append %s: %v", id, err)
+		}
+	}
+
+	store, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+
+	if list, err := store.Query(context.Background(), Query{}); err != nil || len(list) != 3 || list[1].ID != "c" || list[2].ID != "d" {
+		t.Fatalf("query: got %v, %v, want [a c d]", ids(list), err)
+	}
+
+	// The log keeps the ips of the clients, only the owner can read it
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("got mode %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestSQLiteStoreContract(t *testing.T) {
+	db := migrationstest.OpenSQLite(t)
+
+	testStoreContract(t, NewSQLStore(db, products.DialectSQLite))
+
+	// The table rejects changing the log
+	if _, err := db.Exec("UPDATE audit_entries SET actor = 'mallory'"); err == nil {
+		t.Fatal("update: the audit entries were changed")
+	}
+	if _, err := db.Exec("DELETE FROM audit_entries"); err == nil {
+		t.Fatal("delete: the audit entries were removed")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	store := NewMemoryStore()
+	repository := products.NewMemoryRepository(nil)
+	service := products.NewServiceProduct(repository, products.WithListener(NewRecorder(store)))
+
+	ctx := requestinfo.WithClientIP(identity.WithSubject(context.Background(), "alice"), "192.0.2.7")
+
+	product, err := service.Create(ctx, domain.Product{
+		Name:       "Coco Cola",
+		Quantity:   10,
+		CodeValue:  "123456789",
+		Expiration: time.Now().Add(24 * time.Hour),
+		Price:      10.5,
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	product.Name = "Coco Cola Zero"
+	product.Version = 0
+	if _, err := service.Update(ctx, product, product.Id); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if err := service.Delete(ctx, product.Id, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	list, err := store.Query(context.Background(), Query{ProductID: product.Id})
+	if err != nil || len(list) != 3 {
+		t.Fatalf("query: got %v, %v", ids(list), err)
+	}
+
+	create, update, remove := list[0], list[1], list[2]
+	if create.Action != products.ActionCreate || create.Before != nil || create.After.Name != "Coco Cola" {
+		t.Fatalf("create entry: got %+v", create)
+	}
+	if update.Actor != "alice" || update.IP != "192.0.2.7" || update.Before.Name != "Coco Cola" || update.After.Name != "Coco Cola Zero" {
+		t.Fatalf("update entry: got %+v", update)
+	}
+	if remove.Action != products.ActionDelete || remove.Before.DeletedAt != nil || remove.After.DeletedAt == nil {
+		t.Fatalf("delete entry: got %+v", remove)
+	}
+
+	// The purge job is recorded as the system, the product is gone after it
+	system := identity.WithSubject(context.Background(), identity.System)
+	if purged, err := service.Purge(system, time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("purge: got %d, %v", purged, err)
+	}
+
+	list, err = store.Query(context.Background(), Query{ProductID: product.Id, Actor: identity.System})
+	if err != nil || len(list) != 1 {
+		t.Fatalf("query purge: got %v, %v", ids(list), err)
+	}
+	if purge := list[0]; purge.Action != products.ActionPurge || purge.Before == nil || purge.Before.DeletedAt == nil || purge.After != nil {
+		t.Fatalf("purge entry: got %+v", purge)
+	}
+}
+
+// failingStore is a Store that can't append
+type failingStore struct {
+	Store
+}
+
+// Append is a function that returns an error
+func (failingStore) Append(ctx context.Context, entry Entry) error {
+	return errors.New("disk full")
+}
+
+func TestRecorderIsBestEffort(t *testing.T) {
+	repository := products.NewMemoryRepository(nil)
+	service := products.NewServiceProduct(repository, products.WithListener(NewRecorder(failingStore{NewMemoryStore()})))
+
+	// The write is kept when its entry can't be appended
+	product, err := service.Create(context.Background(), domain.Product{
+		Name:       "Coco Cola",
+		Quantity:   10,
+		CodeValue:  "123456789",
+		Expiration: time.Now().Add(24 * time.Hour),
+		Price:      10.5,
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := repository.GetByID(context.Background(), product.Id); err != nil {
+		t.Fatalf("get created: %v", err)
+	}
+}
@@ -2,13 +2,24 @@ package domain
 
 import "time"
 
-// Product is a struct that represents a product in the db
+// Product is a struct that represents a product in the db.
+// The validate tags are the rules checked by Validate.
 type Product struct {
 	Id          string    `json:"id"`
-	Name        string    `json:"name"`
-	Quantity    int       `json:"quantity"`
-	CodeValue   string    `json:"code_value"`
+	Name        string    `json:"name" validate:"required,max=100"`
+	Quantity    int       `json:"quantity" validate:"gte=0"`
+	CodeValue   string    `json:"code_value" validate:"required,codevalue"`
 	IsPublished bool      `json:"is_published"`
-	Expiration  time.Time `json:"expiration"`
-	Price       float64   `json:"price"`
+	Expiration  time.Time `json:"expiration" validate:"required"`
+	Price       float64   `json:"price" validate:"gt=0"`
+
+	// Version is the revision of the product, the repository sets it to 1
+	// on create and increments it on every update
+	Version int64 `json:"version"`
+
+	// UpdatedAt is when the repository stored the current version
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// DeletedAt is when the product was moved to the trash, nil if it wasn't
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrValidation is matched by errors.Is for every ValidationError
+var ErrValidation = errors.New("invalid product")
+
+// codeValuePattern is a well formed code value: 4 to 64 letters or digits,
+// optionally separated by single dashes
+var codeValuePattern = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*$`)
+
+// validate is the validator of the validate tags of the domain structs
+var validate = newValidator()
+
+// FieldError is a struct that represents a rule broken by a field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a product breaks one or more rules
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error returns the messages of every field joined
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldError := range e.Errors {
+		messages = append(messages, fieldError.Message)
+	}
+	return "invalid product: " + strings.Join(messages, ", ")
+}
+
+// Is makes errors.Is match ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// newValidator builds a validator that names the fields by their json tag
+// and knows the codevalue rule
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	v.RegisterValidation("codevalue", func(field validator.FieldLevel) bool {
+		code := field.Field().String()
+		return len(code) >= 4 && len(code) <= 64 && codeValuePattern.MatchString(code)
+	})
+
+	return v
+}
+
+// Validate is a function that checks the rules of the validate tags of the product
+func (p Product) Validate() error {
+	err := validate.Struct(p)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	result := &ValidationError{}
+	for _, fieldError := range validationErrors {
+		result.Errors = append(result.Errors, FieldError{
+			Field:   fieldError.Field(),
+			Rule:    fieldError.Tag(),
+			Message: fieldMessage(fieldError),
+		})
+	}
+
+	return result
+}
+
+// ValidateNew is a function that checks the rules of a product that is
+// going to be created: the ones of Validate plus an expiration not before now
+func (p Product) ValidateNew(now time.Time) error {
+	err := p.Validate()
+
+	var result *ValidationError
+	if err != nil && !errors.As(err, &result) {
+		return err
+	}
+
+	if !p.Expiration.IsZero() && p.Expiration.Before(now) {
+		if result == nil {
+			result = &ValidationError{}
+		}
+		result.Errors = append(result.Errors, FieldError{
+			Field:   "expiration",
+			Rule:    "future",
+			Message: "expiration must not be in the past",
+		})
+	}
+
+	if result == nil {
+		return nil
+	}
+	return result
+}
+
+// fieldMessage returns a message for the broken rule that can be shown to the user
+func fieldMessage(fieldError validator.FieldError) string {
+	field := fieldError.Field()
+
+	switch fieldError.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is required", field)
+	case "max":
+		return fmt.Sprintf("%s must be at most %s characters", field, fieldError.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, fieldError.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fieldError.Param())
+	case "codevalue":
+		return fmt.Sprintf("%s must have 4 to 64 letters or digits, optionally separated by dashes", field)
+	default:
+		return fmt.Sprintf("%s is invalid", field)
+	}
+}
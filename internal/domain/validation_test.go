@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// now is the time the products of the tests are validated at
+var now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// validProduct is a function that returns a product that breaks no rule
+func validProduct() Product {
+	return Product{
+		Name:       "Coco Cola",
+		Quantity:   10,
+		CodeValue:  "ABC-123",
+		Expiration: now.Add(24 * time.Hour),
+		Price:      10.5,
+	}
+}
+
+// rules is a function that returns the field and rule of every error of err
+func rules(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+
+	var validationError *ValidationError
+	if !errors.As(err, &validationError) || !errors.Is(err, ErrValidation) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+
+	result := []string{}
+	for _, fieldError := range validationError.Errors {
+		if fieldError.Message == "" || !strings.Contains(err.Error(), fieldError.Message) {
+			t.Fatalf("%s: got message %q in %q", fieldError.Field, fieldError.Message, err)
+		}
+		result = append(result, fieldError.Field+":"+fieldError.Rule)
+	}
+	return result
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(p *Product)
+		want   []string
+	}{
+		{name: "valid", change: func(p *Product) {}},
+		{name: "no name", change: func(p *Product) { p.Name = "" }, want: []string{"name:required"}},
+		{name: "name of 100 characters", change: func(p *Product) { p.Name = strings.Repeat("ñ", 100) }},
+		{name: "name of 101 characters", change: func(p *Product) { p.Name = strings.Repeat("a", 101) }, want: []string{"name:max"}},
+		{name: "no quantity", change: func(p *Product) { p.Quantity = 0 }},
+		{name: "negative quantity", change: func(p *Product) { p.Quantity = -1 }, want: []string{"quantity:gte"}},
+		{name: "no code", change: func(p *Product) { p.CodeValue = "" }, want: []string{"code_value:required"}},
+		{name: "code of 4 characters", change: func(p *Product) { p.CodeValue = "abc1" }},
+		{name: "code of 3 characters", change: func(p *Product) { p.CodeValue = "abc" }, want: []string{"code_value:codevalue"}},
+		{name: "code of 64 characters", change: func(p *Product) { p.CodeValue = strings.Repeat("a", 64) }},
+		{name: "code of 65 characters", change: func(p *Product) { p.CodeValue = strings.Repeat("a", 65) }, want: []string{"code_value:codevalue"}},
+		{name: "code with dashes", change: func(p *Product) { p.CodeValue = "a-b-c-d" }},
+		{name: "code with a double dash", change: func(p *Product) { p.CodeValue = "ab--cd" }, want: []string{"code_value:codevalue"}},
+		{name: "code ending in a dash", change: func(p *Product) { p.CodeValue = "abcd-" }, want: []string{"code_value:codevalue"}},
+		{name: "code with a space", change: func(p *Product) { p.CodeValue = "ab cd" }, want: []string{"code_value:codevalue"}},
+		{name: "no expiration", change: func(p *Product) { p.Expiration = time.Time{} }, want: []string{"expiration:required"}},
+		{name: "expiration in the past", change: func(p *Product) { p.Expiration = now.Add(-time.Hour) }},
+		{name: "no price", change: func(p *Product) { p.Price = 0 }, want: []string{"price:gt"}},
+		{name: "negative price", change: func(p *Product) { p.Price = -1 }, want: []string{"price:gt"}},
+		{
+			name:   "several rules",
+			change: func(p *Product) { p.Name, p.Quantity, p.Price = "", -1, 0 },
+			want:   []string{"name:required", "quantity:gte", "price:gt"},
+		},
+	}
+	for _, c := range cases {
+		product := validProduct()
+		c.change(&product)
+
+		if got := rules(t, product.Validate()); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestValidateNew(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(p *Product)
+		want   []string
+	}{
+		{name: "valid", change: func(p *Product) {}},
+		{name: "expiration now", change: func(p *Product) { p.Expiration = now }},
+		{name: "expiration in the past", change: func(p *Product) { p.Expiration = now.Add(-time.Second) }, want: []string{"expiration:future"}},
+		// A missing expiration is only required, not also in the past
+		{name: "no expiration", change: func(p *Product) { p.Expiration = time.Time{} }, want: []string{"expiration:required"}},
+		{
+			name:   "rules of Validate and expiration in the past",
+			change: func(p *Product) { p.Price, p.Expiration = 0, now.Add(-time.Hour) },
+			want:   []string{"price:gt", "expiration:future"},
+		},
+	}
+	for _, c := range cases {
+		product := validProduct()
+		c.change(&product)
+
+		if got := rules(t, product.ValidateNew(now)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
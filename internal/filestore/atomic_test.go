@@ -0,0 +1,34 @@
+package filestore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.json")
+
+	for _, content := range []string{"[1]", "[1,2]"} {
+		if err := WriteAtomic(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", content, err)
+		}
+		if got, err := os.ReadFile(path); err != nil || string(got) != content {
+			t.Fatalf("got %q, %v, want %s", got, err, content)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Fatalf("got mode %v, want 0644", info.Mode().Perm())
+	}
+
+	// The temporary files are gone
+	if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Fatalf("got %d files in the directory, %v", len(entries), err)
+	}
+}
@@ -0,0 +1,166 @@
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Log is a struct that contains an append-only JSONL file, one record per
+// line. Every record is synced before Append returns, so a record that was
+// acknowledged survives a crash.
+type Log struct {
+	// mu serializes the appends so the lines are never interleaved
+	mu   sync.Mutex
+	file logFile
+
+	// size is the length of the complete records of the file
+	size int64
+}
+
+// logFile is the part of *os.File used by a Log
+type logFile interface {
+	io.WriteCloser
+	Sync() error
+	Truncate(size int64) error
+}
+
+// OpenLog is a function that calls read with every record of the file in
+// path, in order, and opens it to append the new ones with perm. A missing
+// file is created empty.
+//
+// A last line without a newline is an append that was interrupted by a
+// crash, and therefore never acknowledged. It's not read, and it's cut from
+// the file before opening it, otherwise the next record would be glued to it.
+func OpenLog[T any](path string, perm os.FileMode, read func(record T) error) (*Log, error) {
+	size, complete, err := readLog(path, read)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	if complete < size {
+		slog.Warn("[Log][OpenLog] cutting a torn last line", "path", path, "bytes", size-complete)
+
+		if err := file.Truncate(complete); err != nil {
+			file.Close()
+			return nil, err
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &Log{file: file, size: complete}, nil
+}
+
+// readLog is a function that calls read with every complete line of the file
+// in path. It returns the size of the file and the size of its complete lines.
+func readLog[T any](path string, read func(record T) error) (size int64, complete int64, err error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		content, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return complete + int64(len(content)), complete, nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+
+		var record T
+		if err := json.Unmarshal(bytes.TrimSpace(content), &record); err != nil {
+			return 0, 0, fmt.Errorf("reading %s line %d: %w", path, line, err)
+		}
+
+		if err := read(record); err != nil {
+			return 0, 0, fmt.Errorf("reading %s line %d: %w", path, line, err)
+		}
+
+		complete += int64(len(content))
+	}
+}
+
+// Append is a function that writes the record at the end of the file and
+// syncs it. If the write or the sync fails the file is cut back to the
+// previous record, so a failed append never leaves part of a line behind.
+func (l *Log) Append(record any) error {
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	content = append(content, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(content); err != nil {
+		return l.rollback(err)
+	}
+
+	if err := l.file.Sync(); err != nil {
+		return l.rollback(err)
+	}
+
+	l.size += int64(len(content))
+	return nil
+}
+
+// rollback cuts the file back to its complete records after a failed append
+// and returns err. It must be called with mu held.
+func (l *Log) rollback(err error) error {
+	if truncateErr := l.file.Truncate(l.size); truncateErr != nil {
+		return errors.Join(err, truncateErr)
+	}
+
+	if syncErr := l.file.Sync(); syncErr != nil {
+		return errors.Join(err, syncErr)
+	}
+
+	return err
+}
+
+// Size is a function that returns the length of the records of the file
+func (l *Log) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.size
+}
+
+// Truncate is a function that removes every record of the file and syncs it
+func (l *Log) Truncate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+
+	l.size = 0
+	return l.file.Sync()
+}
+
+// Close is a function that closes the file
+func (l *Log) Close() error {
+	return l.file.Close()
+}
@@ -0,0 +1,129 @@
+package filestore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// record is the record of the test logs
+type record struct {
+	N int `json:"n"`
+}
+
+// readAll is a function that opens the log in path and returns its records
+func readAll(t *testing.T, path string) (*Log, []int) {
+	t.Helper()
+
+	read := []int{}
+	log, err := OpenLog(path, 0o600, func(r record) error {
+		read = append(read, r.N)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	return log, read
+}
+
+func TestLogTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.jsonl")
+
+	if err := os.WriteFile(path, []byte("{\"n\":1}\n{\"n\":2}\n{\"n\":"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	log, read := readAll(t, path)
+	if len(read) != 2 {
+		t.Fatalf("got records %v, want the 2 complete ones", read)
+	}
+	if err := log.Append(record{N: 3}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	log.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n" {
+		t.Fatalf("got file %q, %v", content, err)
+	}
+
+	log, read = readAll(t, path)
+	log.Close()
+	if len(read) != 3 || read[2] != 3 {
+		t.Fatalf("got records %v after reopening", read)
+	}
+}
+
+func TestLogCorruptLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.jsonl")
+
+	// A complete line that can't be read is not a crash, the log is refused
+	if err := os.WriteFile(path, []byte("{\"n\":1}\nnot json\n{\"n\":3}\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := OpenLog(path, 0o600, func(record) error { return nil }); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("got %v, want an error on line 2", err)
+	}
+
+	// The errors of read stop the open too
+	failed := errors.New("failed")
+	if _, err := OpenLog(path, 0o600, func(record) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("got %v, want the error of read", err)
+	}
+}
+
+// shortFile is a log file where the writes stop in the middle of the line
+type shortFile struct {
+	*os.File
+	fail bool
+}
+
+func (f *shortFile) Write(content []byte) (int, error) {
+	if !f.fail {
+		return f.File.Write(content)
+	}
+	n, _ := f.File.Write(content[:len(content)/2])
+	return n, errShortWrite
+}
+
+var errShortWrite = errors.New("short write")
+
+func TestLogFailedAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.jsonl")
+
+	log, _ := readAll(t, path)
+	if err := log.Append(record{N: 1}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	// The part of the line that was written is cut, the next append starts a new line
+	file := &shortFile{File: log.file.(*os.File), fail: true}
+	log.file = file
+	if err := log.Append(record{N: 2}); !errors.Is(err, errShortWrite) {
+		t.Fatalf("failed append: got %v, want the error of the write", err)
+	}
+	file.fail = false
+	if err := log.Append(record{N: 3}); err != nil {
+		t.Fatalf("append after the failed one: %v", err)
+	}
+	if size := log.Size(); size != int64(len("{\"n\":1}\n{\"n\":3}\n")) {
+		t.Fatalf("got size %d", size)
+	}
+	log.Close()
+
+	log, read := readAll(t, path)
+	defer log.Close()
+	if len(read) != 2 || read[0] != 1 || read[1] != 3 {
+		t.Fatalf("got records %v after reopening, want 1 and 3", read)
+	}
+
+	// Truncate empties the log
+	if err := log.Truncate(); err != nil || log.Size() != 0 {
+		t.Fatalf("truncate: got size %d, %v", log.Size(), err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Fatalf("file after truncate: got %v, %v", info, err)
+	}
+}
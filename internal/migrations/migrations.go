@@ -0,0 +1,337 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// files contains the up and down scripts of every dialect. The scripts are
+// named <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// Errors that can be returned by the migrator
+var (
+	ErrUnknownDialect = errors.New("unknown migrations dialect")
+	ErrMissingScript  = errors.New("migration is missing its up or down script")
+
+	// ErrUnknownSchema is returned when the products table exists but no
+	// migration was recorded and the table is not the one of the first migration
+	ErrUnknownSchema = errors.New("products table exists without migrations and doesn't match the first one")
+
+	// ErrDuplicateCodeValue is returned when the products can't have a unique
+	// code value because some of them share one
+	ErrDuplicateCodeValue = errors.New("products share a code value")
+)
+
+// checks contains, by version, what the data must meet before the up script
+// runs. They run in the transaction of the migration, so it fails with a
+// clear error instead of the one of the script.
+var checks = map[int]func(ctx context.Context, tx *sql.Tx) error{
+	2: checkUniqueCodeValues,
+}
+
+// checkUniqueCodeValues is a function that returns ErrDuplicateCodeValue with
+// the code values that more than one product has
+func checkUniqueCodeValues(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT code_value, COUNT(*) FROM products GROUP BY code_value HAVING COUNT(*) > 1 ORDER BY code_value")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var duplicates []string
+	for rows.Next() {
+		var code string
+		var count int
+		if err := rows.Scan(&code, &count); err != nil {
+			return err
+		}
+		duplicates = append(duplicates, fmt.Sprintf("%q (%d products)", code, count))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%w: %s, change or delete them before migrating", ErrDuplicateCodeValue, strings.Join(duplicates, ", "))
+	}
+	return nil
+}
+
+// Migration is a struct that represents a versioned change of the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load is a function that returns the migrations of the dialect sorted by version
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDialect, dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		// We split 0001_create_products.up.sql into its version, name and direction
+		base := strings.TrimSuffix(fileName, ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+
+		versionText, name, found := strings.Cut(base, "_")
+		if !found {
+			return nil, fmt.Errorf("invalid migration file name %q", fileName)
+		}
+
+		version, err := strconv.Atoi(versionText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %w", fileName, err)
+		}
+
+		content, err := files.ReadFile(path.Join(dialect, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+
+		switch direction {
+		case ".up":
+			migration.Up = string(content)
+		case ".down":
+			migration.Down = string(content)
+		default:
+			return nil, fmt.Errorf("invalid migration direction in %q", fileName)
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("%w: %04d_%s", ErrMissingScript, migration.Version, migration.Name)
+		}
+		result = append(result, *migration)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result, nil
+}
+
+// Migrator is a struct that applies the migrations of a dialect to a db and
+// keeps track of them in the schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// NewMigrator is a function that loads the migrations of the dialect into a Migrator
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// placeholder returns the n-th bind parameter in the syntax of the dialect
+func (m *Migrator) placeholder(n int) string {
+	if m.dialect == "postgres" {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// ensureTable creates the schema_migrations table if it doesn't exist
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+// Version is a function that returns the last applied version, 0 if none
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+
+	var version sql.NullInt64
+	err := m.db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(version.Int64), nil
+}
+
+// Latest is a function that returns the version of the newest migration
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// baselineColumns are the columns of the products table of the first
+// migration, which is also the schema the SQLite repository created on its
+// own before there were migrations
+var baselineColumns = []string{"id", "name", "quantity", "code_value", "is_published", "expiration", "price"}
+
+// tableExists returns if the table is in the db
+func (m *Migrator) tableExists(ctx context.Context, table string) (bool, error) {
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = " + m.placeholder(1)
+	if m.dialect == "postgres" {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = " + m.placeholder(1)
+	}
+
+	var count int
+	if err := m.db.QueryRowContext(ctx, query, table).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// baseline is a function that records the first migration as applied when
+// no migration was recorded but the products table already exists, as it was
+// created before the migrations. It returns ErrUnknownSchema if the table is
+// not the one of the first migration, instead of migrating over it.
+func (m *Migrator) baseline(ctx context.Context) error {
+	exists, err := m.tableExists(ctx, "products")
+	if err != nil || !exists {
+		return err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT * FROM products LIMIT 0")
+	if err != nil {
+		return err
+	}
+	columns, err := rows.Columns()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	if strings.Join(columns, ",") != strings.Join(baselineColumns, ",") {
+		return fmt.Errorf("%w: got columns %s", ErrUnknownSchema, strings.Join(columns, ", "))
+	}
+
+	_, err = m.db.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, applied_at) VALUES ("+m.placeholder(1)+", "+m.placeholder(2)+")",
+		m.migrations[0].Version, time.Now().UTC())
+	return err
+}
+
+// Up is a function that applies every pending migration and returns how many
+// were applied. A products table created before the migrations is taken as
+// the first one.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if current == 0 && len(m.migrations) > 0 {
+		if err := m.baseline(ctx); err != nil {
+			return 0, err
+		}
+		if current, err = m.Version(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	applied := 0
+	for _, migration := range m.migrations {
+		if migration.Version <= current {
+			continue
+		}
+
+		err := m.apply(ctx, checks[migration.Version], migration.Up,
+			"INSERT INTO schema_migrations (version, applied_at) VALUES ("+
+				m.placeholder(1)+", "+m.placeholder(2)+")",
+			migration.Version, time.Now().UTC())
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s up: %w", migration.Version, migration.Name, err)
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// Down is a function that reverts the last steps applied migrations and
+// returns how many were reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+		migration := m.migrations[i]
+		if migration.Version > current {
+			continue
+		}
+
+		err := m.apply(ctx, nil, migration.Down,
+			"DELETE FROM schema_migrations WHERE version = "+m.placeholder(1),
+			migration.Version)
+		if err != nil {
+			return reverted, fmt.Errorf("migration %04d_%s down: %w", migration.Version, migration.Name, err)
+		}
+		reverted++
+	}
+
+	return reverted, nil
+}
+
+// apply runs the check, if any, the script and the bookkeeping statement in a single transaction
+func (m *Migrator) apply(ctx context.Context, check func(ctx context.Context, tx *sql.Tx) error, script, bookkeeping string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if check != nil {
+		if err := check(ctx, tx); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,126 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// bootstrapSchema is the products table the SQLite repository created on its
+// own before there were migrations
+const bootstrapSchema = `
+CREATE TABLE IF NOT EXISTS products (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	quantity     INTEGER NOT NULL,
+	code_value   TEXT NOT NULL,
+	is_published BOOLEAN NOT NULL,
+	expiration   TIMESTAMP NOT NULL,
+	price        REAL NOT NULL
+)`
+
+// newTestMigrator is a function that returns an empty SQLite db and its migrator
+func newTestMigrator(t *testing.T) (*sql.DB, *Migrator) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "products.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatalf("migrator: %v", err)
+	}
+	return db, migrator
+}
+
+func TestUpBaselinesBootstrapSchema(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := newTestMigrator(t)
+
+	if _, err := db.Exec(bootstrapSchema); err != nil {
+		t.Fatalf("bootstrap: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO products VALUES ('1', 'Agua', 1, '123', 1, '2099-01-01', 1)"); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	// The table is the first migration, the rest are applied over it
+	applied, err := migrator.Up(ctx)
+	if err != nil || applied != migrator.Latest()-1 {
+		t.Fatalf("migrate up: got %d, %v, want %d", applied, err, migrator.Latest()-1)
+	}
+	if version, err := migrator.Version(ctx); err != nil || version != migrator.Latest() {
+		t.Fatalf("got version %d, %v, want %d", version, err, migrator.Latest())
+	}
+
+	var name string
+	var version int64
+	if err := db.QueryRow("SELECT name, version FROM products WHERE id = '1'").Scan(&name, &version); err != nil || name != "Agua" || version != 1 {
+		t.Fatalf("got product %s in version %d, %v", name, version, err)
+	}
+
+	// Running it again applies nothing
+	if applied, err := migrator.Up(ctx); err != nil || applied != 0 {
+		t.Fatalf("migrate up again: got %d, %v", applied, err)
+	}
+}
+
+func TestUpRejectsUnknownSchema(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := newTestMigrator(t)
+
+	if _, err := db.Exec("CREATE TABLE products (id TEXT PRIMARY KEY, title TEXT)"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx); !errors.Is(err, ErrUnknownSchema) || !strings.Contains(err.Error(), "id, title") {
+		t.Fatalf("migrate up: got %v, want ErrUnknownSchema", err)
+	}
+	if version, err := migrator.Version(ctx); err != nil || version != 0 {
+		t.Fatalf("got version %d, %v, want 0", version, err)
+	}
+}
+
+func TestUpRejectsDuplicateCodeValues(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := newTestMigrator(t)
+
+	// We go back to the first version, before the code values were unique
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if _, err := migrator.Down(ctx, migrator.Latest()-1); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+
+	insert := "INSERT INTO products (id, name, quantity, code_value, is_published, expiration, price) VALUES (?, 'Agua', 1, ?, 1, '2099-01-01', 1)"
+	for _, product := range [][2]string{{"1", "123"}, {"2", "123"}, {"3", "456"}} {
+		if _, err := db.Exec(insert, product[0], product[1]); err != nil {
+			t.Fatalf("insert %s: %v", product[0], err)
+		}
+	}
+
+	applied, err := migrator.Up(ctx)
+	if !errors.Is(err, ErrDuplicateCodeValue) || !strings.Contains(err.Error(), `"123" (2 products)`) || strings.Contains(err.Error(), "456") {
+		t.Fatalf("migrate up with duplicates: got %v", err)
+	}
+	if version, err := migrator.Version(ctx); err != nil || applied != 0 || version != 1 {
+		t.Fatalf("got version %d after applying %d, %v, want 1", version, applied, err)
+	}
+
+	// Once the codes are unique the migration applies
+	if _, err := db.Exec("DELETE FROM products WHERE id = '2'"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate up without duplicates: %v", err)
+	}
+}
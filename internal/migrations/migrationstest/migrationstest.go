@@ -0,0 +1,36 @@
+// Package migrationstest contains the helpers of the tests of the stores that
+// keep their tables in the db of the products
+package migrationstest
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/migrations"
+
+	_ "modernc.org/sqlite"
+)
+
+// OpenSQLite is a function that returns a SQLite db in a temporary directory
+// with every migration applied. It's closed when the test ends.
+func OpenSQLite(t testing.TB) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "products.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatalf("migrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+
+	return db
+}
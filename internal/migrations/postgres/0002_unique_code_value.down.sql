@@ -0,0 +1 @@
+DROP INDEX products_code_value_key;
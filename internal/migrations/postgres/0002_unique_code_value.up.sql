@@ -0,0 +1 @@
+CREATE UNIQUE INDEX products_code_value_key ON products (code_value);
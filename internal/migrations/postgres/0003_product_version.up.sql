@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
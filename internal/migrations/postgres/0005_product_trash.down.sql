@@ -0,0 +1,8 @@
+-- The schema before the trash can't keep the trashed products
+DELETE FROM products WHERE deleted_at IS NOT NULL;
+
+DROP INDEX products_deleted_at_idx;
+DROP INDEX products_code_value_key;
+CREATE UNIQUE INDEX products_code_value_key ON products (code_value);
+
+ALTER TABLE products DROP COLUMN deleted_at;
@@ -0,0 +1,24 @@
+CREATE TABLE audit_entries (
+	id           TEXT PRIMARY KEY,
+	created_at   TIMESTAMPTZ NOT NULL,
+	actor        TEXT NOT NULL,
+	ip           TEXT NOT NULL,
+	action       TEXT NOT NULL,
+	product_id   TEXT NOT NULL,
+	before_state JSONB,
+	after_state  JSONB
+);
+
+CREATE INDEX audit_entries_created_at_idx ON audit_entries (created_at);
+CREATE INDEX audit_entries_actor_idx ON audit_entries (actor, created_at);
+CREATE INDEX audit_entries_product_id_idx ON audit_entries (product_id, created_at);
+
+-- The audit log is append-only
+CREATE FUNCTION audit_entries_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit entries are append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_entries
+	FOR EACH STATEMENT EXECUTE FUNCTION audit_entries_append_only();
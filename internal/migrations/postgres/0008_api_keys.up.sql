@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	scopes       TEXT NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL,
+	expires_at   TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	revoked_at   TIMESTAMPTZ,
+	secrets      TEXT NOT NULL
+);
+
+-- The names of the keys that are not revoked are unique
+CREATE UNIQUE INDEX api_keys_name_key ON api_keys (name) WHERE revoked_at IS NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE products (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	quantity     INTEGER NOT NULL,
+	code_value   TEXT NOT NULL,
+	is_published BOOLEAN NOT NULL,
+	expiration   TIMESTAMP NOT NULL,
+	price        REAL NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE products ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00';
+UPDATE products SET updated_at = CURRENT_TIMESTAMP;
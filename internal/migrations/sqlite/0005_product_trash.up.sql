@@ -0,0 +1,7 @@
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP;
+
+-- A trashed product doesn't hold its code value
+DROP INDEX products_code_value_key;
+CREATE UNIQUE INDEX products_code_value_key ON products (code_value) WHERE deleted_at IS NULL;
+
+CREATE INDEX products_deleted_at_idx ON products (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE product_revisions (
+	product_id TEXT NOT NULL,
+	number     BIGINT NOT NULL,
+	action     TEXT NOT NULL,
+	actor      TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	product    TEXT NOT NULL,
+	PRIMARY KEY (product_id, number)
+);
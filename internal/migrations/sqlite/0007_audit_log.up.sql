@@ -0,0 +1,25 @@
+CREATE TABLE audit_entries (
+	id           TEXT PRIMARY KEY,
+	created_at   TIMESTAMP NOT NULL,
+	actor        TEXT NOT NULL,
+	ip           TEXT NOT NULL,
+	action       TEXT NOT NULL,
+	product_id   TEXT NOT NULL,
+	before_state TEXT,
+	after_state  TEXT
+);
+
+CREATE INDEX audit_entries_created_at_idx ON audit_entries (created_at);
+CREATE INDEX audit_entries_actor_idx ON audit_entries (actor, created_at);
+CREATE INDEX audit_entries_product_id_idx ON audit_entries (product_id, created_at);
+
+-- The audit log is append-only
+CREATE TRIGGER audit_entries_no_update BEFORE UPDATE ON audit_entries
+BEGIN
+	SELECT RAISE(ABORT, 'audit entries are append-only');
+END;
+
+CREATE TRIGGER audit_entries_no_delete BEFORE DELETE ON audit_entries
+BEGIN
+	SELECT RAISE(ABORT, 'audit entries are append-only');
+END;
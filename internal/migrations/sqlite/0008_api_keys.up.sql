@@ -0,0 +1,13 @@
+CREATE TABLE api_keys (
+	id           TEXT PRIMARY KEY,
+	name         TEXT NOT NULL,
+	scopes       TEXT NOT NULL,
+	created_at   TIMESTAMP NOT NULL,
+	expires_at   TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at   TIMESTAMP,
+	secrets      TEXT NOT NULL
+);
+
+-- The names of the keys that are not revoked are unique
+CREATE UNIQUE INDEX api_keys_name_key ON api_keys (name) WHERE revoked_at IS NULL;
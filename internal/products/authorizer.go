@@ -0,0 +1,61 @@
+package products
+
+import (
+	"context"
+	"errors"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+)
+
+// ErrForbidden is returned when the caller is not allowed to do an operation of the service
+var ErrForbidden = errors.New("forbidden")
+
+// Permission is an operation of the service that can be granted to a caller
+type Permission string
+
+// Permissions checked by the service
+const (
+	PermissionRead    Permission = "products:read"
+	PermissionCreate  Permission = "products:create"
+	PermissionUpdate  Permission = "products:update"
+	PermissionPublish Permission = "products:publish"
+	PermissionDelete  Permission = "products:delete"
+	PermissionRestore Permission = "products:restore"
+	PermissionPurge   Permission = "products:purge"
+)
+
+// Permissions is the list of every permission checked by the service
+var Permissions = []Permission{
+	PermissionRead,
+	PermissionCreate,
+	PermissionUpdate,
+	PermissionPublish,
+	PermissionDelete,
+	PermissionRestore,
+	PermissionPurge,
+}
+
+// Authorizer represents a contract of what decides if the caller carried by
+// ctx can do an operation. A denial is an error that wraps ErrForbidden.
+type Authorizer interface {
+	Authorize(ctx context.Context, permission Permission) error
+}
+
+// AuthorizerFunc is an adapter to use a function as an Authorizer
+type AuthorizerFunc func(ctx context.Context, permission Permission) error
+
+// Authorize calls the function
+func (f AuthorizerFunc) Authorize(ctx context.Context, permission Permission) error {
+	return f(ctx, permission)
+}
+
+// allowAll is the Authorizer of the service when none is set
+var allowAll = AuthorizerFunc(func(ctx context.Context, permission Permission) error {
+	return nil
+})
+
+// publishes is a function that returns if a write of the product publishes
+// it, previous is the product it replaces or nil for a new one
+func publishes(product domain.Product, previous *domain.Product) bool {
+	return product.IsPublished && (previous == nil || !previous.IsPublished)
+}
@@ -0,0 +1,87 @@
+package products
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+// ID generators that can be selected with NewIDGenerator
+const (
+	IDGeneratorUUIDv7     = "uuidv7"
+	IDGeneratorULID       = "ulid"
+	IDGeneratorSequential = "sequential"
+)
+
+// IDGenerator represents a contract for the generators of product ids
+type IDGenerator interface {
+	NewID() (string, error)
+}
+
+// IDGeneratorFunc is an adapter to use an ordinary function as an IDGenerator
+type IDGeneratorFunc func() (string, error)
+
+// NewID calls f()
+func (f IDGeneratorFunc) NewID() (string, error) {
+	return f()
+}
+
+// NewIDGenerator is a function that returns the generator of the given kind.
+// start is only used by the sequential generator, it's the last id already taken.
+func NewIDGenerator(kind string, start uint64) (IDGenerator, error) {
+	switch kind {
+	case IDGeneratorUUIDv7:
+		return NewUUIDv7Generator(), nil
+	case IDGeneratorULID:
+		return NewULIDGenerator(), nil
+	case IDGeneratorSequential:
+		return NewSequentialGenerator(start), nil
+	default:
+		return nil, fmt.Errorf("unknown id generator %q", kind)
+	}
+}
+
+// NewUUIDv7Generator is a function that returns a generator of time ordered UUIDs
+func NewUUIDv7Generator() IDGenerator {
+	return IDGeneratorFunc(func() (string, error) {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return "", err
+		}
+		return id.String(), nil
+	})
+}
+
+// NewULIDGenerator is a function that returns a generator of ULIDs.
+// The ids generated in the same millisecond are still increasing.
+func NewULIDGenerator() IDGenerator {
+	var mu sync.Mutex
+	entropy := ulid.Monotonic(rand.Reader, 0)
+
+	return IDGeneratorFunc(func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id, err := ulid.New(ulid.Timestamp(time.Now()), entropy)
+		if err != nil {
+			return "", err
+		}
+		return id.String(), nil
+	})
+}
+
+// NewSequentialGenerator is a function that returns a generator of the
+// numbers that follow start
+func NewSequentialGenerator(start uint64) IDGenerator {
+	counter := start
+
+	return IDGeneratorFunc(func() (string, error) {
+		return strconv.FormatUint(atomic.AddUint64(&counter, 1), 10), nil
+	})
+}
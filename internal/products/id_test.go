@@ -0,0 +1,132 @@
+package products
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+)
+
+func TestNewIDGenerator(t *testing.T) {
+	cases := []struct {
+		kind  string
+		valid func(id string) bool
+	}{
+		{
+			kind: IDGeneratorUUIDv7,
+			valid: func(id string) bool {
+				parsed, err := uuid.Parse(id)
+				return err == nil && parsed.Version() == 7 && parsed.Variant() == uuid.RFC4122
+			},
+		},
+		{
+			kind: IDGeneratorULID,
+			valid: func(id string) bool {
+				parsed, err := ulid.ParseStrict(id)
+				// The time of the id is the one it was generated at
+				return err == nil && time.Since(ulid.Time(parsed.Time())) < time.Minute
+			},
+		},
+		{
+			kind:  IDGeneratorSequential,
+			valid: func(id string) bool { return id == "43" },
+		},
+	}
+	for _, c := range cases {
+		generator, err := NewIDGenerator(c.kind, 42)
+		if err != nil {
+			t.Fatalf("%s: %v", c.kind, err)
+		}
+
+		id, err := generator.NewID()
+		if err != nil || !c.valid(id) {
+			t.Errorf("%s: got %q, %v", c.kind, id, err)
+		}
+	}
+
+	if _, err := NewIDGenerator("uuidv4", 0); err == nil {
+		t.Fatal("unknown generator: got no error")
+	}
+}
+
+func TestIDGeneratorsAreOrdered(t *testing.T) {
+	// less is the order of the ids, the one of the strings but for the sequence
+	less := map[string]func(a, b string) bool{
+		IDGeneratorUUIDv7: func(a, b string) bool { return a < b },
+		IDGeneratorULID:   func(a, b string) bool { return a < b },
+		IDGeneratorSequential: func(a, b string) bool {
+			x, _ := strconv.ParseUint(a, 10, 64)
+			y, _ := strconv.ParseUint(b, 10, 64)
+			return x < y
+		},
+	}
+
+	// Most of the ids are generated in the same millisecond as the previous one
+	for kind, less := range less {
+		generator, err := NewIDGenerator(kind, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+
+		previous, err := generator.NewID()
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		for i := 0; i < 10000; i++ {
+			id, err := generator.NewID()
+			if err != nil {
+				t.Fatalf("%s: %v", kind, err)
+			}
+			if !less(previous, id) {
+				t.Fatalf("%s: got %s after %s", kind, id, previous)
+			}
+			previous = id
+		}
+	}
+}
+
+func TestIDGeneratorsAreUnique(t *testing.T) {
+	const (
+		goroutines = 8
+		perRoutine = 2000
+	)
+
+	for _, kind := range []string{IDGeneratorUUIDv7, IDGeneratorULID, IDGeneratorSequential} {
+		generator, err := NewIDGenerator(kind, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		seen := make(map[string]bool, goroutines*perRoutine)
+		for g := 0; g < goroutines; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perRoutine; i++ {
+					id, err := generator.NewID()
+					if err != nil {
+						t.Errorf("%s: %v", kind, err)
+						return
+					}
+
+					mu.Lock()
+					if seen[id] {
+						t.Errorf("%s: got %s twice", kind, id)
+					}
+					seen[id] = true
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(seen) != goroutines*perRoutine {
+			t.Fatalf("%s: got %d ids, want %d", kind, len(seen), goroutines*perRoutine)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package products
+
+import (
+	"context"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+)
+
+// Actions of the changes notified to the listeners
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// Change is a struct that describes a write done by the service
+type Change struct {
+	Action string
+
+	// Product is the product as the write stored it, or as it was in the trash
+	// for a purge
+	Product domain.Product
+
+	// Previous is the product the write replaced, nil when it did not exist
+	// or was in the trash
+	Previous *domain.Product
+}
+
+// Listener represents a contract of what is notified after every write of the service.
+// The write already happened, so an error is only logged and the write still
+// succeeds: what a listener records is best-effort.
+type Listener interface {
+	OnChange(ctx context.Context, change Change) error
+}
+
+// ListenerFunc is an adapter to use a function as a Listener
+type ListenerFunc func(ctx context.Context, change Change) error
+
+// OnChange calls the function
+func (f ListenerFunc) OnChange(ctx context.Context, change Change) error {
+	return f(ctx, change)
+}
@@ -0,0 +1,176 @@
+package products
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+)
+
+// Media types of the patches a product accepts
+const (
+	PatchMerge = "application/merge-patch+json"
+	PatchJSON  = "application/json-patch+json"
+)
+
+// Errors that can be returned when patching a product
+var (
+	ErrMalformedPatch = errors.New("malformed patch")
+	ErrInvalidPatch   = errors.New("patch can't be applied")
+)
+
+// readOnlyFields are the members of the product json a patch can only read
+var readOnlyFields = map[string]bool{
+	"id":         true,
+	"version":    true,
+	"updated_at": true,
+	"deleted_at": true,
+}
+
+// patchableFields are the members of the product json a patch can change
+var patchableFields = map[string]bool{
+	"name":         true,
+	"quantity":     true,
+	"code_value":   true,
+	"is_published": true,
+	"expiration":   true,
+	"price":        true,
+}
+
+// Patch is a struct that contains a patch document and its media type,
+// PatchMerge for RFC 7386 JSON Merge Patch or PatchJSON for RFC 6902 JSON Patch
+type Patch struct {
+	Type     string
+	Document []byte
+}
+
+// Apply is a function that returns the product with the patch applied.
+// Only the fields named by the patch change, the read only fields can't be patched.
+func (p Patch) Apply(product domain.Product) (domain.Product, error) {
+	original, err := json.Marshal(product)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	var patched []byte
+	switch p.Type {
+	case PatchMerge:
+		patched, err = p.applyMerge(original)
+	case PatchJSON:
+		patched, err = p.applyJSON(original)
+	default:
+		return domain.Product{}, fmt.Errorf("%w: unsupported media type %q", ErrMalformedPatch, p.Type)
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	// A member with the wrong type is reported as an invalid patch
+	var result domain.Product
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return domain.Product{}, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	result.Id = product.Id
+	result.Version = product.Version
+	result.UpdatedAt = product.UpdatedAt
+	result.DeletedAt = product.DeletedAt
+	return result, nil
+}
+
+// applyMerge applies a JSON Merge Patch, a null member resets the field
+func (p Patch) applyMerge(original []byte) ([]byte, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(p.Document, &members); err != nil {
+		return nil, fmt.Errorf("%w: a merge patch must be a json object", ErrMalformedPatch)
+	}
+
+	for name := range members {
+		if !patchableFields[name] {
+			return nil, fmt.Errorf("%w: %q can't be patched", ErrInvalidPatch, name)
+		}
+	}
+
+	patched, err := jsonpatch.MergePatch(original, p.Document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedPatch, err)
+	}
+
+	return patched, nil
+}
+
+// applyJSON applies a JSON Patch after checking every operation
+func (p Patch) applyJSON(original []byte) ([]byte, error) {
+	patch, err := jsonpatch.DecodePatch(p.Document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: a json patch must be an array of operations", ErrMalformedPatch)
+	}
+
+	for i, operation := range patch {
+		if err := validateOperation(operation); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	patched, err := patch.Apply(original)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	return patched, nil
+}
+
+// validateOperation checks the op and that its paths point to members of the
+// product. test and the from of copy can read the read only fields, every
+// other path must be a patchable field.
+func validateOperation(operation jsonpatch.Operation) error {
+	kind := operation.Kind()
+	switch kind {
+	case "add", "remove", "replace", "move", "copy", "test":
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrMalformedPatch, kind)
+	}
+
+	path, err := operation.Path()
+	if err != nil {
+		return fmt.Errorf("%w: %s needs a path", ErrMalformedPatch, kind)
+	}
+
+	if _, ok := operation["value"]; !ok && (kind == "add" || kind == "replace" || kind == "test") {
+		return fmt.Errorf("%w: %s needs a value", ErrMalformedPatch, kind)
+	}
+
+	if kind == "test" {
+		return checkPath(path, true)
+	}
+	if err := checkPath(path, false); err != nil {
+		return err
+	}
+
+	if kind == "move" || kind == "copy" {
+		from, err := operation.From()
+		if err != nil {
+			return fmt.Errorf("%w: %s needs a from", ErrMalformedPatch, kind)
+		}
+		return checkPath(from, kind == "copy")
+	}
+
+	return nil
+}
+
+// checkPath checks that the pointer names a single member of the product,
+// the read only fields are accepted when the path is only read
+func checkPath(path string, read bool) error {
+	field, ok := strings.CutPrefix(path, "/")
+	if ok && (patchableFields[field] || (read && readOnlyFields[field])) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q can't be patched", ErrInvalidPatch, path)
+}
@@ -0,0 +1,121 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+)
+
+func TestPatchApply(t *testing.T) {
+	original := newTestProduct("1")
+	original.Version = 3
+	original.UpdatedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name    string
+		patch   Patch
+		err     error
+		patched func(p domain.Product) bool
+	}{
+		{
+			name:    "merge changes only the members",
+			patch:   Patch{Type: PatchMerge, Document: []byte(`{"name":"Agua","quantity":0}`)},
+			patched: func(p domain.Product) bool { return p.Name == "Agua" && p.Quantity == 0 && p.Price == original.Price },
+		},
+		{
+			name:    "merge null resets the member",
+			patch:   Patch{Type: PatchMerge, Document: []byte(`{"code_value":null}`)},
+			patched: func(p domain.Product) bool { return p.CodeValue == "" && p.Name == original.Name },
+		},
+		{name: "merge of the id", patch: Patch{Type: PatchMerge, Document: []byte(`{"id":"2"}`)}, err: ErrInvalidPatch},
+		{name: "merge of the version", patch: Patch{Type: PatchMerge, Document: []byte(`{"version":9}`)}, err: ErrInvalidPatch},
+		{name: "merge of an unknown member", patch: Patch{Type: PatchMerge, Document: []byte(`{"color":"red"}`)}, err: ErrInvalidPatch},
+		{name: "merge of the wrong type", patch: Patch{Type: PatchMerge, Document: []byte(`{"quantity":"ten"}`)}, err: ErrInvalidPatch},
+		{name: "merge that is not an object", patch: Patch{Type: PatchMerge, Document: []byte(`[{"op":"remove","path":"/name"}]`)}, err: ErrMalformedPatch},
+		{
+			name:    "json patch replaces",
+			patch:   Patch{Type: PatchJSON, Document: []byte(`[{"op":"replace","path":"/price","value":2.5}]`)},
+			patched: func(p domain.Product) bool { return p.Price == 2.5 && p.Name == original.Name },
+		},
+		{
+			name:    "json patch tests the id and the version",
+			patch:   Patch{Type: PatchJSON, Document: []byte(`[{"op":"test","path":"/id","value":"1"},{"op":"test","path":"/version","value":3},{"op":"replace","path":"/name","value":"Agua"}]`)},
+			patched: func(p domain.Product) bool { return p.Name == "Agua" },
+		},
+		{
+			name:    "json patch copies the id",
+			patch:   Patch{Type: PatchJSON, Document: []byte(`[{"op":"copy","from":"/id","path":"/name"}]`)},
+			patched: func(p domain.Product) bool { return p.Name == "1" && p.Id == "1" },
+		},
+		{name: "json patch failed test", patch: Patch{Type: PatchJSON, Document: []byte(`[{"op":"test","path":"/name","value":"Agua"},{"op":"replace","path":"/name","value":"Soda"}]`)}, err: ErrInvalidPatch},
+		{name: "json patch replaces the id", patch: Patch{Type: PatchJSON, Document: []byte(`[{"op":"replace","path":"/id","value":"2"}]`)}, err: ErrInvalidPatch},
+		{name: "json patch removes the id", patch: Patch{Type: PatchJSON, Document: []byte(`[{"op":"remove","path":"/id"}]`)}, err: ErrInvalidPatch},
+		{name: "json patch moves the id", patch: Patch{Type: PatchJSON, Document: []byte(`[{"op":"move","from":"/id","path":"/name"}]`)}, err: ErrInvalidPatch},
+		{name: "json patch of a nested path", patch: Patch{Type: PatchJSON, Document: []byte(`[{"op":"add","path":"/name/first","value":"A"}]`)}, err: ErrInvalidPatch},
+		{name: "json patch of the wrong type", patch: Patch{Type: PatchJSON, Document: []byte(`[{"op":"replace","path":"/price","value":"free"}]`)}, err: ErrInvalidPatch},
+		{name: "json patch unknown op", patch: Patch{Type: PatchJSON, Document: []byte(`[{"op":"increment","path":"/quantity"}]`)}, err: ErrMalformedPatch},
+		{name: "json patch without a value", patch: Patch{Type: PatchJSON, Document: []byte(`[{"op":"replace","path":"/name"}]`)}, err: ErrMalformedPatch},
+		{name: "json patch that is not an array", patch: Patch{Type: PatchJSON, Document: []byte(`{"name":"Agua"}`)}, err: ErrMalformedPatch},
+		{name: "unknown media type", patch: Patch{Type: "application/json", Document: []byte(`{"name":"Agua"}`)}, err: ErrMalformedPatch},
+	}
+	for _, c := range cases {
+		patched, err := c.patch.Apply(original)
+		if c.err != nil {
+			if !errors.Is(err, c.err) {
+				t.Errorf("%s: got %v, want %v", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil || !c.patched(patched) {
+			t.Errorf("%s: got %+v, %v", c.name, patched, err)
+			continue
+		}
+
+		// The read only fields are the ones of the product, whatever the patch did
+		if patched.Id != original.Id || patched.Version != original.Version || !patched.UpdatedAt.Equal(original.UpdatedAt) || patched.DeletedAt != nil {
+			t.Errorf("%s: got read only fields %+v", c.name, patched)
+		}
+	}
+}
+
+// racingRepository is a repository where another write gets in before the first update
+type racingRepository struct {
+	Repository
+	raced bool
+}
+
+func (r *racingRepository) Update(ctx context.Context, product domain.Product, id string) (domain.Product, error) {
+	if !r.raced {
+		r.raced = true
+		current, err := r.Repository.GetByID(ctx, id)
+		if err != nil {
+			return domain.Product{}, err
+		}
+		current.Quantity = 99
+		if _, err := r.Repository.Update(ctx, current, id); err != nil {
+			return domain.Product{}, err
+		}
+	}
+	return r.Repository.Update(ctx, product, id)
+}
+
+func TestServicePatchConcurrentWrite(t *testing.T) {
+	ctx := context.Background()
+	patch := Patch{Type: PatchMerge, Document: []byte(`{"name":"Agua"}`)}
+
+	// Without an expected version the patch is applied again over the other write
+	service := NewServiceProduct(&racingRepository{Repository: NewMemoryRepository([]domain.Product{newTestProduct("1")})})
+	patched, err := service.Patch(ctx, "1", patch, 0)
+	if err != nil || patched.Name != "Agua" || patched.Quantity != 99 || patched.Version != 3 {
+		t.Fatalf("patch any version: got %+v, %v", patched, err)
+	}
+
+	// With an expected version the other write wins
+	service = NewServiceProduct(&racingRepository{Repository: NewMemoryRepository([]domain.Product{newTestProduct("1")})})
+	if _, err := service.Patch(ctx, "1", patch, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("patch expected version: got %v, want ErrVersionMismatch", err)
+	}
+}
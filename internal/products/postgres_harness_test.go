@@ -0,0 +1,81 @@
+package products
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// postgresTestDSN returns the dsn of a Postgres used by the integration tests.
+//
+// POSTGRES_TEST_DSN points the tests to an already running server. Otherwise
+// a throwaway cluster is created with the initdb and pg_ctl binaries found in
+// PATH or in POSTGRES_BIN, and stopped when the test ends. The test is skipped
+// when neither is available.
+func postgresTestDSN(t *testing.T) string {
+	t.Helper()
+
+	if dsn := os.Getenv("POSTGRES_TEST_DSN"); dsn != "" {
+		return dsn
+	}
+
+	initdb, err := lookPostgresBinary("initdb")
+	if err != nil {
+		t.Skip("postgres integration tests need POSTGRES_TEST_DSN or initdb in PATH")
+	}
+	pgCtl, err := lookPostgresBinary("pg_ctl")
+	if err != nil {
+		t.Skip("postgres integration tests need POSTGRES_TEST_DSN or pg_ctl in PATH")
+	}
+
+	dir := t.TempDir()
+	dataDir := filepath.Join(dir, "data")
+
+	output, err := exec.Command(initdb, "-D", dataDir, "-U", "postgres", "-A", "trust", "--no-sync").CombinedOutput()
+	if err != nil {
+		// initdb refuses to run as root, we skip instead of failing the suite
+		t.Skipf("initdb failed: %v\n%s", err, output)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		t.Fatalf("free port: %v", err)
+	}
+
+	options := fmt.Sprintf("-p %d -k %s -c listen_addresses=127.0.0.1 -c fsync=off", port, dir)
+	output, err = exec.Command(pgCtl, "-D", dataDir, "-o", options, "-l", filepath.Join(dir, "postgres.log"), "-w", "start").CombinedOutput()
+	if err != nil {
+		t.Fatalf("pg_ctl start: %v\n%s", err, output)
+	}
+
+	t.Cleanup(func() {
+		output, err := exec.Command(pgCtl, "-D", dataDir, "-m", "immediate", "-w", "stop").CombinedOutput()
+		if err != nil {
+			t.Logf("pg_ctl stop: %v\n%s", err, output)
+		}
+	})
+
+	return fmt.Sprintf("postgres://postgres@127.0.0.1:%d/postgres?sslmode=disable", port)
+}
+
+// lookPostgresBinary finds a Postgres binary in POSTGRES_BIN or in PATH
+func lookPostgresBinary(name string) (string, error) {
+	if dir := os.Getenv("POSTGRES_BIN"); dir != "" {
+		return exec.LookPath(filepath.Join(dir, name))
+	}
+	return exec.LookPath(name)
+}
+
+// freePort asks the kernel for a free tcp port
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
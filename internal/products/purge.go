@@ -0,0 +1,41 @@
+package products
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Defaults of the purge of the trash
+const (
+	DefaultTrashRetention = 30 * 24 * time.Hour
+	DefaultPurgeInterval  = time.Hour
+)
+
+// RunPurge is a function that permanently deletes the products that have
+// been in the trash for longer than retention, every interval, until ctx is
+// done. It purges once right away.
+func RunPurge(ctx context.Context, service Service, retention, interval time.Duration) {
+	if retention <= 0 {
+		retention = DefaultTrashRetention
+	}
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// The service already logs the errors, the next tick retries
+		if purged, err := service.Purge(ctx, time.Now().Add(-retention)); err == nil && purged > 0 {
+			slog.InfoContext(ctx, "[Purge] trashed products purged", "purged", purged)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
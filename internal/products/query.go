@@ -0,0 +1,290 @@
+package products
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+)
+
+// Limits of the size of a page
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Fields a listing can be sorted by, named as in the product json
+const (
+	SortID          = "id"
+	SortName        = "name"
+	SortQuantity    = "quantity"
+	SortCodeValue   = "code_value"
+	SortIsPublished = "is_published"
+	SortExpiration  = "expiration"
+	SortPrice       = "price"
+)
+
+// sortFields are the fields a listing can be sorted by
+var sortFields = map[string]bool{
+	SortID:          true,
+	SortName:        true,
+	SortQuantity:    true,
+	SortCodeValue:   true,
+	SortIsPublished: true,
+	SortExpiration:  true,
+	SortPrice:       true,
+}
+
+// ErrInvalidQuery is matched by errors.Is for every error of a Query
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Query is a struct that describes a page of the products listing.
+// Every Repository translates it into its own query language.
+// When Cursor is set the page starts right after it and Offset is ignored.
+type Query struct {
+	Filter Filter
+	Sort   []SortKey
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// Filter is a struct that contains the conditions a product must meet to be
+// listed. The zero value of each field means no condition, ranges include
+// their limits and expirations are exclusive.
+type Filter struct {
+	// Name is a case insensitive substring of the name
+	Name          string
+	IsPublished   *bool
+	MinPrice      *float64
+	MaxPrice      *float64
+	MinQuantity   *int
+	MaxQuantity   *int
+	ExpiresBefore *time.Time
+	ExpiresAfter  *time.Time
+}
+
+// SortKey is a struct that represents a field of the order of a listing
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Page is a struct that contains a page of products and how to get the next one.
+// Total is the number of products that meet the filter and NextCursor is
+// empty on the last page.
+type Page struct {
+	Items      []domain.Product
+	Total      int
+	NextCursor string
+}
+
+// cursor is the content of the opaque Query.Cursor: the sort it was made
+// for and the sort values of the last product of the previous page
+type cursor struct {
+	Sort  string         `json:"sort"`
+	After domain.Product `json:"after"`
+}
+
+// ParseSort is a function that reads a comma separated list of fields,
+// where a leading - sorts that field in descending order, like -price,name
+func ParseSort(value string) ([]SortKey, error) {
+	var keys []SortKey
+	if value == "" {
+		return keys, nil
+	}
+
+	seen := map[string]bool{}
+	for _, field := range strings.Split(value, ",") {
+		key := SortKey{Field: strings.TrimSpace(field)}
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field = strings.TrimPrefix(key.Field, "-")
+			key.Desc = true
+		}
+
+		if !sortFields[key.Field] {
+			return nil, fmt.Errorf("%w: can't sort by %q", ErrInvalidQuery, key.Field)
+		}
+		if seen[key.Field] {
+			return nil, fmt.Errorf("%w: %q is sorted twice", ErrInvalidQuery, key.Field)
+		}
+		seen[key.Field] = true
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// sortString is a function that returns the sort in the format read by ParseSort
+func sortString(keys []SortKey) string {
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.Desc {
+			fields = append(fields, "-"+key.Field)
+		} else {
+			fields = append(fields, key.Field)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// Normalize is a function that applies the defaults of the query and checks it.
+// The id is always the last sort key so the order of the products is total.
+func (q Query) Normalize() (Query, error) {
+	if q.Limit == 0 {
+		q.Limit = DefaultLimit
+	}
+
+	if q.Limit < 1 || q.Limit > MaxLimit {
+		return Query{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxLimit)
+	}
+
+	if q.Offset < 0 {
+		return Query{}, fmt.Errorf("%w: offset must not be negative", ErrInvalidQuery)
+	}
+
+	filter := q.Filter
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return Query{}, fmt.Errorf("%w: the price range is empty", ErrInvalidQuery)
+	}
+	if filter.MinQuantity != nil && filter.MaxQuantity != nil && *filter.MinQuantity > *filter.MaxQuantity {
+		return Query{}, fmt.Errorf("%w: the quantity range is empty", ErrInvalidQuery)
+	}
+
+	sorted := make([]SortKey, 0, len(q.Sort)+1)
+	hasID := false
+	for _, key := range q.Sort {
+		if !sortFields[key.Field] {
+			return Query{}, fmt.Errorf("%w: can't sort by %q", ErrInvalidQuery, key.Field)
+		}
+		hasID = hasID || key.Field == SortID
+		sorted = append(sorted, key)
+	}
+	if !hasID {
+		sorted = append(sorted, SortKey{Field: SortID})
+	}
+	q.Sort = sorted
+
+	if q.Cursor != "" {
+		if _, err := q.decodeCursor(); err != nil {
+			return Query{}, err
+		}
+	}
+
+	return q, nil
+}
+
+// Matches is a function that reports if the product meets the filter
+func (f Filter) Matches(product domain.Product) bool {
+	switch {
+	case f.Name != "" && !strings.Contains(strings.ToLower(product.Name), strings.ToLower(f.Name)):
+		return false
+	case f.IsPublished != nil && product.IsPublished != *f.IsPublished:
+		return false
+	case f.MinPrice != nil && product.Price < *f.MinPrice:
+		return false
+	case f.MaxPrice != nil && product.Price > *f.MaxPrice:
+		return false
+	case f.MinQuantity != nil && product.Quantity < *f.MinQuantity:
+		return false
+	case f.MaxQuantity != nil && product.Quantity > *f.MaxQuantity:
+		return false
+	case f.ExpiresBefore != nil && !product.Expiration.Before(*f.ExpiresBefore):
+		return false
+	case f.ExpiresAfter != nil && !product.Expiration.After(*f.ExpiresAfter):
+		return false
+	}
+	return true
+}
+
+// compareProducts is a function that returns -1, 0 or 1 if a goes before,
+// together or after b in the order of the keys
+func compareProducts(a, b domain.Product, keys []SortKey) int {
+	for _, key := range keys {
+		result := compareField(a, b, key.Field)
+		if key.Desc {
+			result = -result
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+// compareField is a function that compares a single field of two products
+func compareField(a, b domain.Product, field string) int {
+	switch field {
+	case SortName:
+		return strings.Compare(a.Name, b.Name)
+	case SortQuantity:
+		return cmp.Compare(a.Quantity, b.Quantity)
+	case SortCodeValue:
+		return strings.Compare(a.CodeValue, b.CodeValue)
+	case SortIsPublished:
+		return cmp.Compare(boolToInt(a.IsPublished), boolToInt(b.IsPublished))
+	case SortExpiration:
+		return a.Expiration.Compare(b.Expiration)
+	case SortPrice:
+		return cmp.Compare(a.Price, b.Price)
+	default:
+		return strings.Compare(a.Id, b.Id)
+	}
+}
+
+// boolToInt sorts false before true
+func boolToInt(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// encodeCursor is a function that returns the cursor of the page after the
+// product. Only the sort values of the product are kept.
+func (q Query) encodeCursor(last domain.Product) string {
+	var after domain.Product
+	for _, key := range q.Sort {
+		switch key.Field {
+		case SortID:
+			after.Id = last.Id
+		case SortName:
+			after.Name = last.Name
+		case SortQuantity:
+			after.Quantity = last.Quantity
+		case SortCodeValue:
+			after.CodeValue = last.CodeValue
+		case SortIsPublished:
+			after.IsPublished = last.IsPublished
+		case SortExpiration:
+			after.Expiration = last.Expiration
+		case SortPrice:
+			after.Price = last.Price
+		}
+	}
+
+	content, _ := json.Marshal(cursor{Sort: sortString(q.Sort), After: after})
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+// decodeCursor is a function that reads the cursor of a normalized query
+func (q Query) decodeCursor() (cursor, error) {
+	var result cursor
+
+	content, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil || json.Unmarshal(content, &result) != nil || result.After.Id == "" {
+		return cursor{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+
+	if result.Sort != sortString(q.Sort) {
+		return cursor{}, fmt.Errorf("%w: the cursor was made for another sort", ErrInvalidQuery)
+	}
+
+	return result, nil
+}
@@ -3,6 +3,10 @@ package products
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/burgosfacundo/ApiGo.git/internal/domain"
 )
@@ -11,58 +15,228 @@ import (
 var (
 	ErrEmpty    = errors.New("empty list")
 	ErrNotFound = errors.New("product not found")
+	ErrConflict = errors.New("product already exists")
+
+	// ErrVersionMismatch is returned when the version expected by a write
+	// is not the current one, because another write happened before
+	ErrVersionMismatch = errors.New("product version mismatch")
 )
 
-// Repository represents a contract with all the functions that need to be implemented
+// ConflictError is returned when a write breaks the uniqueness of a field.
+// errors.Is(err, ErrConflict) reports true for it.
+type ConflictError struct {
+	Field string
+	Value string
+}
+
+// Error returns the message of the conflict
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("product with %s %q already exists", e.Field, e.Value)
+}
+
+// Is makes errors.Is match ErrConflict
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Repository represents a contract with all the functions that need to be implemented.
+// Update and Delete are compare-and-swap: a version other than 0 must be the
+// current version of the product or ErrVersionMismatch is returned. The
+// version of the product passed to Update is the expected one.
+//
+// Delete moves the product to the trash, where only Trash, Restore and Purge
+// see it. A trashed product doesn't hold its code value, so Restore returns a
+// ConflictError if another product took it.
 type Repository interface {
 	Create(ctx context.Context, product domain.Product) (domain.Product, error)
 	GetAll(ctx context.Context) ([]domain.Product, error)
+	List(ctx context.Context, query Query) (Page, error)
 	GetByID(ctx context.Context, id string) (domain.Product, error)
+	GetByCode(ctx context.Context, code string) (domain.Product, error)
 	Update(ctx context.Context, product domain.Product, id string) (domain.Product, error)
-	Delete(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string, version int64) (domain.Product, error)
+	Trash(ctx context.Context, query Query) (Page, error)
+	GetTrashed(ctx context.Context, id string) (domain.Product, error)
+	Restore(ctx context.Context, id string) (domain.Product, error)
+	Purge(ctx context.Context, before time.Time) ([]domain.Product, error)
+	Stats(ctx context.Context) (Stats, error)
+}
+
+// Stats is a struct that contains the figures of the catalog, the products in
+// the trash are not counted. InventoryValue is the sum of price times quantity.
+type Stats struct {
+	Products       int
+	Published      int
+	InventoryValue float64
 }
 
-// repository is a struct that contains the db of Product
+// repository is a struct that contains the db of Product.
+// db indexes the products by id, codes indexes their ids by code value and
+// ids keeps them sorted by id. trash indexes the deleted products by id and
+// they are in none of the other indexes. mu guards the four of them: writers
+// take the lock exclusively and readers share it.
 type repository struct {
-	db []domain.Product
+	mu    sync.RWMutex
+	db    map[string]domain.Product
+	codes map[string]string
+	ids   []string
+	trash map[string]domain.Product
 }
 
 // NewMemoryRepository is a function that loads the db into the repository
-// because we still don't have a db sql connection
+// because we still don't have a db sql connection. It panics if two products
+// of the db have the same code value, the db is the data of the program.
 func NewMemoryRepository(db []domain.Product) Repository {
-	return &repository{db: db}
+	r, err := newRepository(db)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// newRepository is a function that loads the db into a repository, it
+// returns a ConflictError if two products of the db have the same code value
+func newRepository(db []domain.Product) (*repository, error) {
+	r := &repository{
+		db:    make(map[string]domain.Product, len(db)),
+		codes: make(map[string]string, len(db)),
+		trash: make(map[string]domain.Product),
+	}
+	now := time.Now().UTC()
+	for _, product := range db {
+		// Products stored before versioning start at the first version
+		if product.Version == 0 {
+			product.Version = 1
+		}
+		if product.UpdatedAt.IsZero() {
+			product.UpdatedAt = now
+		}
+		if err := r.load(product); err != nil {
+			return nil, fmt.Errorf("loading product %s: %w", product.Id, err)
+		}
+	}
+
+	return r, nil
 }
 
 // Create is a function that creates a new Product in the db
 func (r *repository) Create(ctx context.Context, product domain.Product) (domain.Product, error) {
-	r.db = append(r.db, product)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.db[product.Id]; ok {
+		return domain.Product{}, &ConflictError{Field: "id", Value: product.Id}
+	}
+
+	if _, ok := r.trash[product.Id]; ok {
+		return domain.Product{}, &ConflictError{Field: "id", Value: product.Id}
+	}
+
+	if _, ok := r.codes[product.CodeValue]; ok {
+		return domain.Product{}, &ConflictError{Field: "code_value", Value: product.CodeValue}
+	}
+
+	product.Version = 1
+	product.UpdatedAt = time.Now().UTC()
+	product.DeletedAt = nil
+	r.db[product.Id] = product
+	r.codes[product.CodeValue] = product.Id
+	r.insertID(product.Id)
 	return product, nil
 }
 
 // GetAll is a function that returns all the products in the db
 func (r *repository) GetAll(ctx context.Context) ([]domain.Product, error) {
-	if len(r.db) < 1 {
+	listProducts := r.all()
+	if len(listProducts) < 1 {
 		return []domain.Product{}, ErrEmpty
 	}
 
-	return r.db, nil
+	return listProducts, nil
+}
+
+// List is a function that returns a page of the products in the db that
+// meet the filter of the query, in its order
+func (r *repository) List(ctx context.Context, query Query) (Page, error) {
+	return listPage(r.all(), query)
+}
+
+// Trash is a function that returns a page of the deleted products that meet
+// the filter of the query, in its order
+func (r *repository) Trash(ctx context.Context, query Query) (Page, error) {
+	r.mu.RLock()
+	listProducts := make([]domain.Product, 0, len(r.trash))
+	for _, product := range r.trash {
+		listProducts = append(listProducts, product)
+	}
+	r.mu.RUnlock()
+
+	return listPage(listProducts, query)
+}
+
+// listPage is a function that filters, sorts and cuts the page of the query
+// out of listProducts, which it's free to reorder
+func listPage(listProducts []domain.Product, query Query) (Page, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	// We filter and sort a copy so the lock isn't held while sorting
+	matching := []domain.Product{}
+	for _, product := range listProducts {
+		if query.Filter.Matches(product) {
+			matching = append(matching, product)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return compareProducts(matching[i], matching[j], query.Sort) < 0
+	})
+
+	// The page after a cursor starts with the first product sorted after it
+	start := query.Offset
+	if query.Cursor != "" {
+		after, _ := query.decodeCursor()
+		start = sort.Search(len(matching), func(i int) bool {
+			return compareProducts(matching[i], after.After, query.Sort) > 0
+		})
+	}
+	start = min(start, len(matching))
+	end := min(start+query.Limit, len(matching))
+
+	page := Page{Items: matching[start:end], Total: len(matching)}
+	if end < len(matching) && end > start {
+		page.NextCursor = query.encodeCursor(matching[end-1])
+	}
+
+	return page, nil
 }
 
 // GetByID is a function that returns a Product by id from the db
 func (r *repository) GetByID(ctx context.Context, id string) (domain.Product, error) {
-	var result domain.Product
-	for _, value := range r.db {
-		if value.Id == id {
-			result = value
-			break
-		}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.db[id]
+	if !ok {
+		return domain.Product{}, ErrNotFound
 	}
 
-	if result.Id == "" {
+	return product, nil
+}
+
+// GetByCode is a function that returns a Product by code value from the db
+func (r *repository) GetByCode(ctx context.Context, code string) (domain.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.codes[code]
+	if !ok {
 		return domain.Product{}, ErrNotFound
 	}
 
-	return result, nil
+	return r.db[id], nil
 }
 
 // Update is a function that updates a Product by id from the db
@@ -71,38 +245,238 @@ func (r *repository) Update(
 	product domain.Product,
 	id string) (domain.Product, error) {
 
-	var result domain.Product
-	for key, value := range r.db {
-		if value.Id == id {
-			product.Id = id
-			r.db[key] = product
-			result = r.db[key]
-			break
-		}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, ok := r.db[id]
+	if !ok {
+		return domain.Product{}, ErrNotFound
+	}
+
+	if product.Version != 0 && product.Version != previous.Version {
+		return domain.Product{}, ErrVersionMismatch
 	}
 
-	if result.Id == "" {
+	if owner, ok := r.codes[product.CodeValue]; ok && owner != id {
+		return domain.Product{}, &ConflictError{Field: "code_value", Value: product.CodeValue}
+	}
+
+	product.Id = id
+	product.Version = previous.Version + 1
+	product.UpdatedAt = time.Now().UTC()
+	product.DeletedAt = nil
+	delete(r.codes, previous.CodeValue)
+	r.codes[product.CodeValue] = id
+	r.db[id] = product
+
+	return product, nil
+}
+
+// Delete is a function that moves a Product by id from the db to the trash
+// and returns it as it's in the trash
+func (r *repository) Delete(ctx context.Context, id string, version int64) (domain.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, ok := r.db[id]
+	if !ok {
 		return domain.Product{}, ErrNotFound
 	}
 
-	return result, nil
+	if version != 0 && version != product.Version {
+		return domain.Product{}, ErrVersionMismatch
+	}
 
+	now := time.Now().UTC()
+	product.DeletedAt = &now
+	product.Version++
+	product.UpdatedAt = now
+
+	r.delete(id)
+	r.trash[id] = product
+	return product, nil
+}
+
+// GetTrashed is a function that returns a Product by id from the trash
+func (r *repository) GetTrashed(ctx context.Context, id string) (domain.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.trash[id]
+	if !ok {
+		return domain.Product{}, ErrNotFound
+	}
+
+	return product, nil
 }
 
-// Delete is a function that deletes a Product by id from the db
-func (r *repository) Delete(ctx context.Context, id string) error {
-	var result domain.Product
-	for key, value := range r.db {
-		if value.Id == id {
-			result = r.db[key]
-			r.db = append(r.db[:key], r.db[key+1:]...)
-			break
+// Restore is a function that moves a Product by id from the trash back to the db
+func (r *repository) Restore(ctx context.Context, id string) (domain.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, ok := r.trash[id]
+	if !ok {
+		return domain.Product{}, ErrNotFound
+	}
+
+	if _, ok := r.codes[product.CodeValue]; ok {
+		return domain.Product{}, &ConflictError{Field: "code_value", Value: product.CodeValue}
+	}
+
+	product.DeletedAt = nil
+	product.Version++
+	product.UpdatedAt = time.Now().UTC()
+
+	delete(r.trash, id)
+	r.db[id] = product
+	r.codes[product.CodeValue] = id
+	r.insertID(id)
+	return product, nil
+}
+
+// Purge is a function that permanently deletes the products that were moved
+// to the trash before the given time and returns them
+func (r *repository) Purge(ctx context.Context, before time.Time) ([]domain.Product, error) {
+	return r.purge(before), nil
+}
+
+// purge is a function that permanently deletes the products that were moved
+// to the trash before the given time and returns them
+func (r *repository) purge(before time.Time) []domain.Product {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged []domain.Product
+	for id, product := range r.trash {
+		if product.DeletedAt.Before(before) {
+			delete(r.trash, id)
+			purged = append(purged, product)
 		}
 	}
 
-	if result.Id == "" {
-		return ErrNotFound
+	return purged
+}
+
+// Stats is a function that returns the figures of the products in the db
+func (r *repository) Stats(ctx context.Context) (Stats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := Stats{Products: len(r.db)}
+	for _, product := range r.db {
+		if product.IsPublished {
+			stats.Published++
+		}
+		stats.InventoryValue += product.Price * float64(product.Quantity)
+	}
+
+	return stats, nil
+}
+
+// trashed is a function that returns a Product by id from the trash
+func (r *repository) trashed(id string) (domain.Product, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.trash[id]
+	return product, ok
+}
+
+// all is a function that returns a copy of every product, even if there are none
+func (r *repository) all() []domain.Product {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// We return a copy so later writes don't race with the caller
+	result := make([]domain.Product, 0, len(r.ids))
+	for _, id := range r.ids {
+		result = append(result, r.db[id])
+	}
+
+	return result
+}
+
+// everything is a function that returns a copy of every product, the ones
+// in the trash included
+func (r *repository) everything() []domain.Product {
+	result := r.all()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, product := range r.trash {
+		result = append(result, product)
+	}
+
+	return result
+}
+
+// put is a function that stores the product as it is, replacing the one with
+// the same id. A product with DeletedAt goes to the trash. It's used to
+// restore a previous state of the db.
+func (r *repository) put(product domain.Product) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.db[product.Id]; ok {
+		r.delete(product.Id)
+	}
+	delete(r.trash, product.Id)
+
+	if product.DeletedAt != nil {
+		r.trash[product.Id] = product
+		return
 	}
 
+	r.db[product.Id] = product
+	r.codes[product.CodeValue] = product.Id
+	r.insertID(product.Id)
+}
+
+// load is a function that stores the product like put, unless another
+// product that is not in the trash has its code value. Otherwise the index of
+// the codes would point to only one of them.
+func (r *repository) load(product domain.Product) error {
+	r.mu.RLock()
+	id, taken := r.codes[product.CodeValue]
+	r.mu.RUnlock()
+
+	if product.DeletedAt == nil && taken && id != product.Id {
+		return &ConflictError{Field: "code_value", Value: product.CodeValue}
+	}
+
+	r.put(product)
 	return nil
 }
+
+// remove is a function that permanently deletes the product with the id if
+// it exists, from the db or the trash
+func (r *repository) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.db[id]; ok {
+		r.delete(id)
+	}
+	delete(r.trash, id)
+}
+
+// insertID adds the id to ids keeping them sorted. It must be called with mu held.
+func (r *repository) insertID(id string) {
+	key := sort.SearchStrings(r.ids, id)
+	r.ids = append(r.ids, "")
+	copy(r.ids[key+1:], r.ids[key:])
+	r.ids[key] = id
+}
+
+// delete removes the product from the indexes of the db. It must be called with mu held.
+func (r *repository) delete(id string) {
+	delete(r.codes, r.db[id].CodeValue)
+	delete(r.db, id)
+
+	key := sort.SearchStrings(r.ids, id)
+	if key < len(r.ids) && r.ids[key] == id {
+		r.ids = append(r.ids[:key], r.ids[key+1:]...)
+	}
+}
@@ -0,0 +1,374 @@
+package products
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/filestore"
+)
+
+// Operations that can be written in the journal
+const (
+	journalPut    = "put"
+	journalDelete = "delete"
+)
+
+// errInvalidJournalEntry is returned for an entry of the journal that is not a put or a delete
+var errInvalidJournalEntry = errors.New("invalid entry")
+
+// DefaultSnapshotInterval is the snapshot interval used when none is configured
+const DefaultSnapshotInterval = time.Minute
+
+// JournalEntry is a struct that represents a write in the journal.
+// A put stores the whole product so replaying an entry twice is harmless, a
+// put of a product with DeletedAt moves it to the trash and a delete removes
+// it for good.
+type JournalEntry struct {
+	Op      string          `json:"op"`
+	Product *domain.Product `json:"product,omitempty"`
+	Id      string          `json:"id,omitempty"`
+}
+
+// FileConfig is a struct that contains the configuration of a FileRepository
+type FileConfig struct {
+	// SnapshotPath is the JSON file with the products, the journal is
+	// written next to it with the .journal extension
+	SnapshotPath string
+
+	// SnapshotInterval is how often the journal is compacted into a new snapshot
+	SnapshotInterval time.Duration
+}
+
+// JournalPath is a function that returns the path of the journal of the config
+func (c FileConfig) JournalPath() string {
+	return c.SnapshotPath + ".journal"
+}
+
+// FileRepository is a memory repository that persists its products in a JSON
+// snapshot plus an append-only journal with every write since that snapshot.
+//
+// Every write is applied in memory and then appended and synced to the
+// journal before returning, so a crash between snapshots loses nothing.
+// Snapshots are written to a temporary file and renamed over the previous
+// one, so a crash in the middle of a snapshot leaves the old one intact.
+type FileRepository struct {
+	memory *repository
+
+	// mu serializes the writes so the journal keeps the order of the memory
+	mu      sync.Mutex
+	config  FileConfig
+	journal *filestore.Log
+	dirty   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// FileRepository must keep implementing every method of Repository
+var _ Repository = (*FileRepository)(nil)
+
+// NewFileRepository is a function that loads the snapshot and replays the
+// journal of the config into a FileRepository. It starts taking snapshots
+// every SnapshotInterval until Close is called.
+func NewFileRepository(config FileConfig) (*FileRepository, error) {
+	if config.SnapshotInterval <= 0 {
+		config.SnapshotInterval = DefaultSnapshotInterval
+	}
+
+	db, err := LoadSnapshot(config.SnapshotPath)
+	if err != nil {
+		return nil, err
+	}
+
+	memory, err := newRepository(db)
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", config.SnapshotPath, err)
+	}
+
+	// We replay the writes since the snapshot. A last line without a newline
+	// is a write that was interrupted by a crash, and therefore never
+	// acknowledged, so the log cuts it.
+	journal, err := filestore.OpenLog(config.JournalPath(), 0o644, memory.replay)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileRepository{
+		memory:  memory,
+		config:  config,
+		journal: journal,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	// We compact what we replayed right away so the journal starts empty
+	if err := f.Snapshot(); err != nil {
+		journal.Close()
+		return nil, err
+	}
+
+	go f.snapshotLoop()
+
+	return f, nil
+}
+
+// LoadSnapshot is a function that reads the products of a snapshot file.
+// A missing file is an empty db.
+func LoadSnapshot(path string) ([]domain.Product, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []domain.Product{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var db []domain.Product
+	if err := json.Unmarshal(content, &db); err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", path, err)
+	}
+
+	return db, nil
+}
+
+// replay is a function that applies an entry of the journal to the repository
+func (r *repository) replay(entry JournalEntry) error {
+	switch {
+	case entry.Op == journalPut && entry.Product != nil:
+		return r.load(*entry.Product)
+	case entry.Op == journalDelete:
+		r.remove(entry.Id)
+		return nil
+	default:
+		return errInvalidJournalEntry
+	}
+}
+
+// snapshotLoop takes a snapshot every SnapshotInterval until Close is called
+func (f *FileRepository) snapshotLoop() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.config.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.Snapshot(); err != nil {
+				slog.Error("[FileRepository][Snapshot] error taking snapshot", "error", err)
+			}
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// Snapshot is a function that writes every product into the snapshot file
+// and empties the journal. It does nothing if there were no writes since
+// the last snapshot.
+func (f *FileRepository) Snapshot() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.dirty && f.journal.Size() == 0 {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(f.memory.everything(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := filestore.WriteAtomic(f.config.SnapshotPath, content, 0o644); err != nil {
+		return err
+	}
+
+	// The snapshot already has every write of the journal. If we crash
+	// before truncating it the entries are replayed over the new snapshot,
+	// which gives the same result.
+	if err := f.journal.Truncate(); err != nil {
+		return err
+	}
+
+	f.dirty = false
+	return nil
+}
+
+// Close is a function that stops the snapshots, takes a last one and closes the journal
+func (f *FileRepository) Close() error {
+	close(f.stop)
+	<-f.done
+
+	if err := f.Snapshot(); err != nil {
+		f.journal.Close()
+		return err
+	}
+
+	return f.journal.Close()
+}
+
+// appendJournal writes the entry at the end of the journal and syncs it.
+// It must be called with mu held.
+func (f *FileRepository) appendJournal(entry JournalEntry) error {
+	if err := f.journal.Append(entry); err != nil {
+		return err
+	}
+
+	f.dirty = true
+	return nil
+}
+
+// Create is a function that creates a new Product in the db and the journal
+func (f *FileRepository) Create(ctx context.Context, product domain.Product) (domain.Product, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	product, err := f.memory.Create(ctx, product)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	if err := f.appendJournal(JournalEntry{Op: journalPut, Product: &product}); err != nil {
+		// We undo the write so the memory doesn't get ahead of the disk
+		f.memory.remove(product.Id)
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+// GetAll is a function that returns all the products in the db
+func (f *FileRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
+	return f.memory.GetAll(ctx)
+}
+
+// List is a function that returns a page of the products in the db sorted by id
+func (f *FileRepository) List(ctx context.Context, query Query) (Page, error) {
+	return f.memory.List(ctx, query)
+}
+
+// Stats is a function that returns the figures of the products in the db
+func (f *FileRepository) Stats(ctx context.Context) (Stats, error) {
+	return f.memory.Stats(ctx)
+}
+
+// GetByID is a function that returns a Product by id from the db
+func (f *FileRepository) GetByID(ctx context.Context, id string) (domain.Product, error) {
+	return f.memory.GetByID(ctx, id)
+}
+
+// GetByCode is a function that returns a Product by code value from the db
+func (f *FileRepository) GetByCode(ctx context.Context, code string) (domain.Product, error) {
+	return f.memory.GetByCode(ctx, code)
+}
+
+// Update is a function that updates a Product by id in the db and the journal
+func (f *FileRepository) Update(
+	ctx context.Context,
+	product domain.Product,
+	id string) (domain.Product, error) {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, err := f.memory.GetByID(ctx, id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	product, err = f.memory.Update(ctx, product, id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	if err := f.appendJournal(JournalEntry{Op: journalPut, Product: &product}); err != nil {
+		f.memory.put(previous)
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+// Delete is a function that moves a Product by id to the trash and writes it in the journal
+func (f *FileRepository) Delete(ctx context.Context, id string, version int64) (domain.Product, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, err := f.memory.GetByID(ctx, id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	product, err := f.memory.Delete(ctx, id, version)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	if err := f.appendJournal(JournalEntry{Op: journalPut, Product: &product}); err != nil {
+		f.memory.put(previous)
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+// Trash is a function that returns a page of the deleted products
+func (f *FileRepository) Trash(ctx context.Context, query Query) (Page, error) {
+	return f.memory.Trash(ctx, query)
+}
+
+// GetTrashed is a function that returns a Product by id from the trash
+func (f *FileRepository) GetTrashed(ctx context.Context, id string) (domain.Product, error) {
+	return f.memory.GetTrashed(ctx, id)
+}
+
+// Restore is a function that moves a Product by id from the trash back to the
+// db and writes it in the journal
+func (f *FileRepository) Restore(ctx context.Context, id string) (domain.Product, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, ok := f.memory.trashed(id)
+	if !ok {
+		return domain.Product{}, ErrNotFound
+	}
+
+	product, err := f.memory.Restore(ctx, id)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	if err := f.appendJournal(JournalEntry{Op: journalPut, Product: &product}); err != nil {
+		f.memory.put(previous)
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+// Purge is a function that permanently deletes the products moved to the
+// trash before the given time, writes them in the journal and returns them
+func (f *FileRepository) Purge(ctx context.Context, before time.Time) ([]domain.Product, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	purged := f.memory.purge(before)
+	for i, product := range purged {
+		if err := f.appendJournal(JournalEntry{Op: journalDelete, Id: product.Id}); err != nil {
+			// The ones already in the journal are gone, we put back the rest
+			for _, pending := range purged[i:] {
+				f.memory.put(pending)
+			}
+			return purged[:i], err
+		}
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,219 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statsTimeout bounds the query of the catalog figures made on every scrape
+const statsTimeout = 5 * time.Second
+
+// instrumentedRepository is a struct that contains a Repository and the
+// metrics of its operations: how long each method takes and how it fails
+type instrumentedRepository struct {
+	repository Repository
+	duration   *prometheus.HistogramVec
+	errors     *prometheus.CounterVec
+}
+
+// NewInstrumentedRepository is a function that returns the repository with
+// the duration and the errors of every method registered in the registerer.
+// The errors are counted by kind, so the expected ones like not_found can be
+// told apart from the ones of the storage.
+func NewInstrumentedRepository(repository Repository, registerer prometheus.Registerer) (Repository, error) {
+	r := &instrumentedRepository{
+		repository: repository,
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "products_repository_operation_duration_seconds",
+			Help:    "Duration of the operations of the products repository by method.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "products_repository_errors_total",
+			Help: "Operations of the products repository that returned an error by method and kind.",
+		}, []string{"method", "kind"}),
+	}
+
+	if err := registerer.Register(r.duration); err != nil {
+		return nil, err
+	}
+	if err := registerer.Register(r.errors); err != nil {
+		registerer.Unregister(r.duration)
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// observe is a function that records an operation of the method started at start
+func (r *instrumentedRepository) observe(method string, start time.Time, err error) {
+	r.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		r.errors.WithLabelValues(method, errorKind(err)).Inc()
+	}
+}
+
+// errorKind is a function that returns the label of the error of an operation
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrEmpty):
+		return "empty"
+	case errors.Is(err, ErrConflict):
+		return "conflict"
+	case errors.Is(err, ErrVersionMismatch):
+		return "version_mismatch"
+	case errors.Is(err, ErrInvalidQuery):
+		return "invalid_query"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	default:
+		return "internal"
+	}
+}
+
+// Create is a function that creates a Product in the db
+func (r *instrumentedRepository) Create(ctx context.Context, product domain.Product) (domain.Product, error) {
+	start := time.Now()
+	created, err := r.repository.Create(ctx, product)
+	r.observe("Create", start, err)
+	return created, err
+}
+
+// GetAll is a function that returns all the Products of the db
+func (r *instrumentedRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
+	start := time.Now()
+	listProducts, err := r.repository.GetAll(ctx)
+	r.observe("GetAll", start, err)
+	return listProducts, err
+}
+
+// List is a function that returns a page of the Products of the db
+func (r *instrumentedRepository) List(ctx context.Context, query Query) (Page, error) {
+	start := time.Now()
+	page, err := r.repository.List(ctx, query)
+	r.observe("List", start, err)
+	return page, err
+}
+
+// GetByID is a function that returns a Product by id from the db
+func (r *instrumentedRepository) GetByID(ctx context.Context, id string) (domain.Product, error) {
+	start := time.Now()
+	product, err := r.repository.GetByID(ctx, id)
+	r.observe("GetByID", start, err)
+	return product, err
+}
+
+// GetByCode is a function that returns a Product by code value from the db
+func (r *instrumentedRepository) GetByCode(ctx context.Context, code string) (domain.Product, error) {
+	start := time.Now()
+	product, err := r.repository.GetByCode(ctx, code)
+	r.observe("GetByCode", start, err)
+	return product, err
+}
+
+// Update is a function that updates a Product by id in the db
+func (r *instrumentedRepository) Update(ctx context.Context, product domain.Product, id string) (domain.Product, error) {
+	start := time.Now()
+	updated, err := r.repository.Update(ctx, product, id)
+	r.observe("Update", start, err)
+	return updated, err
+}
+
+// Delete is a function that moves a Product by id to the trash
+func (r *instrumentedRepository) Delete(ctx context.Context, id string, version int64) (domain.Product, error) {
+	start := time.Now()
+	deleted, err := r.repository.Delete(ctx, id, version)
+	r.observe("Delete", start, err)
+	return deleted, err
+}
+
+// Trash is a function that returns a page of the Products in the trash
+func (r *instrumentedRepository) Trash(ctx context.Context, query Query) (Page, error) {
+	start := time.Now()
+	page, err := r.repository.Trash(ctx, query)
+	r.observe("Trash", start, err)
+	return page, err
+}
+
+// GetTrashed is a function that returns a Product by id from the trash
+func (r *instrumentedRepository) GetTrashed(ctx context.Context, id string) (domain.Product, error) {
+	start := time.Now()
+	product, err := r.repository.GetTrashed(ctx, id)
+	r.observe("GetTrashed", start, err)
+	return product, err
+}
+
+// Restore is a function that brings a Product by id back from the trash
+func (r *instrumentedRepository) Restore(ctx context.Context, id string) (domain.Product, error) {
+	start := time.Now()
+	restored, err := r.repository.Restore(ctx, id)
+	r.observe("Restore", start, err)
+	return restored, err
+}
+
+// Purge is a function that removes the Products trashed before the time for good
+func (r *instrumentedRepository) Purge(ctx context.Context, before time.Time) ([]domain.Product, error) {
+	start := time.Now()
+	purged, err := r.repository.Purge(ctx, before)
+	r.observe("Purge", start, err)
+	return purged, err
+}
+
+// Stats is a function that returns the figures of the products in the db
+func (r *instrumentedRepository) Stats(ctx context.Context) (Stats, error) {
+	start := time.Now()
+	stats, err := r.repository.Stats(ctx)
+	r.observe("Stats", start, err)
+	return stats, err
+}
+
+// catalogCollector is a struct that contains the repository whose figures are
+// exported as gauges, they are read from it on every scrape
+type catalogCollector struct {
+	repository Repository
+	products   *prometheus.Desc
+	published  *prometheus.Desc
+	value      *prometheus.Desc
+}
+
+// NewCatalogCollector is a function that returns a collector of the size of
+// the catalog, the published products and the total inventory value
+func NewCatalogCollector(repository Repository) prometheus.Collector {
+	return &catalogCollector{
+		repository: repository,
+		products:   prometheus.NewDesc("products_catalog_size", "Products in the catalog, the trashed ones are not counted.", nil, nil),
+		published:  prometheus.NewDesc("products_catalog_published", "Published products in the catalog.", nil, nil),
+		value:      prometheus.NewDesc("products_catalog_inventory_value", "Sum of price times quantity of the products in the catalog.", nil, nil),
+	}
+}
+
+// Describe is a function that sends the descriptions of the gauges
+func (c *catalogCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.products
+	ch <- c.published
+	ch <- c.value
+}
+
+// Collect is a function that reads the figures of the catalog and sends them.
+// When the repository fails the gauges are left out of the scrape.
+func (c *catalogCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+
+	stats, err := c.repository.Stats(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "[CatalogCollector][Collect] error getting the stats", "error", err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.products, prometheus.GaugeValue, float64(stats.Products))
+	ch <- prometheus.MustNewConstMetric(c.published, prometheus.GaugeValue, float64(stats.Published))
+	ch <- prometheus.MustNewConstMetric(c.value, prometheus.GaugeValue, stats.InventoryValue)
+}
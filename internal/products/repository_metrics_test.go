@@ -0,0 +1,88 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentedRepository(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+
+	memory := NewMemoryRepository([]domain.Product{newTestProduct("1")})
+	repository, err := NewInstrumentedRepository(memory, registry)
+	if err != nil {
+		t.Fatalf("new instrumented repository: %v", err)
+	}
+
+	// The repository behaves like the one it wraps
+	contract, err := NewInstrumentedRepository(NewMemoryRepository(nil), prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("new instrumented repository: %v", err)
+	}
+	testRepositoryContract(t, contract)
+
+	if _, err := repository.GetByID(ctx, "1"); err != nil {
+		t.Fatalf("get by id: %v", err)
+	}
+	if _, err := repository.GetByID(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing: got %v, want ErrNotFound", err)
+	}
+	if _, err := repository.Create(ctx, newTestProduct("1")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("create duplicate: got %v, want ErrConflict", err)
+	}
+
+	if count := testutil.CollectAndCount(registry, "products_repository_operation_duration_seconds"); count != 2 {
+		t.Fatalf("got %d duration series, want one per method called", count)
+	}
+
+	want := `
+# HELP products_repository_errors_total Operations of the products repository that returned an error by method and kind.
+# TYPE products_repository_errors_total counter
+products_repository_errors_total{kind="conflict",method="Create"} 1
+products_repository_errors_total{kind="not_found",method="GetByID"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "products_repository_errors_total"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second registration in the same registry fails instead of panicking
+	if _, err := NewInstrumentedRepository(memory, registry); err == nil {
+		t.Fatal("registering twice: got no error")
+	}
+}
+
+func TestCatalogCollector(t *testing.T) {
+	ctx := context.Background()
+
+	draft := newTestProduct("2")
+	draft.IsPublished = false
+	draft.Price = 2
+	repository := NewMemoryRepository([]domain.Product{newTestProduct("1"), draft, newTestProduct("3")})
+
+	// The trashed products are not in the catalog
+	if _, err := repository.Delete(ctx, "3", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	want := `
+# HELP products_catalog_inventory_value Sum of price times quantity of the products in the catalog.
+# TYPE products_catalog_inventory_value gauge
+products_catalog_inventory_value 125
+# HELP products_catalog_published Published products in the catalog.
+# TYPE products_catalog_published gauge
+products_catalog_published 1
+# HELP products_catalog_size Products in the catalog, the trashed ones are not counted.
+# TYPE products_catalog_size gauge
+products_catalog_size 2
+`
+	if err := testutil.CollectAndCompare(NewCatalogCollector(repository), strings.NewReader(want)); err != nil {
+		t.Fatal(err)
+	}
+}
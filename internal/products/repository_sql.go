@@ -0,0 +1,562 @@
+package products
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// pgUniqueViolation is the SQLSTATE of Postgres for unique constraint violations
+const pgUniqueViolation = "23505"
+
+// Dialect is the SQL flavour spoken by the db behind a sqlRepository
+type Dialect string
+
+// Dialects supported by NewSQLRepository
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+)
+
+// productColumns is the list of columns that maps a domain.Product, in the
+// same order that scanProduct reads them
+const productColumns = "id, name, quantity, code_value, is_published, expiration, price, version, updated_at, deleted_at"
+
+// sqlRepository is a struct that contains the sql connection of the products db.
+// The schema is created by the internal/migrations package.
+type sqlRepository struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// NewSQLRepository is a function that loads the sql connection into the repository
+func NewSQLRepository(db *sql.DB, dialect Dialect) Repository {
+	return &sqlRepository{db: db, dialect: dialect}
+}
+
+// rebind is a function that rewrites the ? placeholders of the query into
+// the bind parameters of the dialect
+func (r *sqlRepository) rebind(query string) string {
+	return r.dialect.Rebind(query)
+}
+
+// Rebind is a function that rewrites the ? placeholders of the query into
+// the bind parameters of the dialect, $1, $2... for Postgres
+func (d Dialect) Rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, char := range query {
+		if char == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(char)
+	}
+
+	return builder.String()
+}
+
+// RowScanner is implemented by *sql.Row and *sql.Rows, the stores that share
+// the connection of the products use it to read a row either way
+type RowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanProduct is a function that reads a row with productColumns into a Product
+func scanProduct(row RowScanner) (domain.Product, error) {
+	var product domain.Product
+	var deletedAt sql.NullTime
+	err := row.Scan(
+		&product.Id,
+		&product.Name,
+		&product.Quantity,
+		&product.CodeValue,
+		&product.IsPublished,
+		&product.Expiration,
+		&product.Price,
+		&product.Version,
+		&product.UpdatedAt,
+		&deletedAt,
+	)
+	if deletedAt.Valid {
+		product.DeletedAt = &deletedAt.Time
+	}
+	return product, err
+}
+
+// conflictError is a function that translates the unique violations of the
+// drivers into a ConflictError and returns any other error untouched
+func conflictError(err error, product domain.Product) error {
+	detail, ok := UniqueViolation(err)
+	if !ok {
+		return err
+	}
+
+	if strings.Contains(detail, "code_value") {
+		return &ConflictError{Field: "code_value", Value: product.CodeValue}
+	}
+
+	return &ConflictError{Field: "id", Value: product.Id}
+}
+
+// UniqueViolation is a function that reports if err is a unique violation
+// of SQLite or Postgres, with a detail that names the column or the index
+// that was violated
+func UniqueViolation(err error) (string, bool) {
+	var sqliteErr *sqlite.Error
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.As(err, &sqliteErr):
+		if sqliteErr.Code() != sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY &&
+			sqliteErr.Code() != sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return "", false
+		}
+		return sqliteErr.Error(), true
+	case errors.As(err, &pgErr):
+		if pgErr.Code != pgUniqueViolation {
+			return "", false
+		}
+		return pgErr.ConstraintName, true
+	default:
+		return "", false
+	}
+}
+
+// Create is a function that creates a new Product in the db
+func (r *sqlRepository) Create(ctx context.Context, product domain.Product) (domain.Product, error) {
+	product.Version = 1
+	product.UpdatedAt = time.Now().UTC()
+	product.DeletedAt = nil
+
+	_, err := r.db.ExecContext(ctx,
+		r.rebind("INSERT INTO products ("+productColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		product.Id,
+		product.Name,
+		product.Quantity,
+		product.CodeValue,
+		product.IsPublished,
+		product.Expiration.UTC(),
+		product.Price,
+		product.Version,
+		product.UpdatedAt,
+		product.DeletedAt,
+	)
+	if err != nil {
+		return domain.Product{}, conflictError(err, product)
+	}
+
+	return product, nil
+}
+
+// GetAll is a function that returns all the products in the db
+func (r *sqlRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+productColumns+" FROM products WHERE deleted_at IS NULL ORDER BY id")
+	if err != nil {
+		return []domain.Product{}, err
+	}
+	defer rows.Close()
+
+	listProducts := []domain.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return []domain.Product{}, err
+		}
+		listProducts = append(listProducts, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return []domain.Product{}, err
+	}
+
+	if len(listProducts) < 1 {
+		return []domain.Product{}, ErrEmpty
+	}
+
+	return listProducts, nil
+}
+
+// List is a function that returns a page of the products in the db that
+// meet the filter of the query, in its order
+func (r *sqlRepository) List(ctx context.Context, query Query) (Page, error) {
+	return r.list(ctx, query, "deleted_at IS NULL")
+}
+
+// Trash is a function that returns a page of the deleted products that meet
+// the filter of the query, in its order
+func (r *sqlRepository) Trash(ctx context.Context, query Query) (Page, error) {
+	return r.list(ctx, query, "deleted_at IS NOT NULL")
+}
+
+// list is a function that returns a page of the products that meet the scope
+// condition and the filter of the query. The page after a cursor is a keyset
+// condition on the sort columns instead of an offset.
+func (r *sqlRepository) list(ctx context.Context, query Query, scope string) (Page, error) {
+	query, err := query.Normalize()
+	if err != nil {
+		return Page{}, err
+	}
+
+	where, args := r.filterConditions(query.Filter)
+	where = append([]string{scope}, where...)
+
+	page := Page{Items: []domain.Product{}}
+	count := "SELECT COUNT(*) FROM products" + whereClause(where)
+	if err := r.db.QueryRowContext(ctx, r.rebind(count), args...).Scan(&page.Total); err != nil {
+		return Page{}, err
+	}
+
+	if query.Cursor != "" {
+		after, _ := query.decodeCursor()
+		condition, keysetArgs := keysetCondition(query.Sort, after.After)
+		where = append(where, condition)
+		args = append(args, keysetArgs...)
+	}
+
+	order := make([]string, 0, len(query.Sort))
+	for _, key := range query.Sort {
+		if key.Desc {
+			order = append(order, key.Field+" DESC")
+		} else {
+			order = append(order, key.Field)
+		}
+	}
+
+	// We ask for one more product to know if there is a next page
+	statement := "SELECT " + productColumns + " FROM products" + whereClause(where) +
+		" ORDER BY " + strings.Join(order, ", ") + " LIMIT ?"
+	args = append(args, query.Limit+1)
+	if query.Cursor == "" {
+		statement += " OFFSET ?"
+		args = append(args, query.Offset)
+	}
+
+	rows, err := r.db.QueryContext(ctx, r.rebind(statement), args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return Page{}, err
+		}
+		page.Items = append(page.Items, product)
+	}
+
+	if err := rows.Err(); err != nil {
+		return Page{}, err
+	}
+
+	if len(page.Items) > query.Limit {
+		page.Items = page.Items[:query.Limit]
+		page.NextCursor = query.encodeCursor(page.Items[len(page.Items)-1])
+	}
+
+	return page, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE so the name filter is a plain substring
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// filterConditions is a function that translates the filter into sql conditions
+func (r *sqlRepository) filterConditions(filter Filter) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	add := func(condition string, arg any) {
+		conditions = append(conditions, condition)
+		args = append(args, arg)
+	}
+
+	if filter.Name != "" {
+		// LIKE is already case insensitive in SQLite, Postgres needs ILIKE
+		like := "LIKE"
+		if r.dialect == DialectPostgres {
+			like = "ILIKE"
+		}
+		add("name "+like+` ? ESCAPE '\'`, "%"+likeEscaper.Replace(filter.Name)+"%")
+	}
+	if filter.IsPublished != nil {
+		add("is_published = ?", *filter.IsPublished)
+	}
+	if filter.MinPrice != nil {
+		add("price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		add("price <= ?", *filter.MaxPrice)
+	}
+	if filter.MinQuantity != nil {
+		add("quantity >= ?", *filter.MinQuantity)
+	}
+	if filter.MaxQuantity != nil {
+		add("quantity <= ?", *filter.MaxQuantity)
+	}
+	if filter.ExpiresBefore != nil {
+		add("expiration < ?", filter.ExpiresBefore.UTC())
+	}
+	if filter.ExpiresAfter != nil {
+		add("expiration > ?", filter.ExpiresAfter.UTC())
+	}
+
+	return conditions, args
+}
+
+// keysetCondition is a function that returns the condition of the products
+// sorted after the given one. For the keys a, -b, id it's
+// a > ? OR (a = ? AND b < ?) OR (a = ? AND b = ? AND id > ?)
+func keysetCondition(keys []SortKey, after domain.Product) (string, []any) {
+	var alternatives []string
+	var args []any
+
+	for i, key := range keys {
+		var parts []string
+		for _, previous := range keys[:i] {
+			parts = append(parts, previous.Field+" = ?")
+			args = append(args, sortValue(after, previous.Field))
+		}
+
+		operator := " > ?"
+		if key.Desc {
+			operator = " < ?"
+		}
+		parts = append(parts, key.Field+operator)
+		args = append(args, sortValue(after, key.Field))
+
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// sortValue is a function that returns the value of the sort field of the product
+func sortValue(product domain.Product, field string) any {
+	switch field {
+	case SortName:
+		return product.Name
+	case SortQuantity:
+		return product.Quantity
+	case SortCodeValue:
+		return product.CodeValue
+	case SortIsPublished:
+		return product.IsPublished
+	case SortExpiration:
+		return product.Expiration.UTC()
+	case SortPrice:
+		return product.Price
+	default:
+		return product.Id
+	}
+}
+
+// whereClause is a function that joins the conditions into a WHERE clause
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// Stats is a function that returns the figures of the products in the db
+func (r *sqlRepository) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*),
+		COALESCE(SUM(CASE WHEN is_published THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(price * quantity), 0)
+		FROM products WHERE deleted_at IS NULL`).Scan(&stats.Products, &stats.Published, &stats.InventoryValue)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+// GetByID is a function that returns a Product by id from the db
+func (r *sqlRepository) GetByID(ctx context.Context, id string) (domain.Product, error) {
+	row := r.db.QueryRowContext(ctx, r.rebind("SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NULL"), id)
+
+	product, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+// GetByCode is a function that returns a Product by code value from the db
+func (r *sqlRepository) GetByCode(ctx context.Context, code string) (domain.Product, error) {
+	row := r.db.QueryRowContext(ctx, r.rebind("SELECT "+productColumns+" FROM products WHERE code_value = ? AND deleted_at IS NULL"), code)
+
+	product, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+// Update is a function that updates a Product by id from the db. The
+// version is checked and bumped in the same statement so two concurrent
+// updates of the same version can't both succeed.
+func (r *sqlRepository) Update(
+	ctx context.Context,
+	product domain.Product,
+	id string) (domain.Product, error) {
+
+	product.UpdatedAt = time.Now().UTC()
+
+	row := r.db.QueryRowContext(ctx,
+		r.rebind(`UPDATE products
+		SET name = ?, quantity = ?, code_value = ?, is_published = ?, expiration = ?, price = ?,
+			version = version + 1, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+		RETURNING version`),
+		product.Name,
+		product.Quantity,
+		product.CodeValue,
+		product.IsPublished,
+		product.Expiration.UTC(),
+		product.Price,
+		product.UpdatedAt,
+		id,
+		product.Version,
+		product.Version,
+	)
+
+	var version int64
+	err := row.Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, r.missingError(ctx, id)
+	}
+	if err != nil {
+		return domain.Product{}, conflictError(err, product)
+	}
+
+	product.Id = id
+	product.Version = version
+	product.DeletedAt = nil
+	return product, nil
+}
+
+// Delete is a function that moves a Product by id from the db to the trash
+// and returns it as it's in the trash
+func (r *sqlRepository) Delete(ctx context.Context, id string, version int64) (domain.Product, error) {
+	now := time.Now().UTC()
+
+	row := r.db.QueryRowContext(ctx,
+		r.rebind(`UPDATE products SET deleted_at = ?, version = version + 1, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+		RETURNING `+productColumns),
+		now, now, id, version, version)
+
+	product, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, r.missingError(ctx, id)
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+// GetTrashed is a function that returns a Product by id from the trash
+func (r *sqlRepository) GetTrashed(ctx context.Context, id string) (domain.Product, error) {
+	row := r.db.QueryRowContext(ctx, r.rebind("SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NOT NULL"), id)
+
+	product, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	return product, nil
+}
+
+// Restore is a function that moves a Product by id from the trash back to the db
+func (r *sqlRepository) Restore(ctx context.Context, id string) (domain.Product, error) {
+	row := r.db.QueryRowContext(ctx,
+		r.rebind("SELECT "+productColumns+" FROM products WHERE id = ? AND deleted_at IS NOT NULL"), id)
+
+	product, err := scanProduct(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	// The unique index of the code value only covers the products out of the trash
+	product.UpdatedAt = time.Now().UTC()
+	row = r.db.QueryRowContext(ctx,
+		r.rebind(`UPDATE products SET deleted_at = NULL, version = version + 1, updated_at = ?
+		WHERE id = ? AND deleted_at IS NOT NULL
+		RETURNING version`),
+		product.UpdatedAt, id)
+
+	err = row.Scan(&product.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Product{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.Product{}, conflictError(err, product)
+	}
+
+	product.DeletedAt = nil
+	return product, nil
+}
+
+// Purge is a function that permanently deletes the products that were moved
+// to the trash before the given time and returns them
+func (r *sqlRepository) Purge(ctx context.Context, before time.Time) ([]domain.Product, error) {
+	rows, err := r.db.QueryContext(ctx,
+		r.rebind("DELETE FROM products WHERE deleted_at IS NOT NULL AND deleted_at < ? RETURNING "+productColumns), before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var purged []domain.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		purged = append(purged, product)
+	}
+
+	return purged, rows.Err()
+}
+
+// missingError is a function that explains why a write by id matched no
+// row: the product doesn't exist or its version is not the expected one
+func (r *sqlRepository) missingError(ctx context.Context, id string) error {
+	_, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
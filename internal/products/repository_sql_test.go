@@ -0,0 +1,397 @@
+package products
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/migrations"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// openMigratedDB opens the db and runs every migration of the dialect on it.
+// It also checks that the migrations can be reverted and applied again.
+func openMigratedDB(t *testing.T, driverName, dsn string, dialect Dialect) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	migrator, err := migrations.NewMigrator(db, string(dialect))
+	if err != nil {
+		t.Fatalf("migrator: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate up: %v", err)
+	}
+	if _, err := migrator.Down(ctx, migrator.Latest()); err != nil {
+		t.Fatalf("migrate down: %v", err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("migrate up again: %v", err)
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		t.Fatalf("version: %v", err)
+	}
+	if version != migrator.Latest() {
+		t.Fatalf("got version %d, want %d", version, migrator.Latest())
+	}
+
+	return db
+}
+
+// clean is a function that deletes every product of the db
+func clean(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	if _, err := db.Exec("DELETE FROM products"); err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+}
+
+// testRepositoryContract checks the behaviour every Repository implementation must share
+func testRepositoryContract(t *testing.T, repository Repository) {
+	ctx := context.Background()
+
+	if _, err := repository.GetAll(ctx); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("get all on empty repository: got %v, want ErrEmpty", err)
+	}
+
+	product := newTestProduct("1")
+	if _, err := repository.Create(ctx, product); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := repository.Create(ctx, product); !errors.Is(err, ErrConflict) {
+		t.Fatalf("create duplicate id: got %v, want ErrConflict", err)
+	}
+
+	sameCode := newTestProduct("2")
+	sameCode.CodeValue = product.CodeValue
+	if _, err := repository.Create(ctx, sameCode); !errors.Is(err, ErrConflict) {
+		t.Fatalf("create duplicate code value: got %v, want ErrConflict", err)
+	}
+
+	if stats, err := repository.Stats(ctx); err != nil || stats != (Stats{Products: 1, Published: 1, InventoryValue: 105}) {
+		t.Fatalf("stats: got %+v, %v", stats, err)
+	}
+
+	byCode, err := repository.GetByCode(ctx, product.CodeValue)
+	if err != nil || byCode.Id != "1" {
+		t.Fatalf("get by code: got %+v, %v", byCode, err)
+	}
+
+	stored, err := repository.GetByID(ctx, "1")
+	if err != nil {
+		t.Fatalf("get by id: %v", err)
+	}
+
+	if stored.Name != product.Name ||
+		stored.Quantity != product.Quantity ||
+		stored.CodeValue != product.CodeValue ||
+		stored.IsPublished != product.IsPublished ||
+		!stored.Expiration.Equal(product.Expiration) ||
+		stored.Price != product.Price ||
+		stored.Version != 1 ||
+		stored.UpdatedAt.IsZero() {
+		t.Fatalf("got %+v, want %+v", stored, product)
+	}
+
+	product.Name = "Updated"
+	product.IsPublished = false
+	product.Version = 1
+	updated, err := repository.Update(ctx, product, "1")
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Name != "Updated" || updated.Id != "1" || updated.Version != 2 ||
+		updated.UpdatedAt.Before(stored.UpdatedAt) {
+		t.Fatalf("got %+v after update", updated)
+	}
+
+	// The version 1 is stale now, for updates and deletes
+	if _, err := repository.Update(ctx, product, "1"); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("update stale version: got %v, want ErrVersionMismatch", err)
+	}
+	if _, err := repository.Delete(ctx, "1", 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("delete stale version: got %v, want ErrVersionMismatch", err)
+	}
+
+	product.Version = 0
+	if updated, err := repository.Update(ctx, product, "1"); err != nil || updated.Version != 3 {
+		t.Fatalf("unconditional update: got %+v, %v", updated, err)
+	}
+
+	if _, err := repository.Update(ctx, product, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("update missing: got %v, want ErrNotFound", err)
+	}
+
+	listProducts, err := repository.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	if len(listProducts) != 1 || listProducts[0].IsPublished {
+		t.Fatalf("got %+v from get all", listProducts)
+	}
+
+	deleted, err := repository.Delete(ctx, "1", 3)
+	if err != nil || deleted.DeletedAt == nil || deleted.Version != 4 {
+		t.Fatalf("delete: got %+v, %v", deleted, err)
+	}
+	if _, err := repository.Delete(ctx, "1", 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("delete twice: got %v, want ErrNotFound", err)
+	}
+	if _, err := repository.GetByID(ctx, "1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted: got %v, want ErrNotFound", err)
+	}
+	if _, err := repository.GetByCode(ctx, product.CodeValue); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted by code: got %v, want ErrNotFound", err)
+	}
+
+	// The products in the trash are not in the stats
+	if stats, err := repository.Stats(ctx); err != nil || stats != (Stats{}) {
+		t.Fatalf("stats after delete: got %+v, %v", stats, err)
+	}
+
+	testRepositoryTrash(t, repository, product)
+}
+
+// testRepositoryTrash checks the trash after the product was deleted
+func testRepositoryTrash(t *testing.T, repository Repository, product domain.Product) {
+	ctx := context.Background()
+
+	trash, err := repository.Trash(ctx, Query{})
+	if err != nil || len(trash.Items) != 1 || trash.Items[0].DeletedAt == nil {
+		t.Fatalf("trash: got %+v, %v", trash, err)
+	}
+
+	// The trashed product is only found in the trash
+	if trashed, err := repository.GetTrashed(ctx, "1"); err != nil || trashed.Id != "1" || trashed.DeletedAt == nil {
+		t.Fatalf("get trashed: got %+v, %v", trashed, err)
+	}
+	if _, err := repository.GetTrashed(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get trashed missing: got %v, want ErrNotFound", err)
+	}
+
+	// The code value is free while the product is in the trash
+	sameCode := newTestProduct("2")
+	sameCode.CodeValue = product.CodeValue
+	if _, err := repository.Create(ctx, sameCode); err != nil {
+		t.Fatalf("create with the code of a trashed product: %v", err)
+	}
+	if _, err := repository.Restore(ctx, "1"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("restore with the code taken: got %v, want ErrConflict", err)
+	}
+	if _, err := repository.Delete(ctx, "2", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	restored, err := repository.Restore(ctx, "1")
+	if err != nil || restored.DeletedAt != nil {
+		t.Fatalf("restore: got %+v, %v", restored, err)
+	}
+	if _, err := repository.GetByCode(ctx, product.CodeValue); err != nil {
+		t.Fatalf("get restored by code: %v", err)
+	}
+	if _, err := repository.Restore(ctx, "1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("restore twice: got %v, want ErrNotFound", err)
+	}
+	if _, err := repository.GetTrashed(ctx, "1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get trashed after the restore: got %v, want ErrNotFound", err)
+	}
+
+	// Only the products trashed before the time are purged
+	if purged, err := repository.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || len(purged) != 0 {
+		t.Fatalf("purge old: got %+v, %v", purged, err)
+	}
+	if purged, err := repository.Purge(ctx, time.Now().Add(time.Hour)); err != nil || len(purged) != 1 || purged[0].Id != "2" || purged[0].DeletedAt == nil {
+		t.Fatalf("purge: got %+v, %v", purged, err)
+	}
+	if _, err := repository.Restore(ctx, "2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("restore purged: got %v, want ErrNotFound", err)
+	}
+
+	if _, err := repository.Delete(ctx, "1", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
+
+// testRepositoryPagination checks that walking the pages with cursors and
+// offsets returns every product once and sorted by id
+func testRepositoryPagination(t *testing.T, repository Repository) {
+	ctx := context.Background()
+
+	for _, id := range []string{"e", "a", "d", "b", "c"} {
+		if _, err := repository.Create(ctx, newTestProduct(id)); err != nil {
+			t.Fatalf("create %s: %v", id, err)
+		}
+	}
+
+	var ids []string
+	query := Query{Limit: 2}
+	for {
+		page, err := repository.List(ctx, query)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if page.Total != 5 {
+			t.Fatalf("got total %d, want 5", page.Total)
+		}
+
+		for _, product := range page.Items {
+			ids = append(ids, product.Id)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if got := strings.Join(ids, ""); got != "abcde" {
+		t.Fatalf("walked the cursors in order %q, want abcde", got)
+	}
+
+	page, err := repository.List(ctx, Query{Limit: 2, Offset: 3})
+	if err != nil {
+		t.Fatalf("list with offset: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Id != "d" || page.NextCursor != "" {
+		t.Fatalf("got %+v for the last page", page)
+	}
+
+	if _, err := repository.List(ctx, Query{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("list with malformed cursor: got %v, want ErrInvalidQuery", err)
+	}
+}
+
+// testRepositoryFiltering checks the filters and a multi-key sort walked with cursors
+func testRepositoryFiltering(t *testing.T, repository Repository) {
+	ctx := context.Background()
+
+	// id, name, price and published
+	fixtures := []struct {
+		id        string
+		name      string
+		price     float64
+		published bool
+	}{
+		{"1", "Coco Cola", 10, true},
+		{"2", "Pepsi", 8, true},
+		{"3", "Cola Light", 10, true},
+		{"4", "Fanta", 5, false},
+		{"5", "coca 100%", 10, true},
+	}
+
+	for _, fixture := range fixtures {
+		product := newTestProduct(fixture.id)
+		product.Name = fixture.name
+		product.Price = fixture.price
+		product.IsPublished = fixture.published
+		if _, err := repository.Create(ctx, product); err != nil {
+			t.Fatalf("create %s: %v", fixture.id, err)
+		}
+	}
+
+	minPrice := 6.0
+	published := true
+	query := Query{
+		Filter: Filter{MinPrice: &minPrice, IsPublished: &published},
+		Sort:   []SortKey{{Field: SortPrice, Desc: true}, {Field: SortName}},
+		Limit:  1,
+	}
+
+	var ids []string
+	for {
+		page, err := repository.List(ctx, query)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if page.Total != 4 {
+			t.Fatalf("got total %d, want 4", page.Total)
+		}
+
+		for _, product := range page.Items {
+			ids = append(ids, product.Id)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	if got := strings.Join(ids, ","); got != "1,3,5,2" {
+		t.Fatalf("got %q sorted by -price,name, want 1,3,5,2", got)
+	}
+
+	page, err := repository.List(ctx, Query{Filter: Filter{Name: "COLA"}})
+	if err != nil {
+		t.Fatalf("list by name: %v", err)
+	}
+	if page.Total != 2 {
+		t.Fatalf("got %d products named like cola, want 2", page.Total)
+	}
+
+	page, err = repository.List(ctx, Query{Filter: Filter{Name: "0%"}})
+	if err != nil {
+		t.Fatalf("list by name with a wildcard: %v", err)
+	}
+	if page.Total != 1 || page.Items[0].Id != "5" {
+		t.Fatalf("got %+v for the name 0%%, want the product 5", page.Items)
+	}
+
+	later := time.Now().Add(48 * time.Hour)
+	page, err = repository.List(ctx, Query{Filter: Filter{ExpiresBefore: &later}})
+	if err != nil {
+		t.Fatalf("list by expiration: %v", err)
+	}
+	if page.Total != 5 {
+		t.Fatalf("got %d products expiring before %v, want 5", page.Total, later)
+	}
+}
+
+func TestMemoryRepositoryContract(t *testing.T) {
+	testRepositoryContract(t, NewMemoryRepository(nil))
+	testRepositoryPagination(t, NewMemoryRepository(nil))
+	testRepositoryFiltering(t, NewMemoryRepository(nil))
+}
+
+func TestSQLiteRepositoryContract(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "products.db")
+	db := openMigratedDB(t, "sqlite", dsn, DialectSQLite)
+
+	testRepositoryContract(t, NewSQLRepository(db, DialectSQLite))
+	testRepositoryPagination(t, NewSQLRepository(db, DialectSQLite))
+
+	clean(t, db)
+	testRepositoryFiltering(t, NewSQLRepository(db, DialectSQLite))
+}
+
+func TestPostgresRepositoryContract(t *testing.T) {
+	if testing.Short() {
+		t.Skip("postgres integration test skipped in short mode")
+	}
+
+	db := openMigratedDB(t, "pgx", postgresTestDSN(t), DialectPostgres)
+
+	testRepositoryContract(t, NewSQLRepository(db, DialectPostgres))
+	testRepositoryPagination(t, NewSQLRepository(db, DialectPostgres))
+
+	clean(t, db)
+	testRepositoryFiltering(t, NewSQLRepository(db, DialectPostgres))
+}
@@ -0,0 +1,354 @@
+package products
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+)
+
+// newTestProduct returns a product with the given id ready to be stored
+func newTestProduct(id string) domain.Product {
+	return domain.Product{
+		Id:          id,
+		Name:        "Product " + id,
+		Quantity:    10,
+		CodeValue:   "code-" + id,
+		IsPublished: true,
+		Expiration:  time.Now().Add(24 * time.Hour),
+		Price:       10.5,
+	}
+}
+
+// TestMemoryRepositoryConcurrentAccess hammers the five methods of the memory
+// repository in parallel. It is meant to be run with `go test -race`.
+func TestMemoryRepositoryConcurrentAccess(t *testing.T) {
+	const (
+		workers    = 16
+		iterations = 200
+	)
+
+	ctx := context.Background()
+	repository := NewMemoryRepository(nil)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				id := strconv.Itoa(worker) + "-" + strconv.Itoa(i)
+
+				if _, err := repository.Create(ctx, newTestProduct(id)); err != nil {
+					t.Errorf("create %s: %v", id, err)
+					return
+				}
+
+				if _, err := repository.GetAll(ctx); err != nil && !errors.Is(err, ErrEmpty) {
+					t.Errorf("get all: %v", err)
+					return
+				}
+
+				if _, err := repository.GetByID(ctx, id); err != nil {
+					t.Errorf("get %s: %v", id, err)
+					return
+				}
+
+				updated := newTestProduct(id)
+				updated.Quantity = i
+				if _, err := repository.Update(ctx, updated, id); err != nil {
+					t.Errorf("update %s: %v", id, err)
+					return
+				}
+
+				// We only delete half of the products so the reads keep finding data
+				if i%2 == 0 {
+					if _, err := repository.Delete(ctx, id, 0); err != nil {
+						t.Errorf("delete %s: %v", id, err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	listProducts, err := repository.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+
+	if want := workers * iterations / 2; len(listProducts) != want {
+		t.Fatalf("got %d products, want %d", len(listProducts), want)
+	}
+}
+
+// TestMemoryRepositoryGetAllReturnsCopy checks that the slice returned by
+// GetAll is not shared with the repository.
+func TestMemoryRepositoryGetAllReturnsCopy(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryRepository([]domain.Product{newTestProduct("1")})
+
+	listProducts, err := repository.GetAll(ctx)
+	if err != nil {
+		t.Fatalf("get all: %v", err)
+	}
+	listProducts[0].Name = "changed"
+
+	product, err := repository.GetByID(ctx, "1")
+	if err != nil {
+		t.Fatalf("get by id: %v", err)
+	}
+
+	if product.Name == "changed" {
+		t.Fatal("GetAll leaked the internal slice")
+	}
+}
+
+func TestFileRepositoryContract(t *testing.T) {
+	repository, err := NewFileRepository(FileConfig{SnapshotPath: t.TempDir() + "/products.json"})
+	if err != nil {
+		t.Fatalf("new file repository: %v", err)
+	}
+	defer repository.Close()
+
+	testRepositoryContract(t, repository)
+}
+
+// writeJSON is a function that writes the values in path, one JSON per line
+// when lines is true and as a single array otherwise
+func writeJSON(t *testing.T, path string, lines bool, values ...any) {
+	t.Helper()
+
+	var content []byte
+	if lines {
+		for _, value := range values {
+			line, err := json.Marshal(value)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			content = append(append(content, line...), '\n')
+		}
+	} else {
+		var err error
+		if content, err = json.Marshal(values); err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+	}
+
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestFileRepositoryRejectsDuplicateCodes(t *testing.T) {
+	duplicate := newTestProduct("2")
+	duplicate.CodeValue = "code-1"
+
+	// Two products of the snapshot with the same code
+	config := FileConfig{SnapshotPath: filepath.Join(t.TempDir(), "products.json")}
+	writeJSON(t, config.SnapshotPath, false, newTestProduct("1"), duplicate)
+	var conflict *ConflictError
+	if _, err := NewFileRepository(config); !errors.As(err, &conflict) || conflict.Value != "code-1" {
+		t.Fatalf("snapshot: got %v, want a conflict on code-1", err)
+	}
+
+	// A product of the journal with the code of one of the snapshot
+	config = FileConfig{SnapshotPath: filepath.Join(t.TempDir(), "products.json")}
+	writeJSON(t, config.SnapshotPath, false, newTestProduct("1"))
+	writeJSON(t, config.JournalPath(), true, JournalEntry{Op: journalPut, Product: &duplicate})
+	if _, err := NewFileRepository(config); !errors.As(err, &conflict) || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("journal: got %v, want a conflict on line 1", err)
+	}
+
+	// A product in the trash doesn't hold its code, and an update keeps its own
+	trashed, updated := newTestProduct("1"), newTestProduct("2")
+	deletedAt := time.Now()
+	trashed.DeletedAt = &deletedAt
+	updated.Name = "Updated"
+	writeJSON(t, config.SnapshotPath, false, trashed, newTestProduct("2"))
+	writeJSON(t, config.JournalPath(), true, JournalEntry{Op: journalPut, Product: &updated})
+	repository, err := NewFileRepository(config)
+	if err != nil {
+		t.Fatalf("trash and update: %v", err)
+	}
+	defer repository.Close()
+
+	if product, err := repository.GetByCode(context.Background(), "code-2"); err != nil || product.Name != "Updated" {
+		t.Fatalf("get by code: got %+v, %v", product, err)
+	}
+}
+
+func TestMemoryRepositoryPanicsOnDuplicateCodes(t *testing.T) {
+	duplicate := newTestProduct("2")
+	duplicate.CodeValue = "code-1"
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("two products with the same code were loaded")
+		}
+	}()
+	NewMemoryRepository([]domain.Product{newTestProduct("1"), duplicate})
+}
+
+// crashCopy is a function that copies the snapshot and the journal of config
+// to another directory, as they are on disk if the process died now
+func crashCopy(t *testing.T, config FileConfig) FileConfig {
+	t.Helper()
+
+	copied := FileConfig{SnapshotPath: filepath.Join(t.TempDir(), "products.json"), SnapshotInterval: time.Hour}
+	for from, to := range map[string]string{config.SnapshotPath: copied.SnapshotPath, config.JournalPath(): copied.JournalPath()} {
+		content, err := os.ReadFile(from)
+		if err != nil {
+			t.Fatalf("read %s: %v", from, err)
+		}
+		if err := os.WriteFile(to, content, 0o644); err != nil {
+			t.Fatalf("write %s: %v", to, err)
+		}
+	}
+	return copied
+}
+
+// writeCrashedRepository is a function that makes some writes in a file
+// repository and returns a copy of its files as they are before any snapshot:
+// product 1 updated, product 2 in the trash and product 3 created
+func writeCrashedRepository(t *testing.T) FileConfig {
+	t.Helper()
+	ctx := context.Background()
+
+	config := FileConfig{SnapshotPath: filepath.Join(t.TempDir(), "products.json"), SnapshotInterval: time.Hour}
+	writeJSON(t, config.SnapshotPath, false, newTestProduct("1"), newTestProduct("2"))
+	repository, err := NewFileRepository(config)
+	if err != nil {
+		t.Fatalf("new file repository: %v", err)
+	}
+	defer repository.Close()
+
+	updated := newTestProduct("1")
+	updated.Name = "Updated"
+	if _, err := repository.Update(ctx, updated, "1"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := repository.Delete(ctx, "2", 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repository.Create(ctx, newTestProduct("3")); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	return crashCopy(t, config)
+}
+
+// checkReplayed is a function that asserts the repository has the writes of writeCrashedRepository
+func checkReplayed(t *testing.T, repository Repository) {
+	t.Helper()
+	ctx := context.Background()
+
+	if product, err := repository.GetByID(ctx, "1"); err != nil || product.Name != "Updated" || product.Version != 2 {
+		t.Fatalf("get updated: got %+v, %v", product, err)
+	}
+	if _, err := repository.GetByID(ctx, "2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get deleted: got %v, want ErrNotFound", err)
+	}
+	if product, err := repository.GetTrashed(ctx, "2"); err != nil || product.DeletedAt == nil {
+		t.Fatalf("get trashed: got %+v, %v", product, err)
+	}
+	if _, err := repository.GetByCode(ctx, "code-3"); err != nil {
+		t.Fatalf("get created: %v", err)
+	}
+}
+
+func TestFileRepositoryReplaysJournal(t *testing.T) {
+	config := writeCrashedRepository(t)
+
+	repository, err := NewFileRepository(config)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer repository.Close()
+	checkReplayed(t, repository)
+
+	// What was replayed is in the snapshot and the journal starts empty
+	if info, err := os.Stat(config.JournalPath()); err != nil || info.Size() != 0 {
+		t.Fatalf("journal after reopening: got %v, %v", info, err)
+	}
+	snapshot, err := LoadSnapshot(config.SnapshotPath)
+	if err != nil || len(snapshot) != 3 {
+		t.Fatalf("snapshot after reopening: got %d products, %v", len(snapshot), err)
+	}
+}
+
+func TestFileRepositoryJournalTornTail(t *testing.T) {
+	ctx := context.Background()
+	config := writeCrashedRepository(t)
+
+	// The last write was interrupted in the middle of its line
+	journal, err := os.OpenFile(config.JournalPath(), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	_, err = journal.WriteString(`{"op":"put","product":{"id":"4","name":"Pro`)
+	journal.Close()
+	if err != nil {
+		t.Fatalf("tear journal: %v", err)
+	}
+
+	repository, err := NewFileRepository(config)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	checkReplayed(t, repository)
+	if _, err := repository.GetByID(ctx, "4"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get torn: got %v, want ErrNotFound", err)
+	}
+
+	// A write after the torn one is read after a crash, it isn't glued to it
+	if _, err := repository.Create(ctx, newTestProduct("5")); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	copied := crashCopy(t, config)
+	repository.Close()
+
+	repository, err = NewFileRepository(copied)
+	if err != nil {
+		t.Fatalf("reopen after the write: %v", err)
+	}
+	defer repository.Close()
+	checkReplayed(t, repository)
+	if _, err := repository.GetByID(ctx, "5"); err != nil {
+		t.Fatalf("get written after the torn one: %v", err)
+	}
+}
+
+func TestFileRepositoryJournalCorruptLine(t *testing.T) {
+	config := writeCrashedRepository(t)
+
+	// A complete line that can't be read is not a crash, the repository is refused
+	content, err := os.ReadFile(config.JournalPath())
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	lines[1] = "not json\n"
+	if err := os.WriteFile(config.JournalPath(), []byte(strings.Join(lines, "")), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	if _, err := NewFileRepository(config); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("got %v, want an error on line 2", err)
+	}
+
+	// The journal is left as it was to be fixed by hand
+	if after, err := os.ReadFile(config.JournalPath()); err != nil || string(after) != strings.Join(lines, "") {
+		t.Fatalf("got journal %q, %v", after, err)
+	}
+}
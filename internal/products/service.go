@@ -2,7 +2,9 @@ package products
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
+	"time"
 
 	"github.com/burgosfacundo/ApiGo.git/internal/domain"
 )
@@ -11,44 +13,122 @@ import (
 type Service interface {
 	Create(ctx context.Context, product domain.Product) (domain.Product, error)
 	GetAll(ctx context.Context) ([]domain.Product, error)
+	List(ctx context.Context, query Query) (Page, error)
 	GetByID(ctx context.Context, id string) (domain.Product, error)
+	GetByCode(ctx context.Context, code string) (domain.Product, error)
 	Update(ctx context.Context, product domain.Product, id string) (domain.Product, error)
-	Delete(ctx context.Context, id string) error
+	Patch(ctx context.Context, id string, patch Patch, version int64) (domain.Product, error)
+	Delete(ctx context.Context, id string, version int64) error
+	Trash(ctx context.Context, query Query) (Page, error)
+	Restore(ctx context.Context, id string) (domain.Product, error)
+	Purge(ctx context.Context, before time.Time) (int, error)
 }
 
+// maxWriteAttempts is how many times a write that expects no version is tried
+// when another write changes the product between the read and the write
+const maxWriteAttempts = 3
+
 // service is a struct that contains the repository of Product objects
 type service struct {
 	repository Repository
+	ids        IDGenerator
+	listeners  []Listener
+	authorizer Authorizer
+}
+
+// ServiceOption is a function that configures an optional dependency of the service
+type ServiceOption func(*service)
+
+// WithIDGenerator sets the generator of the ids of the new products, UUIDv7 by default
+func WithIDGenerator(ids IDGenerator) ServiceOption {
+	return func(s *service) {
+		s.ids = ids
+	}
+}
+
+// WithListener adds a listener that is notified after every write
+func WithListener(listener Listener) ServiceOption {
+	return func(s *service) {
+		s.listeners = append(s.listeners, listener)
+	}
+}
+
+// WithAuthorizer sets what decides if the caller can do each operation,
+// everything is allowed by default
+func WithAuthorizer(authorizer Authorizer) ServiceOption {
+	return func(s *service) {
+		s.authorizer = authorizer
+	}
 }
 
 // NewServiceProduct is a function that loads the repository into the service
-func NewServiceProduct(repository Repository) Service {
-	return &service{repository: repository}
+func NewServiceProduct(repository Repository, options ...ServiceOption) Service {
+	s := &service{
+		repository: repository,
+		ids:        NewUUIDv7Generator(),
+		authorizer: allowAll,
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
 }
 
-// Create is a function that calls the repository for create a Product in the db
+// Create is a function that calls the repository for create a Product in the db.
+// The id of the product is always assigned here, the one sent is ignored.
 func (s *service) Create(ctx context.Context, product domain.Product) (domain.Product, error) {
+	// We check the caller can create the product, and publish it if it's published
+	if err := s.authorize(ctx, PermissionCreate); err != nil {
+		return domain.Product{}, err
+	}
+	if publishes(product, nil) {
+		if err := s.authorize(ctx, PermissionPublish); err != nil {
+			return domain.Product{}, err
+		}
+	}
+
+	// We check the rules of a new product
+	if err := product.ValidateNew(time.Now()); err != nil {
+		return domain.Product{}, err
+	}
+
+	// We generate the id of the new product
+	id, err := s.ids.NewID()
+	if err != nil {
+		slog.ErrorContext(ctx, "[ProductsService][Create] error generating product id", "error", err)
+		return domain.Product{}, err
+	}
+	product.Id = id
+
 	// We call the repository for create a Product
-	product, err := s.repository.Create(ctx, product)
+	product, err = s.repository.Create(ctx, product)
 
 	// If we have an error log it and return it
 	if err != nil {
-		log.Println("[ProductsService][Create] error creating product", err)
+		slog.ErrorContext(ctx, "[ProductsService][Create] error creating product", "error", err)
 		return domain.Product{}, err
 	}
 
+	s.notify(ctx, Change{Action: ActionCreate, Product: product})
+
 	// We return the product
 	return product, nil
 }
 
 // GetAll is a function that calls the repository for return all the products in the db
 func (s *service) GetAll(ctx context.Context) ([]domain.Product, error) {
+	if err := s.authorize(ctx, PermissionRead); err != nil {
+		return []domain.Product{}, err
+	}
+
 	// We call the repository for get all the products
 	listProducts, err := s.repository.GetAll(ctx)
 
 	// If we have an error log it and return it
 	if err != nil {
-		log.Println("[ProductsService][GetAll] error getting all products", err)
+		slog.ErrorContext(ctx, "[ProductsService][GetAll] error getting all products", "error", err)
 		return []domain.Product{}, err
 	}
 
@@ -56,14 +136,56 @@ func (s *service) GetAll(ctx context.Context) ([]domain.Product, error) {
 	return listProducts, nil
 }
 
+// List is a function that calls the repository for return a page of products
+func (s *service) List(ctx context.Context, query Query) (Page, error) {
+	if err := s.authorize(ctx, PermissionRead); err != nil {
+		return Page{}, err
+	}
+
+	// We call the repository for get the page
+	page, err := s.repository.List(ctx, query)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[ProductsService][List] error listing products", "error", err)
+		return Page{}, err
+	}
+
+	// We return the page
+	return page, nil
+}
+
 // GetById is a function that calls the repository for return a Product by Id
 func (s *service) GetByID(ctx context.Context, id string) (domain.Product, error) {
+	if err := s.authorize(ctx, PermissionRead); err != nil {
+		return domain.Product{}, err
+	}
+
 	// We call the repository for get the product by id
 	product, err := s.repository.GetByID(ctx, id)
 
 	// If we have an error log it and return it
 	if err != nil {
-		log.Println("[ProductsService][GetByID] error getting product by ID", err)
+		slog.ErrorContext(ctx, "[ProductsService][GetByID] error getting product by ID", "error", err)
+		return domain.Product{}, err
+	}
+
+	// We return the product
+	return product, nil
+}
+
+// GetByCode is a function that calls the repository for return a Product by code value
+func (s *service) GetByCode(ctx context.Context, code string) (domain.Product, error) {
+	if err := s.authorize(ctx, PermissionRead); err != nil {
+		return domain.Product{}, err
+	}
+
+	// We call the repository for get the product by code value
+	product, err := s.repository.GetByCode(ctx, code)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[ProductsService][GetByCode] error getting product by code", "error", err)
 		return domain.Product{}, err
 	}
 
@@ -71,32 +193,257 @@ func (s *service) GetByID(ctx context.Context, id string) (domain.Product, error
 	return product, nil
 }
 
-// Update is a function that calls the repository for update a product by Id
+// Update is a function that calls the repository for update a product by Id.
+// The version of the product is the one expected, 0 updates any version.
 func (s *service) Update(ctx context.Context, product domain.Product, id string) (domain.Product, error) {
-	// We call the repository for update the product by id
-	product, err := s.repository.Update(ctx, product, id)
+	if err := s.authorize(ctx, PermissionUpdate); err != nil {
+		return domain.Product{}, err
+	}
+
+	// We check the rules of the product
+	if err := product.Validate(); err != nil {
+		return domain.Product{}, err
+	}
+
+	// We keep the version the client expects, the product takes the one read
+	version := product.Version
+
+	for attempt := 1; ; attempt++ {
+		// We get the product as it is before the update
+		previous, err := s.repository.GetByID(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "[ProductsService][Update] error getting product by ID", "error", err)
+			return domain.Product{}, err
+		}
+
+		if version != 0 && version != previous.Version {
+			return domain.Product{}, ErrVersionMismatch
+		}
+
+		// Publishing the product needs its own permission
+		if publishes(product, &previous) {
+			if err := s.authorize(ctx, PermissionPublish); err != nil {
+				return domain.Product{}, err
+			}
+		}
+
+		// We call the repository for update the version we read, so the
+		// previous product is exactly the one replaced
+		product.Version = previous.Version
+		updated, err := s.repository.Update(ctx, product, id)
+
+		// If another write got in between and no version was expected we try again
+		if errors.Is(err, ErrVersionMismatch) && version == 0 && attempt < maxWriteAttempts {
+			continue
+		}
+
+		// If we have an error log it and return it
+		if err != nil {
+			slog.ErrorContext(ctx, "[ProductsService][Update] error updating product by ID", "error", err)
+			return domain.Product{}, err
+		}
+
+		s.notify(ctx, Change{Action: ActionUpdate, Product: updated, Previous: &previous})
+
+		// We return the updated product
+		return updated, nil
+	}
+}
+
+// Patch is a function that applies the patch to a product by Id and calls the
+// repository for update it. Only the fields named by the patch change. The
+// version is the one expected, 0 patches any version.
+func (s *service) Patch(ctx context.Context, id string, patch Patch, version int64) (domain.Product, error) {
+	if err := s.authorize(ctx, PermissionUpdate); err != nil {
+		return domain.Product{}, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		// We get the current product
+		previous, err := s.repository.GetByID(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "[ProductsService][Patch] error getting product by ID", "error", err)
+			return domain.Product{}, err
+		}
+
+		if version != 0 && version != previous.Version {
+			return domain.Product{}, ErrVersionMismatch
+		}
+
+		// We apply the patch and check the rules of the result
+		product, err := patch.Apply(previous)
+		if err != nil {
+			return domain.Product{}, err
+		}
+		if err := product.Validate(); err != nil {
+			return domain.Product{}, err
+		}
+
+		// Publishing the product needs its own permission
+		if publishes(product, &previous) {
+			if err := s.authorize(ctx, PermissionPublish); err != nil {
+				return domain.Product{}, err
+			}
+		}
+
+		// We update the version we read, so a write in between is not lost
+		product, err = s.repository.Update(ctx, product, id)
+
+		// If another write got in between and no version was expected we
+		// apply the patch again over it
+		if errors.Is(err, ErrVersionMismatch) && version == 0 && attempt < maxWriteAttempts {
+			continue
+		}
+
+		// If we have an error log it and return it
+		if err != nil {
+			slog.ErrorContext(ctx, "[ProductsService][Patch] error updating product by ID", "error", err)
+			return domain.Product{}, err
+		}
+
+		s.notify(ctx, Change{Action: ActionUpdate, Product: product, Previous: &previous})
+
+		// We return the patched product
+		return product, nil
+	}
+}
+
+// Delete is a function that calls the repository for move a product by Id to the trash.
+// The version is the one expected, 0 deletes any version.
+func (s *service) Delete(ctx context.Context, id string, version int64) error {
+	if err := s.authorize(ctx, PermissionDelete); err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		// We get the product as it is before the delete
+		previous, err := s.repository.GetByID(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "[ProductsService][Delete] error getting product by ID", "error", err)
+			return err
+		}
+
+		if version != 0 && version != previous.Version {
+			return ErrVersionMismatch
+		}
+
+		// We call the repository for delete the version we read
+		product, err := s.repository.Delete(ctx, id, previous.Version)
+
+		// If another write got in between and no version was expected we try again
+		if errors.Is(err, ErrVersionMismatch) && version == 0 && attempt < maxWriteAttempts {
+			continue
+		}
+
+		// If we have an error log it and return it
+		if err != nil {
+			slog.ErrorContext(ctx, "[ProductsService][Delete] error deleting product by ID", "error", err)
+			return err
+		}
+
+		s.notify(ctx, Change{Action: ActionDelete, Product: product, Previous: &previous})
+
+		// We return nill because we didn't have an error
+		return nil
+	}
+}
+
+// Trash is a function that calls the repository for return a page of the deleted products
+func (s *service) Trash(ctx context.Context, query Query) (Page, error) {
+	if err := s.authorize(ctx, PermissionRead); err != nil {
+		return Page{}, err
+	}
+
+	// We call the repository for get the page
+	page, err := s.repository.Trash(ctx, query)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[ProductsService][Trash] error listing trashed products", "error", err)
+		return Page{}, err
+	}
+
+	// We return the page
+	return page, nil
+}
+
+// Restore is a function that calls the repository for bring a deleted product back by Id.
+// Restoring a published product publishes it again, so it needs PermissionPublish too.
+func (s *service) Restore(ctx context.Context, id string) (domain.Product, error) {
+	if err := s.authorize(ctx, PermissionRestore); err != nil {
+		return domain.Product{}, err
+	}
+
+	// We get the product in the trash to know if it was published, it can't
+	// change there so it's restored as it was read
+	trashed, err := s.repository.GetTrashed(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "[ProductsService][Restore] error getting trashed product by ID", "error", err)
+		return domain.Product{}, err
+	}
+
+	if publishes(trashed, nil) {
+		if err := s.authorize(ctx, PermissionPublish); err != nil {
+			return domain.Product{}, err
+		}
+	}
+
+	// We call the repository for restore the product by id
+	product, err := s.repository.Restore(ctx, id)
 
 	// If we have an error log it and return it
 	if err != nil {
-		log.Println("[ProductsService][Update] error updating product by ID", err)
+		slog.ErrorContext(ctx, "[ProductsService][Restore] error restoring product by ID", "error", err)
 		return domain.Product{}, err
 	}
 
-	// We return the updated product
+	s.notify(ctx, Change{Action: ActionRestore, Product: product})
+
+	// We return the restored product
 	return product, nil
 }
 
-// Delete is a function that calls the repository for delete a product by Id
-func (s *service) Delete(ctx context.Context, id string) error {
-	// We call the repository for delete the product by id
-	err := s.repository.Delete(ctx, id)
+// Purge is a function that calls the repository for permanently delete the
+// products that were moved to the trash before the given time. The listeners
+// are notified of every product purged, even when the purge stopped halfway.
+func (s *service) Purge(ctx context.Context, before time.Time) (int, error) {
+	if err := s.authorize(ctx, PermissionPurge); err != nil {
+		return 0, err
+	}
+
+	// We call the repository for purge the trash
+	purged, err := s.repository.Purge(ctx, before)
+
+	for _, product := range purged {
+		s.notify(ctx, Change{Action: ActionPurge, Product: product, Previous: &product})
+	}
 
 	// If we have an error log it and return it
 	if err != nil {
-		log.Println("[ProductsService][Delete] error deleting product by ID", err)
-		return err
+		slog.ErrorContext(ctx, "[ProductsService][Purge] error purging trashed products", "error", err)
+		return len(purged), err
 	}
 
-	// We return nill because we didn't have an error
+	// We return how many products were purged
+	return len(purged), nil
+}
+
+// authorize is a function that asks the authorizer if the caller can do the operation
+func (s *service) authorize(ctx context.Context, permission Permission) error {
+	if err := s.authorizer.Authorize(ctx, permission); err != nil {
+		if !errors.Is(err, ErrForbidden) {
+			slog.ErrorContext(ctx, "[ProductsService][Authorize] error authorizing", "permission", permission, "error", err)
+		}
+		return err
+	}
 	return nil
 }
+
+// notify is a function that tells every listener about the change
+func (s *service) notify(ctx context.Context, change Change) {
+	for _, listener := range s.listeners {
+		if err := listener.OnChange(ctx, change); err != nil {
+			slog.ErrorContext(ctx, "[ProductsService][Notify] error notifying", "action", change.Action, "product_id", change.Product.Id, "error", err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package products
+
+import (
+	"context"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes of the spans of the products
+const (
+	attributeProductID = attribute.Key("product.id")
+	attributeVersion   = attribute.Key("product.version")
+	attributeCount     = attribute.Key("products.count")
+	attributeErrorKind = attribute.Key("error.kind")
+)
+
+// startSpan is a function that starts the span of an operation, child of the
+// span in the context
+func startSpan(ctx context.Context, tracer trace.Tracer, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attributes...))
+}
+
+// endSpan is a function that records the error of the operation, if any, and ends its span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttributes(attributeErrorKind.String(errorKind(err)))
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedRepository is a struct that contains a Repository whose operations are traced
+type tracedRepository struct {
+	repository Repository
+	tracer     trace.Tracer
+}
+
+// NewTracedRepository is a function that returns the repository with a span
+// around every method, named products.Repository/<method>, with the id of the
+// product and the error of the operation
+func NewTracedRepository(repository Repository, provider trace.TracerProvider) Repository {
+	return &tracedRepository{repository: repository, tracer: provider.Tracer(tracing.InstrumentationName)}
+}
+
+// Create is a function that creates a Product in the db
+func (r *tracedRepository) Create(ctx context.Context, product domain.Product) (domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/Create", attributeProductID.String(product.Id))
+	created, err := r.repository.Create(ctx, product)
+	endSpan(span, err)
+	return created, err
+}
+
+// GetAll is a function that returns all the Products of the db
+func (r *tracedRepository) GetAll(ctx context.Context) ([]domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/GetAll")
+	listProducts, err := r.repository.GetAll(ctx)
+	span.SetAttributes(attributeCount.Int(len(listProducts)))
+	endSpan(span, err)
+	return listProducts, err
+}
+
+// List is a function that returns a page of the Products of the db
+func (r *tracedRepository) List(ctx context.Context, query Query) (Page, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/List")
+	page, err := r.repository.List(ctx, query)
+	span.SetAttributes(attributeCount.Int(len(page.Items)))
+	endSpan(span, err)
+	return page, err
+}
+
+// GetByID is a function that returns a Product by id from the db
+func (r *tracedRepository) GetByID(ctx context.Context, id string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/GetByID", attributeProductID.String(id))
+	product, err := r.repository.GetByID(ctx, id)
+	endSpan(span, err)
+	return product, err
+}
+
+// GetByCode is a function that returns a Product by code value from the db
+func (r *tracedRepository) GetByCode(ctx context.Context, code string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/GetByCode")
+	product, err := r.repository.GetByCode(ctx, code)
+	if err == nil {
+		span.SetAttributes(attributeProductID.String(product.Id))
+	}
+	endSpan(span, err)
+	return product, err
+}
+
+// Update is a function that updates a Product by id in the db
+func (r *tracedRepository) Update(ctx context.Context, product domain.Product, id string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/Update",
+		attributeProductID.String(id), attributeVersion.Int64(product.Version))
+	updated, err := r.repository.Update(ctx, product, id)
+	endSpan(span, err)
+	return updated, err
+}
+
+// Delete is a function that moves a Product by id to the trash
+func (r *tracedRepository) Delete(ctx context.Context, id string, version int64) (domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/Delete",
+		attributeProductID.String(id), attributeVersion.Int64(version))
+	deleted, err := r.repository.Delete(ctx, id, version)
+	endSpan(span, err)
+	return deleted, err
+}
+
+// Trash is a function that returns a page of the Products in the trash
+func (r *tracedRepository) Trash(ctx context.Context, query Query) (Page, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/Trash")
+	page, err := r.repository.Trash(ctx, query)
+	span.SetAttributes(attributeCount.Int(len(page.Items)))
+	endSpan(span, err)
+	return page, err
+}
+
+// GetTrashed is a function that returns a Product by id from the trash
+func (r *tracedRepository) GetTrashed(ctx context.Context, id string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/GetTrashed", attributeProductID.String(id))
+	product, err := r.repository.GetTrashed(ctx, id)
+	endSpan(span, err)
+	return product, err
+}
+
+// Restore is a function that brings a Product by id back from the trash
+func (r *tracedRepository) Restore(ctx context.Context, id string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/Restore", attributeProductID.String(id))
+	restored, err := r.repository.Restore(ctx, id)
+	endSpan(span, err)
+	return restored, err
+}
+
+// Purge is a function that removes the Products trashed before the time for good
+func (r *tracedRepository) Purge(ctx context.Context, before time.Time) ([]domain.Product, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/Purge")
+	purged, err := r.repository.Purge(ctx, before)
+	span.SetAttributes(attributeCount.Int(len(purged)))
+	endSpan(span, err)
+	return purged, err
+}
+
+// Stats is a function that returns the figures of the products in the db
+func (r *tracedRepository) Stats(ctx context.Context) (Stats, error) {
+	ctx, span := startSpan(ctx, r.tracer, "products.Repository/Stats")
+	stats, err := r.repository.Stats(ctx)
+	endSpan(span, err)
+	return stats, err
+}
+
+// tracedService is a struct that contains a Service whose operations are traced
+type tracedService struct {
+	service Service
+	tracer  trace.Tracer
+}
+
+// NewTracedService is a function that returns the service with a span around
+// every method, named products.Service/<method>, with the id of the product and
+// the error of the operation. The spans of the repository are their children.
+func NewTracedService(service Service, provider trace.TracerProvider) Service {
+	return &tracedService{service: service, tracer: provider.Tracer(tracing.InstrumentationName)}
+}
+
+// Create is a function that calls the service for create a Product
+func (s *tracedService) Create(ctx context.Context, product domain.Product) (domain.Product, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/Create")
+	created, err := s.service.Create(ctx, product)
+	if err == nil {
+		span.SetAttributes(attributeProductID.String(created.Id))
+	}
+	endSpan(span, err)
+	return created, err
+}
+
+// GetAll is a function that calls the service for get all the Products
+func (s *tracedService) GetAll(ctx context.Context) ([]domain.Product, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/GetAll")
+	listProducts, err := s.service.GetAll(ctx)
+	span.SetAttributes(attributeCount.Int(len(listProducts)))
+	endSpan(span, err)
+	return listProducts, err
+}
+
+// List is a function that calls the service for get a page of the Products
+func (s *tracedService) List(ctx context.Context, query Query) (Page, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/List")
+	page, err := s.service.List(ctx, query)
+	span.SetAttributes(attributeCount.Int(len(page.Items)))
+	endSpan(span, err)
+	return page, err
+}
+
+// GetByID is a function that calls the service for get a Product by id
+func (s *tracedService) GetByID(ctx context.Context, id string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/GetByID", attributeProductID.String(id))
+	product, err := s.service.GetByID(ctx, id)
+	endSpan(span, err)
+	return product, err
+}
+
+// GetByCode is a function that calls the service for get a Product by code value
+func (s *tracedService) GetByCode(ctx context.Context, code string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/GetByCode")
+	product, err := s.service.GetByCode(ctx, code)
+	if err == nil {
+		span.SetAttributes(attributeProductID.String(product.Id))
+	}
+	endSpan(span, err)
+	return product, err
+}
+
+// Update is a function that calls the service for update a Product by id
+func (s *tracedService) Update(ctx context.Context, product domain.Product, id string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/Update",
+		attributeProductID.String(id), attributeVersion.Int64(product.Version))
+	updated, err := s.service.Update(ctx, product, id)
+	endSpan(span, err)
+	return updated, err
+}
+
+// Patch is a function that calls the service for patch a Product by id
+func (s *tracedService) Patch(ctx context.Context, id string, patch Patch, version int64) (domain.Product, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/Patch",
+		attributeProductID.String(id), attributeVersion.Int64(version))
+	patched, err := s.service.Patch(ctx, id, patch, version)
+	endSpan(span, err)
+	return patched, err
+}
+
+// Delete is a function that calls the service for move a Product by id to the trash
+func (s *tracedService) Delete(ctx context.Context, id string, version int64) error {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/Delete",
+		attributeProductID.String(id), attributeVersion.Int64(version))
+	err := s.service.Delete(ctx, id, version)
+	endSpan(span, err)
+	return err
+}
+
+// Trash is a function that calls the service for get a page of the Products in the trash
+func (s *tracedService) Trash(ctx context.Context, query Query) (Page, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/Trash")
+	page, err := s.service.Trash(ctx, query)
+	span.SetAttributes(attributeCount.Int(len(page.Items)))
+	endSpan(span, err)
+	return page, err
+}
+
+// Restore is a function that calls the service for bring a Product by id back from the trash
+func (s *tracedService) Restore(ctx context.Context, id string) (domain.Product, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/Restore", attributeProductID.String(id))
+	restored, err := s.service.Restore(ctx, id)
+	endSpan(span, err)
+	return restored, err
+}
+
+// Purge is a function that calls the service for remove the Products trashed before the time
+func (s *tracedService) Purge(ctx context.Context, before time.Time) (int, error) {
+	ctx, span := startSpan(ctx, s.tracer, "products.Service/Purge")
+	purged, err := s.service.Purge(ctx, before)
+	span.SetAttributes(attributeCount.Int(purged))
+	endSpan(span, err)
+	return purged, err
+}
@@ -0,0 +1,97 @@
+package products
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanTree is a function that returns the ended spans by name and checks there's one of each
+func spanTree(t *testing.T, exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	t.Helper()
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		if _, ok := spans[span.Name]; ok {
+			t.Fatalf("span %s was ended twice", span.Name)
+		}
+		spans[span.Name] = span
+	}
+	exporter.Reset()
+	return spans
+}
+
+// spanAttribute is a function that returns the value of the attribute of the span
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracedServiceSpanTree(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	repository := NewTracedRepository(NewMemoryRepository([]domain.Product{newTestProduct("1")}), provider)
+	service := NewTracedService(NewServiceProduct(repository), provider)
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "request")
+	if _, err := service.GetByID(ctx, "1"); err != nil {
+		t.Fatalf("get by id: %v", err)
+	}
+	root.End()
+
+	// request -> products.Service/GetByID -> products.Repository/GetByID
+	spans := spanTree(t, exporter)
+	request, serviceSpan, repositorySpan := spans["request"], spans["products.Service/GetByID"], spans["products.Repository/GetByID"]
+	if len(spans) != 3 || !serviceSpan.SpanContext.IsValid() || !repositorySpan.SpanContext.IsValid() {
+		t.Fatalf("got spans %v", spans)
+	}
+	if serviceSpan.Parent.SpanID() != request.SpanContext.SpanID() ||
+		repositorySpan.Parent.SpanID() != serviceSpan.SpanContext.SpanID() ||
+		repositorySpan.SpanContext.TraceID() != request.SpanContext.TraceID() {
+		t.Fatal("the spans are not a tree under the request")
+	}
+	for _, span := range []tracetest.SpanStub{serviceSpan, repositorySpan} {
+		if id, ok := spanAttribute(span, attributeProductID); !ok || id.AsString() != "1" {
+			t.Errorf("%s: got product.id %v", span.Name, id)
+		}
+		if span.Status.Code == codes.Error {
+			t.Errorf("%s: got an error status", span.Name)
+		}
+	}
+
+	// The errors are recorded in every span they go through
+	if _, err := service.GetByID(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing: got %v, want ErrNotFound", err)
+	}
+	spans = spanTree(t, exporter)
+	for _, name := range []string{"products.Service/GetByID", "products.Repository/GetByID"} {
+		span := spans[name]
+		if kind, ok := spanAttribute(span, attributeErrorKind); span.Status.Code != codes.Error || !ok || kind.AsString() != "not_found" || len(span.Events) != 1 {
+			t.Errorf("%s: got status %+v, error.kind %v and events %v", name, span.Status, kind, span.Events)
+		}
+	}
+
+	// The id of a new product is only known after the service created it
+	created, err := service.Create(context.Background(), newTestProduct("new"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	spans = spanTree(t, exporter)
+	if id, ok := spanAttribute(spans["products.Service/Create"], attributeProductID); !ok || id.AsString() != created.Id {
+		t.Errorf("create: got product.id %v, want %s", id, created.Id)
+	}
+	if spans["products.Repository/Create"].Parent.SpanID() != spans["products.Service/Create"].SpanContext.SpanID() {
+		t.Error("create: the span of the repository is not a child of the one of the service")
+	}
+}
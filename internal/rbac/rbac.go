@@ -0,0 +1,177 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+	"github.com/burgosfacundo/ApiGo.git/pkg/identity"
+)
+
+// ErrInvalidConfig is returned when the config of the roles is not valid
+var ErrInvalidConfig = errors.New("invalid rbac config")
+
+// Roles of the default config
+const (
+	RoleViewer    = "viewer"
+	RoleEditor    = "editor"
+	RolePublisher = "publisher"
+	RoleAdmin     = "admin"
+)
+
+// Wildcard grants every permission to a role
+const Wildcard products.Permission = "*"
+
+// Config is a struct that contains the roles and who has them. The roles of
+// a caller are the ones of the roles claim of its token plus the ones of its
+// subject, which tells how it authenticated: token, jwt:<sub> or
+// apikey:<id>. An authenticated caller without roles gets Authenticated and
+// a caller that didn't authenticate gets Anonymous.
+type Config struct {
+	Roles         map[string][]products.Permission `json:"roles"`
+	Subjects      map[string][]string              `json:"subjects,omitempty"`
+	Authenticated []string                         `json:"authenticated,omitempty"`
+	Anonymous     []string                         `json:"anonymous,omitempty"`
+}
+
+// DefaultConfig is a function that returns the roles of the api: a viewer
+// reads, an editor also creates, updates and restores, a publisher also
+// publishes, restoring a published product included, and an admin does
+// everything. Nobody is assigned a role.
+func DefaultConfig() Config {
+	editor := []products.Permission{
+		products.PermissionRead,
+		products.PermissionCreate,
+		products.PermissionUpdate,
+		products.PermissionRestore,
+	}
+
+	return Config{
+		Roles: map[string][]products.Permission{
+			RoleViewer:    {products.PermissionRead},
+			RoleEditor:    editor,
+			RolePublisher: append(append([]products.Permission{}, editor...), products.PermissionPublish),
+			RoleAdmin:     {Wildcard},
+		},
+		Subjects: map[string][]string{},
+	}
+}
+
+// LoadConfig is a function that reads the config from a JSON file
+func LoadConfig(path string) (Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	// An unknown field is a typo that would grant less than expected
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+
+	var config Config
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+	return config, nil
+}
+
+// policy is a struct that contains the permissions of every role and the roles of the callers
+type policy struct {
+	roles         map[string]map[products.Permission]bool
+	subjects      map[string][]string
+	authenticated []string
+	anonymous     []string
+}
+
+// NewPolicy is a function that checks the config and returns the authorizer
+// of the service of the products that enforces it
+func NewPolicy(config Config) (products.Authorizer, error) {
+	known := map[products.Permission]bool{Wildcard: true}
+	for _, permission := range products.Permissions {
+		known[permission] = true
+	}
+
+	p := &policy{
+		roles:         map[string]map[products.Permission]bool{},
+		subjects:      config.Subjects,
+		authenticated: config.Authenticated,
+		anonymous:     config.Anonymous,
+	}
+
+	for role, permissions := range config.Roles {
+		p.roles[role] = map[products.Permission]bool{}
+		for _, permission := range permissions {
+			if !known[permission] {
+				return nil, fmt.Errorf("%w: role %s has the unknown permission %q", ErrInvalidConfig, role, permission)
+			}
+			p.roles[role][permission] = true
+		}
+	}
+
+	// We check every role assigned is defined, the ones of the tokens are not
+	// known until they come and the unknown ones just grant nothing
+	assigned := map[string][]string{"authenticated": config.Authenticated, "anonymous": config.Anonymous}
+	for subject, roles := range config.Subjects {
+		assigned["subject "+subject] = roles
+	}
+	for who, roles := range assigned {
+		for _, role := range roles {
+			if _, ok := p.roles[role]; !ok {
+				return nil, fmt.Errorf("%w: %s has the unknown role %q", ErrInvalidConfig, who, role)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// Authorize is a function that returns an error that wraps products.ErrForbidden
+// unless a role of the caller carried by ctx grants the permission
+func (p *policy) Authorize(ctx context.Context, permission products.Permission) error {
+	if isSystem(ctx) {
+		return nil
+	}
+
+	for _, role := range p.rolesOf(ctx) {
+		if p.roles[role][permission] || p.roles[role][Wildcard] {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s is not allowed to %s", products.ErrForbidden, identity.Subject(ctx), permission)
+}
+
+// rolesOf is a function that returns the roles of the caller carried by ctx
+func (p *policy) rolesOf(ctx context.Context) []string {
+	claims, ok := auth.FromContext(ctx)
+	if !ok {
+		return p.anonymous
+	}
+
+	roles := append(append([]string{}, claims.Roles...), p.subjects[claims.Subject]...)
+	if len(roles) == 0 {
+		return p.authenticated
+	}
+	return roles
+}
+
+// systemKey is the key of the mark of the jobs of the server in a context
+type systemKey struct{}
+
+// AsSystem is a function that returns a copy of ctx for a job of the server,
+// like the purge of the trash, which every policy allows. A request can't
+// get this mark, it's not a subject a token could claim.
+func AsSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// isSystem is a function that returns if ctx belongs to a job of the server
+func isSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(systemKey{}).(bool)
+	return system
+}
@@ -0,0 +1,152 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+)
+
+// asCaller is a function that returns a context of an authenticated caller with the roles
+func asCaller(subject string, roles ...string) context.Context {
+	claims := &auth.Claims{Roles: roles}
+	claims.Subject = subject
+	return auth.WithClaims(context.Background(), claims)
+}
+
+// newTestService is a function that returns the service of a draft product
+// and a published one, enforcing the default roles
+func newTestService(t *testing.T) products.Service {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.Subjects["ci"] = []string{RoleEditor}
+	config.Anonymous = []string{RoleViewer}
+
+	policy, err := NewPolicy(config)
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+
+	expiration := time.Now().Add(24 * time.Hour)
+	return products.NewServiceProduct(products.NewMemoryRepository([]domain.Product{
+		{Id: "1", Name: "Draft", Quantity: 1, CodeValue: "draft1", Expiration: expiration, Price: 1},
+		{Id: "2", Name: "Published", Quantity: 1, CodeValue: "published1", IsPublished: true, Expiration: expiration, Price: 1},
+	}), products.WithAuthorizer(policy))
+}
+
+func TestPolicyRoles(t *testing.T) {
+	service := newTestService(t)
+	publish := products.Patch{Type: products.PatchMerge, Document: []byte(`{"is_published":true}`)}
+	rename := products.Patch{Type: products.PatchMerge, Document: []byte(`{"name":"Renamed"}`)}
+	unpublish := products.Patch{Type: products.PatchMerge, Document: []byte(`{"is_published":false}`)}
+
+	// The anonymous callers only read
+	if _, err := service.GetByID(context.Background(), "1"); err != nil {
+		t.Fatalf("anonymous read: %v", err)
+	}
+	if _, err := service.Patch(context.Background(), "1", rename, 0); !errors.Is(err, products.ErrForbidden) {
+		t.Fatalf("anonymous patch: got %v, want ErrForbidden", err)
+	}
+
+	// An authenticated caller without roles gets none, the subject ci is an editor
+	if _, err := service.Patch(asCaller("alice"), "1", rename, 0); !errors.Is(err, products.ErrForbidden) {
+		t.Fatalf("patch without roles: got %v, want ErrForbidden", err)
+	}
+	if _, err := service.Patch(asCaller("ci"), "1", rename, 0); err != nil {
+		t.Fatalf("editor patch: %v", err)
+	}
+
+	// Only a publisher publishes, editing a published product is not publishing it
+	if _, err := service.Patch(asCaller("ci"), "1", publish, 0); !errors.Is(err, products.ErrForbidden) {
+		t.Fatalf("editor publish: got %v, want ErrForbidden", err)
+	}
+	if _, err := service.Patch(asCaller("ci"), "2", rename, 0); err != nil {
+		t.Fatalf("editor patch of a published product: %v", err)
+	}
+	if product, err := service.Patch(asCaller("bob", RolePublisher), "1", publish, 0); err != nil || !product.IsPublished {
+		t.Fatalf("publisher publish: got %+v, %v", product, err)
+	}
+
+	// Only an admin deletes and purges, the jobs of the server can do everything
+	if err := service.Delete(asCaller("bob", RolePublisher), "1", 0); !errors.Is(err, products.ErrForbidden) {
+		t.Fatalf("publisher delete: got %v, want ErrForbidden", err)
+	}
+	if err := service.Delete(asCaller("carol", RoleAdmin), "1", 0); err != nil {
+		t.Fatalf("admin delete: %v", err)
+	}
+	if _, err := service.Purge(asCaller("ci"), time.Now().Add(time.Hour)); !errors.Is(err, products.ErrForbidden) {
+		t.Fatalf("editor purge: got %v, want ErrForbidden", err)
+	}
+	if purged, err := service.Purge(AsSystem(context.Background()), time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Fatalf("system purge: got %d, %v", purged, err)
+	}
+
+	// Restoring a published product publishes it again, an editor only restores the unpublished ones
+	if err := service.Delete(asCaller("carol", RoleAdmin), "2", 0); err != nil {
+		t.Fatalf("admin delete published: %v", err)
+	}
+	if _, err := service.Restore(asCaller("ci"), "2"); !errors.Is(err, products.ErrForbidden) {
+		t.Fatalf("editor restore of a published product: got %v, want ErrForbidden", err)
+	}
+	if product, err := service.Restore(asCaller("bob", RolePublisher), "2"); err != nil || !product.IsPublished {
+		t.Fatalf("publisher restore of a published product: got %+v, %v", product, err)
+	}
+	if _, err := service.Patch(asCaller("bob", RolePublisher), "2", unpublish, 0); err != nil {
+		t.Fatalf("publisher unpublish: %v", err)
+	}
+	if err := service.Delete(asCaller("carol", RoleAdmin), "2", 0); err != nil {
+		t.Fatalf("admin delete unpublished: %v", err)
+	}
+	if _, err := service.Restore(asCaller("ci"), "2"); err != nil {
+		t.Fatalf("editor restore of an unpublished product: %v", err)
+	}
+}
+
+func TestNewPolicyChecksConfig(t *testing.T) {
+	for name, config := range map[string]Config{
+		"unknown permission": {Roles: map[string][]products.Permission{"viewer": {"products:fly"}}},
+		"unknown subject role": {
+			Roles:    map[string][]products.Permission{"viewer": {products.PermissionRead}},
+			Subjects: map[string][]string{"ci": {"editor"}},
+		},
+		"unknown authenticated role": {
+			Roles:         map[string][]products.Permission{"viewer": {products.PermissionRead}},
+			Authenticated: []string{"editor"},
+		},
+	} {
+		if _, err := NewPolicy(config); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: got %v, want ErrInvalidConfig", name, err)
+		}
+	}
+
+	if _, err := NewPolicy(DefaultConfig()); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rbac.json")
+	content := `{"roles":{"viewer":["products:read"],"admin":["*"]},"subjects":{"token":["admin"]},"anonymous":["viewer"]}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil || len(config.Roles) != 2 || config.Subjects["token"][0] != RoleAdmin || config.Anonymous[0] != RoleViewer {
+		t.Fatalf("load config: got %+v, %v", config, err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"roles":{},"admins":["token"]}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := LoadConfig(path); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("load config with an unknown field: got %v, want ErrInvalidConfig", err)
+	}
+}
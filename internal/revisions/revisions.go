@@ -0,0 +1,107 @@
+package revisions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+	"github.com/burgosfacundo/ApiGo.git/pkg/identity"
+)
+
+// Errors that can be returned in the response
+var (
+	ErrNotFound = errors.New("revision not found")
+	ErrConflict = errors.New("revision already exists")
+)
+
+// Revision is a struct that represents a version of a product as a write left it.
+// Number is the version of the product, so it grows with every write.
+type Revision struct {
+	ProductID string         `json:"product_id"`
+	Number    int64          `json:"number"`
+	Action    string         `json:"action"`
+	Actor     string         `json:"actor"`
+	CreatedAt time.Time      `json:"created_at"`
+	Product   domain.Product `json:"product"`
+}
+
+// FieldChange is a struct that represents a field that differs between two revisions
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// NewRecorder is a function that returns a listener of the products service
+// that appends a revision for every write, by the subject of the request
+func NewRecorder(store Store) products.Listener {
+	return products.ListenerFunc(func(ctx context.Context, change products.Change) error {
+		// A purge doesn't make a version, the revisions stay as the history of the product
+		if change.Action == products.ActionPurge {
+			return nil
+		}
+
+		return store.Append(ctx, Revision{
+			ProductID: change.Product.Id,
+			Number:    change.Product.Version,
+			Action:    change.Action,
+			Actor:     identity.Subject(ctx),
+			CreatedAt: change.Product.UpdatedAt,
+			Product:   change.Product,
+		})
+	})
+}
+
+// Diff is a function that returns the fields that differ between the products
+// of two revisions, named as in the product json and sorted by name
+func Diff(from, to Revision) ([]FieldChange, error) {
+	fromFields, err := fields(from.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	toFields, err := fields(to.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(fromFields))
+	for name := range fromFields {
+		names = append(names, name)
+	}
+	for name := range toFields {
+		if _, ok := fromFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		if !reflect.DeepEqual(fromFields[name], toFields[name]) {
+			changes = append(changes, FieldChange{Field: name, From: fromFields[name], To: toFields[name]})
+		}
+	}
+
+	return changes, nil
+}
+
+// fields is a function that returns the members of the product json
+func fields(product domain.Product) (map[string]any, error) {
+	content, err := json.Marshal(product)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
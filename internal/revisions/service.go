@@ -0,0 +1,148 @@
+package revisions
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+)
+
+// Service represents a contract with all the functions that need to be implemented
+type Service interface {
+	List(ctx context.Context, productID string) ([]Revision, error)
+	Get(ctx context.Context, productID string, number int64) (Revision, error)
+	Diff(ctx context.Context, productID string, from, to int64) ([]FieldChange, error)
+	Rollback(ctx context.Context, productID string, number int64, version int64) (domain.Product, error)
+}
+
+// service is a struct that contains the store of the revisions, the
+// products service the rollbacks write through and what decides if the
+// caller can read the revisions
+type service struct {
+	store      Store
+	products   products.Service
+	authorizer products.Authorizer
+}
+
+// ServiceOption is a function that configures an optional dependency of the service
+type ServiceOption func(*service)
+
+// WithAuthorizer sets what decides if the caller can read the revisions, the
+// same one of the products service, everything is allowed by default
+func WithAuthorizer(authorizer products.Authorizer) ServiceOption {
+	return func(s *service) {
+		s.authorizer = authorizer
+	}
+}
+
+// NewServiceRevisions is a function that loads the store and the products service into the service
+func NewServiceRevisions(store Store, productsService products.Service, options ...ServiceOption) Service {
+	s := &service{
+		store:    store,
+		products: productsService,
+		authorizer: products.AuthorizerFunc(func(ctx context.Context, permission products.Permission) error {
+			return nil
+		}),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// authorize is a function that checks the caller can read the products, a
+// revision is a copy of a product
+func (s *service) authorize(ctx context.Context) error {
+	if err := s.authorizer.Authorize(ctx, products.PermissionRead); err != nil {
+		if !errors.Is(err, products.ErrForbidden) {
+			slog.ErrorContext(ctx, "[RevisionsService][Authorize] error authorizing", "error", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// List is a function that calls the store for return the revisions of a product
+func (s *service) List(ctx context.Context, productID string) ([]Revision, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	// We call the store for get the revisions
+	list, err := s.store.List(ctx, productID)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[RevisionsService][List] error listing revisions", "error", err)
+		return nil, err
+	}
+
+	// We return the revisions
+	return list, nil
+}
+
+// Get is a function that calls the store for return a revision of a product by number
+func (s *service) Get(ctx context.Context, productID string, number int64) (Revision, error) {
+	if err := s.authorize(ctx); err != nil {
+		return Revision{}, err
+	}
+
+	// We call the store for get the revision
+	revision, err := s.store.Get(ctx, productID, number)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[RevisionsService][Get] error getting revision", "error", err)
+		return Revision{}, err
+	}
+
+	// We return the revision
+	return revision, nil
+}
+
+// Diff is a function that returns the fields that changed from a revision to another
+func (s *service) Diff(ctx context.Context, productID string, from, to int64) ([]FieldChange, error) {
+	// We get both revisions
+	fromRevision, err := s.Get(ctx, productID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	toRevision, err := s.Get(ctx, productID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	// We return the fields that changed
+	return Diff(fromRevision, toRevision)
+}
+
+// Rollback is a function that writes the fields of a revision over the
+// product, as a new update. The version is the one expected, 0 for any.
+// The product must not be in the trash.
+func (s *service) Rollback(ctx context.Context, productID string, number int64, version int64) (domain.Product, error) {
+	// We get the revision to go back to
+	revision, err := s.Get(ctx, productID, number)
+	if err != nil {
+		return domain.Product{}, err
+	}
+
+	// We call the products service so the rollback is validated and recorded like any update
+	product := revision.Product
+	product.Version = version
+	product.DeletedAt = nil
+	product, err = s.products.Update(ctx, product, productID)
+
+	// If we have an error log it and return it
+	if err != nil {
+		slog.ErrorContext(ctx, "[RevisionsService][Rollback] error rolling back product", "error", err)
+		return domain.Product{}, err
+	}
+
+	// We return the product as it is now
+	return product, nil
+}
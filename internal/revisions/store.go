@@ -0,0 +1,89 @@
+package revisions
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Store represents a contract with all the functions that need to be implemented.
+// Revisions are immutable: Append returns ErrConflict for a number that exists.
+type Store interface {
+	Append(ctx context.Context, revision Revision) error
+	List(ctx context.Context, productID string) ([]Revision, error)
+	Get(ctx context.Context, productID string, number int64) (Revision, error)
+	ProductIDs(ctx context.Context) ([]string, error)
+}
+
+// memoryStore is a struct that contains the revisions of each product sorted by number
+type memoryStore struct {
+	mu        sync.RWMutex
+	revisions map[string][]Revision
+}
+
+// NewMemoryStore is a function that returns an empty store in memory
+func NewMemoryStore() Store {
+	return &memoryStore{revisions: map[string][]Revision{}}
+}
+
+// Append is a function that adds the revision to the store
+func (s *memoryStore) Append(ctx context.Context, revision Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.add(revision)
+}
+
+// add keeps the revisions of the product sorted by number. It must be called with mu held.
+func (s *memoryStore) add(revision Revision) error {
+	list := s.revisions[revision.ProductID]
+
+	key := sort.Search(len(list), func(i int) bool { return list[i].Number >= revision.Number })
+	if key < len(list) && list[key].Number == revision.Number {
+		return ErrConflict
+	}
+
+	list = append(list, Revision{})
+	copy(list[key+1:], list[key:])
+	list[key] = revision
+	s.revisions[revision.ProductID] = list
+
+	return nil
+}
+
+// List is a function that returns the revisions of a product sorted by number
+func (s *memoryStore) List(ctx context.Context, productID string) ([]Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// We return a copy so later appends don't race with the caller
+	return append([]Revision{}, s.revisions[productID]...), nil
+}
+
+// Get is a function that returns a revision of a product by number
+func (s *memoryStore) Get(ctx context.Context, productID string, number int64) (Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := s.revisions[productID]
+	key := sort.Search(len(list), func(i int) bool { return list[i].Number >= number })
+	if key < len(list) && list[key].Number == number {
+		return list[key], nil
+	}
+
+	return Revision{}, ErrNotFound
+}
+
+// ProductIDs is a function that returns the ids of the products with revisions sorted
+func (s *memoryStore) ProductIDs(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]string, 0, len(s.revisions))
+	for id := range s.revisions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
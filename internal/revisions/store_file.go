@@ -0,0 +1,78 @@
+package revisions
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/filestore"
+)
+
+// FileStore is a memory store that persists every revision in an append-only
+// JSONL file. Revisions never change, so the file is never rewritten.
+type FileStore struct {
+	memory *memoryStore
+
+	// mu serializes the appends so the file keeps the order of the memory
+	mu  sync.Mutex
+	log *filestore.Log
+}
+
+// FileStore must keep implementing every method of Store
+var _ Store = (*FileStore)(nil)
+
+// NewFileStore is a function that loads the revisions of the file in path
+// and opens it to append the new ones. A missing file is an empty store.
+func NewFileStore(path string) (*FileStore, error) {
+	memory := NewMemoryStore().(*memoryStore)
+
+	log, err := filestore.OpenLog(path, 0o644, func(revision Revision) error {
+		// A revision appended twice is the same revision
+		if err := memory.add(revision); err != nil && !errors.Is(err, ErrConflict) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{memory: memory, log: log}, nil
+}
+
+// Append is a function that writes the revision at the end of the file,
+// syncs it and adds it to the store
+func (f *FileStore) Append(ctx context.Context, revision Revision) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.memory.Get(ctx, revision.ProductID, revision.Number); err == nil {
+		return ErrConflict
+	}
+
+	if err := f.log.Append(revision); err != nil {
+		return err
+	}
+
+	return f.memory.Append(ctx, revision)
+}
+
+// List is a function that returns the revisions of a product sorted by number
+func (f *FileStore) List(ctx context.Context, productID string) ([]Revision, error) {
+	return f.memory.List(ctx, productID)
+}
+
+// Get is a function that returns a revision of a product by number
+func (f *FileStore) Get(ctx context.Context, productID string, number int64) (Revision, error) {
+	return f.memory.Get(ctx, productID, number)
+}
+
+// ProductIDs is a function that returns the ids of the products with revisions sorted
+func (f *FileStore) ProductIDs(ctx context.Context) ([]string, error) {
+	return f.memory.ProductIDs(ctx)
+}
+
+// Close is a function that closes the file
+func (f *FileStore) Close() error {
+	return f.log.Close()
+}
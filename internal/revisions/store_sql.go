@@ -0,0 +1,129 @@
+package revisions
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+)
+
+// revisionColumns is the list of columns that maps a Revision, in the same
+// order that scanRevision reads them
+const revisionColumns = "product_id, number, action, actor, created_at, product"
+
+// sqlStore is a struct that contains the sql connection of the revisions.
+// The schema is created by the internal/migrations package.
+type sqlStore struct {
+	db      *sql.DB
+	dialect products.Dialect
+}
+
+// NewSQLStore is a function that loads the sql connection into the store
+func NewSQLStore(db *sql.DB, dialect products.Dialect) Store {
+	return &sqlStore{db: db, dialect: dialect}
+}
+
+// scanRevision is a function that reads a row with revisionColumns into a Revision
+func scanRevision(row products.RowScanner) (Revision, error) {
+	var revision Revision
+	var product []byte
+
+	err := row.Scan(
+		&revision.ProductID,
+		&revision.Number,
+		&revision.Action,
+		&revision.Actor,
+		&revision.CreatedAt,
+		&product,
+	)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	if err := json.Unmarshal(product, &revision.Product); err != nil {
+		return Revision{}, err
+	}
+
+	return revision, nil
+}
+
+// Append is a function that inserts the revision in the db
+func (s *sqlStore) Append(ctx context.Context, revision Revision) error {
+	product, err := json.Marshal(revision.Product)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		s.dialect.Rebind("INSERT INTO product_revisions ("+revisionColumns+") VALUES (?, ?, ?, ?, ?, ?)"),
+		revision.ProductID,
+		revision.Number,
+		revision.Action,
+		revision.Actor,
+		revision.CreatedAt.UTC(),
+		string(product),
+	)
+	if _, ok := products.UniqueViolation(err); ok {
+		return ErrConflict
+	}
+
+	return err
+}
+
+// List is a function that returns the revisions of a product sorted by number
+func (s *sqlStore) List(ctx context.Context, productID string) ([]Revision, error) {
+	rows, err := s.db.QueryContext(ctx,
+		s.dialect.Rebind("SELECT "+revisionColumns+" FROM product_revisions WHERE product_id = ? ORDER BY number"),
+		productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Revision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, revision)
+	}
+
+	return list, rows.Err()
+}
+
+// Get is a function that returns a revision of a product by number
+func (s *sqlStore) Get(ctx context.Context, productID string, number int64) (Revision, error) {
+	row := s.db.QueryRowContext(ctx,
+		s.dialect.Rebind("SELECT "+revisionColumns+" FROM product_revisions WHERE product_id = ? AND number = ?"),
+		productID, number)
+
+	revision, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Revision{}, ErrNotFound
+	}
+
+	return revision, err
+}
+
+// ProductIDs is a function that returns the ids of the products with revisions sorted
+func (s *sqlStore) ProductIDs(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT product_id FROM product_revisions ORDER BY product_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
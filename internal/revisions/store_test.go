@@ -0,0 +1,160 @@
+package revisions
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/internal/domain"
+	"github.com/burgosfacundo/ApiGo.git/internal/migrations/migrationstest"
+	"github.com/burgosfacundo/ApiGo.git/internal/products"
+)
+
+// newTestRevision is a function that returns a revision of the product 1
+func newTestRevision(number int64, name string) Revision {
+	at := time.Now().UTC().Truncate(time.Second)
+	return Revision{
+		ProductID: "1",
+		Number:    number,
+		Action:    products.ActionUpdate,
+		Actor:     "tester",
+		CreatedAt: at,
+		Product: domain.Product{
+			Id:         "1",
+			Name:       name,
+			Quantity:   1,
+			CodeValue:  "code-1",
+			Expiration: at.Add(24 * time.Hour),
+			Price:      10.5,
+			Version:    number,
+			UpdatedAt:  at,
+		},
+	}
+}
+
+// testStoreContract checks the behaviour every Store implementation must share
+func testStoreContract(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	// We append out of order, the list is still sorted by number
+	for _, revision := range []Revision{newTestRevision(2, "B"), newTestRevision(1, "A")} {
+		if err := store.Append(ctx, revision); err != nil {
+			t.Fatalf("append %d: %v", revision.Number, err)
+		}
+	}
+
+	if err := store.Append(ctx, newTestRevision(2, "C")); !errors.Is(err, ErrConflict) {
+		t.Fatalf("append existing number: got %v, want ErrConflict", err)
+	}
+
+	list, err := store.List(ctx, "1")
+	if err != nil || len(list) != 2 || list[0].Number != 1 || list[1].Product.Name != "B" {
+		t.Fatalf("list: got %+v, %v", list, err)
+	}
+
+	if list, err := store.List(ctx, "missing"); err != nil || len(list) != 0 {
+		t.Fatalf("list missing product: got %+v, %v", list, err)
+	}
+
+	revision, err := store.Get(ctx, "1", 1)
+	if err != nil || revision.Product.Name != "A" || revision.Actor != "tester" || !revision.CreatedAt.Equal(list[0].CreatedAt) {
+		t.Fatalf("get: got %+v, %v", revision, err)
+	}
+
+	if _, err := store.Get(ctx, "1", 3); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get missing: got %v, want ErrNotFound", err)
+	}
+
+	// Every product with revisions is listed once
+	other := newTestRevision(1, "D")
+	other.ProductID, other.Product.Id = "10", "10"
+	if err := store.Append(ctx, other); err != nil {
+		t.Fatalf("append product 10: %v", err)
+	}
+	if ids, err := store.ProductIDs(ctx); err != nil || len(ids) != 2 || ids[0] != "1" || ids[1] != "10" {
+		t.Fatalf("product ids: got %v, %v", ids, err)
+	}
+
+	changes, err := Diff(list[0], list[1])
+	if err != nil || len(changes) != 2 || changes[0].Field != "name" || changes[0].From != "A" || changes[0].To != "B" {
+		t.Fatalf("diff: got %+v, %v", changes, err)
+	}
+}
+
+func TestMemoryStoreContract(t *testing.T) {
+	testStoreContract(t, NewMemoryStore())
+}
+
+func TestFileStoreContract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json.revisions")
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+	testStoreContract(t, store)
+	store.Close()
+
+	// The revisions are still there after reopening the file
+	store, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen file store: %v", err)
+	}
+	defer store.Close()
+
+	if list, err := store.List(context.Background(), "1"); err != nil || len(list) != 2 {
+		t.Fatalf("list after reopening: got %+v, %v", list, err)
+	}
+}
+
+func TestFileStoreTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "products.json.revisions")
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("new file store: %v", err)
+	}
+	if err := store.Append(context.Background(), newTestRevision(1, "A")); err != nil {
+		t.Fatalf("append 1: %v", err)
+	}
+	store.Close()
+
+	// We crash in the middle of the append of the revision 2
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := file.WriteString(`{"product_id":"1","number":2,"act`); err != nil {
+		t.Fatalf("write partial line: %v", err)
+	}
+	file.Close()
+
+	// The torn line is dropped and the next revision starts on its own line
+	store, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen after the crash: %v", err)
+	}
+	if err := store.Append(context.Background(), newTestRevision(2, "B")); err != nil {
+		t.Fatalf("append 2: %v", err)
+	}
+	store.Close()
+
+	store, err = NewFileStore(path)
+	if err != nil {
+		t.Fatalf("reopen after the append: %v", err)
+	}
+	defer store.Close()
+
+	if list, err := store.List(context.Background(), "1"); err != nil || len(list) != 2 || list[1].Product.Name != "B" {
+		t.Fatalf("list after reopening: got %+v, %v", list, err)
+	}
+}
+
+func TestSQLiteStoreContract(t *testing.T) {
+	db := migrationstest.OpenSQLite(t)
+
+	testStoreContract(t, NewSQLStore(db, products.DialectSQLite))
+}
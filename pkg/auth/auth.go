@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Errors that can be returned in the response
+var (
+	ErrUnauthenticated   = errors.New("authentication required")
+	ErrInsufficientScope = errors.New("insufficient scope")
+
+	// ErrInvalidCredentials is wrapped by the errors of a KeyAuthenticator for a wrong key
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// ClaimsKey is the key of the claims in the gin context
+const ClaimsKey = "auth.claims"
+
+// APIKeyHeader is the header of the api keys
+const APIKeyHeader = "X-API-Key"
+
+// Claims is a struct that contains the claims of a verified token. The scopes
+// are read from the space separated scope claim (RFC 8693) or the scp array.
+// Roles are the roles the issuer grants to the subject.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Scopes is a function that returns the scopes granted by the claims
+func (c *Claims) Scopes() []string {
+	return append(strings.Fields(c.Scope), c.Scp...)
+}
+
+// HasScope is a function that returns if the claims grant the scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, granted := range c.Scopes() {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// claimsKey is the key of the claims in a context
+type claimsKey struct{}
+
+// WithClaims is a function that returns a copy of ctx that carries the claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// FromContext is a function that returns the claims carried by ctx
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// SetClaims is a function that stores the claims in the gin context, under
+// ClaimsKey, and in the context of the request for the services it calls
+func SetClaims(ctx *gin.Context, claims *Claims) {
+	ctx.Set(ClaimsKey, claims)
+	ctx.Request = ctx.Request.WithContext(WithClaims(ctx.Request.Context(), claims))
+}
+
+// BearerToken is a function that returns the token of the Authorization
+// header, if it uses the Bearer scheme
+func BearerToken(ctx *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// KeyAuthenticator represents a contract of what checks the api keys sent in
+// the APIKeyHeader. A wrong key is an error that wraps ErrInvalidCredentials,
+// any other error is a failure to check it.
+type KeyAuthenticator interface {
+	AuthenticateKey(ctx context.Context, key string) (*Claims, error)
+}
+
+// KeyAuthenticatorFunc is an adapter to use a function as a KeyAuthenticator
+type KeyAuthenticatorFunc func(ctx context.Context, key string) (*Claims, error)
+
+// AuthenticateKey calls the function
+func (f KeyAuthenticatorFunc) AuthenticateKey(ctx context.Context, key string) (*Claims, error) {
+	return f(ctx, key)
+}
+
+// RequireScopes is a function that only lets through the requests whose
+// claims grant every scope. It must run after the authentication, the
+// requests without claims answer 401 and the ones missing a scope 403.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := FromContext(ctx.Request.Context())
+		if !ok {
+			problem.Abort(ctx, ErrUnauthenticated)
+			return
+		}
+
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				ctx.Header("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, strings.Join(scopes, " ")))
+				problem.Abort(ctx, fmt.Errorf("%w: %s is required", ErrInsufficientScope, scope))
+				return
+			}
+		}
+
+		ctx.Next()
+	}
+}
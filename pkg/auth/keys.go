@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidKey is returned when a key can't be used to verify tokens
+var ErrInvalidKey = errors.New("invalid key")
+
+// KeySet is a struct that contains the public keys that verify the RS256 and
+// ES256 tokens, by key id
+type KeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+// NewKeySet is a function that returns an empty key set
+func NewKeySet() *KeySet {
+	return &KeySet{keys: map[string]crypto.PublicKey{}}
+}
+
+// Add is a function that adds a RSA or P-256 ECDSA public key with the key id
+func (s *KeySet) Add(kid string, key crypto.PublicKey) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return fmt.Errorf("%w: %s: RSA keys must have at least 2048 bits", ErrInvalidKey, kid)
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return fmt.Errorf("%w: %s: ES256 needs a P-256 key", ErrInvalidKey, kid)
+		}
+	default:
+		return fmt.Errorf("%w: %s: unsupported key type %T", ErrInvalidKey, kid, key)
+	}
+
+	if _, ok := s.keys[kid]; ok {
+		return fmt.Errorf("%w: key id %s is repeated", ErrInvalidKey, kid)
+	}
+
+	s.keys[kid] = key
+	return nil
+}
+
+// Len is a function that returns how many keys the set has
+func (s *KeySet) Len() int {
+	return len(s.keys)
+}
+
+// lookup is a function that returns the key with the id that match accepts.
+// Without a key id the key is only found if it's the single one match accepts.
+func (s *KeySet) lookup(kid string, match func(crypto.PublicKey) bool) (crypto.PublicKey, error) {
+	if kid != "" {
+		key, ok := s.keys[kid]
+		if !ok || !match(key) {
+			return nil, fmt.Errorf("%w: unknown key id %q", ErrInvalidKey, kid)
+		}
+		return key, nil
+	}
+
+	var found crypto.PublicKey
+	for _, key := range s.keys {
+		if !match(key) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: the token has no key id and several keys match", ErrInvalidKey)
+		}
+		found = key
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("%w: no key for the algorithm of the token", ErrInvalidKey)
+	}
+	return found, nil
+}
+
+// has is a function that returns if the set has a key that match accepts
+func (s *KeySet) has(match func(crypto.PublicKey) bool) bool {
+	for _, key := range s.keys {
+		if match(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRSA is a function that returns if the key verifies RS256
+func isRSA(key crypto.PublicKey) bool {
+	_, ok := key.(*rsa.PublicKey)
+	return ok
+}
+
+// isECDSA is a function that returns if the key verifies ES256
+func isECDSA(key crypto.PublicKey) bool {
+	_, ok := key.(*ecdsa.PublicKey)
+	return ok
+}
+
+// LoadPEM is a function that adds the public key of a PEM file, in PKIX,
+// PKCS #1 or a certificate. The key id is the name of the file without the extension.
+func (s *KeySet) LoadPEM(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return fmt.Errorf("%w: %s is not a PEM file", ErrInvalidKey, path)
+	}
+
+	var key crypto.PublicKey
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var certificate *x509.Certificate
+		certificate, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = certificate.PublicKey
+		}
+	default:
+		return fmt.Errorf("%w: %s has a %s, not a public key", ErrInvalidKey, path, block.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidKey, path, err)
+	}
+
+	kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return s.Add(kid, key)
+}
+
+// jwk is a struct that represents a public key of a JWKS (RFC 7517)
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// LoadJWKS is a function that adds the RSA and EC signing keys of a JWKS file.
+// The keys for encryption are skipped.
+func (s *KeySet) LoadJWKS(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &set); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidKey, path, err)
+	}
+
+	for i, key := range set.Keys {
+		if key.Use == "enc" {
+			continue
+		}
+
+		publicKey, err := key.publicKey()
+		if err != nil {
+			return fmt.Errorf("%s key %d: %w", path, i, err)
+		}
+
+		kid := key.Kid
+		if kid == "" {
+			kid = fmt.Sprintf("%s#%d", filepath.Base(path), i)
+		}
+		if err := s.Add(kid, publicKey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// publicKey is a function that builds the public key of the JWK
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBytes(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBytes(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("%w: invalid RSA exponent", ErrInvalidKey)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("%w: unsupported curve %q", ErrInvalidKey, k.Crv)
+		}
+		x, err := decodeBytes(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBytes(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: P-256 coordinates must have 32 bytes", ErrInvalidKey)
+		}
+
+		// We check the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %q", ErrInvalidKey, k.Kty)
+	}
+}
+
+// decodeBytes is a function that reads a base64url member of a JWK
+func decodeBytes(value string) ([]byte, error) {
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(content) == 0 {
+		return nil, fmt.Errorf("%w: invalid base64url value", ErrInvalidKey)
+	}
+	return content, nil
+}
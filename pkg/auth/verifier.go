@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minSecretLength is the shortest HS256 secret accepted, the size of the hash
+const minSecretLength = 32
+
+// Config is a struct that contains the keys and the expected claims of the tokens
+type Config struct {
+	// Secret verifies the HS256 tokens, empty to reject them
+	Secret []byte
+
+	// Keys verify the RS256 and ES256 tokens, nil to reject them
+	Keys *KeySet
+
+	// Issuer and Audience must be the iss and one of the aud of every token
+	Issuer   string
+	Audience string
+
+	// Leeway is the clock skew allowed when checking exp, nbf and iat
+	Leeway time.Duration
+}
+
+// Verifier is a struct that checks the signature and the claims of the tokens
+type Verifier struct {
+	config Config
+	parser *jwt.Parser
+}
+
+// NewVerifier is a function that returns a verifier of the tokens signed with
+// the keys of the config. Only the algorithms that have a key are accepted.
+func NewVerifier(config Config) (*Verifier, error) {
+	if config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("the issuer and the audience of the tokens are required")
+	}
+
+	if len(config.Secret) > 0 && len(config.Secret) < minSecretLength {
+		return nil, fmt.Errorf("%w: the HS256 secret must have at least %d bytes", ErrInvalidKey, minSecretLength)
+	}
+
+	var methods []string
+	if len(config.Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if config.Keys != nil {
+		if config.Keys.has(isRSA) {
+			methods = append(methods, jwt.SigningMethodRS256.Alg())
+		}
+		if config.Keys.has(isECDSA) {
+			methods = append(methods, jwt.SigningMethodES256.Alg())
+		}
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no key to verify the tokens")
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(methods),
+		jwt.WithIssuer(config.Issuer),
+		jwt.WithAudience(config.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(config.Leeway),
+	)
+
+	return &Verifier{config: config, parser: parser}, nil
+}
+
+// Verify is a function that returns the claims of the token if it's signed by
+// a key of the verifier, it's not expired and it's for the issuer and audience
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	claims := &Claims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("the token has no subject")
+	}
+
+	return claims, nil
+}
+
+// key is a function that returns the key that verifies the token by its algorithm.
+// The HS256 secret is never used for the other algorithms and the other way round.
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	switch token.Method {
+	case jwt.SigningMethodHS256:
+		return v.config.Secret, nil
+	case jwt.SigningMethodRS256:
+		return v.config.Keys.lookup(kid, isRSA)
+	case jwt.SigningMethodES256:
+		return v.config.Keys.lookup(kid, isECDSA)
+	default:
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+}
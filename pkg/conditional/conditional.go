@@ -0,0 +1,161 @@
+package conditional
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrPreconditionFailed is returned when no entity tag of If-Match is the current one
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ETag is a function that returns the strong entity tag of a version of the
+// resource with the id, like "3-9f86d081884c7d65". Versions start again on
+// every resource, so the hash of the id keeps apart the tags of a deleted
+// product and of the new one that took its code.
+func ETag(id string, version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + "-" + idHash(id) + `"`
+}
+
+// idHash is a function that returns the part of the entity tags that tells the resources apart
+func idHash(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:8])
+}
+
+// HashETag is a function that returns a strong entity tag of the content of a
+// representation that has no version, like a listing
+func HashETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// SetValidators is a function that writes the entity tag of the version of
+// the resource with the id and the last modification in the response. A zero
+// lastModified is not written.
+func SetValidators(ctx *gin.Context, id string, version int64, lastModified time.Time) {
+	ctx.Header("ETag", ETag(id, version))
+	if !lastModified.IsZero() {
+		ctx.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseETag is a function that returns the version of a strong entity tag of
+// the resource with the id. Weak tags never match in If-Match, and neither do
+// the tags of other resources, so they are not parsed.
+func parseETag(tag string, id string) (int64, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+
+	number, hash, found := strings.Cut(tag[1:len(tag)-1], "-")
+	if !found || hash != idHash(id) {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+// ExpectedVersion is a function that reads the If-Match header of the request
+// and returns the version of the resource with the id a write must find,
+// where 0 means any version: without the header or with * the write is
+// unconditional. A single tag is the expected version. With several tags
+// current is called to find the one that matches. ErrPreconditionFailed is
+// returned when no tag can match.
+func ExpectedVersion(ctx *gin.Context, id string, current func() (int64, error)) (int64, error) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	tags := strings.Split(header, ",")
+	if len(tags) == 1 {
+		version, ok := parseETag(strings.TrimSpace(tags[0]), id)
+		if !ok {
+			return 0, ErrPreconditionFailed
+		}
+		return version, nil
+	}
+
+	version, err := current()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, tag := range tags {
+		if tagVersion, ok := parseETag(strings.TrimSpace(tag), id); ok && tagVersion == version {
+			return version, nil
+		}
+	}
+
+	return 0, ErrPreconditionFailed
+}
+
+// NotModified is a function that writes the validators of the representation
+// in the response and reports if the copy of the client is still fresh
+// according to If-None-Match, or If-Modified-Since when there is no
+// If-None-Match. In that case it answers 304 and the handler must return.
+// A zero lastModified is not written nor compared.
+func NotModified(ctx *gin.Context, etag string, lastModified time.Time) bool {
+	ctx.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		ctx.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if !fresh(ctx.Request, etag, lastModified) {
+		return false
+	}
+
+	ctx.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// fresh is a function that evaluates the conditions of a GET or HEAD request
+func fresh(request *http.Request, etag string, lastModified time.Time) bool {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match uses the weak comparison, so W/"1" matches "1"
+	if header := strings.TrimSpace(request.Header.Get("If-None-Match")); header != "" {
+		if header == "*" {
+			return true
+		}
+		for _, tag := range strings.Split(header, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(request.Header.Get("If-Modified-Since"))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+
+	// Last-Modified has a precision of seconds
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// CacheControl is a middleware that sets the Cache-Control policy of a route.
+// An empty policy leaves the header unset.
+func CacheControl(policy string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if policy != "" {
+			ctx.Header("Cache-Control", policy)
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,142 @@
+package conditional
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testContext is a function that returns the context of a request with the headers
+func testContext(method string, headers map[string]string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(method, "/", nil)
+	for name, value := range headers {
+		ctx.Request.Header.Set(name, value)
+	}
+	return ctx, recorder
+}
+
+func TestETag(t *testing.T) {
+	// The same version of two resources has different tags
+	if ETag("1", 1) == ETag("2", 1) {
+		t.Fatalf("got %s for the version 1 of both resources", ETag("1", 1))
+	}
+	if ETag("1", 1) == ETag("1", 2) {
+		t.Fatalf("got %s for both versions", ETag("1", 1))
+	}
+
+	if version, ok := parseETag(ETag("1", 12), "1"); !ok || version != 12 {
+		t.Fatalf("parse: got %d, %v", version, ok)
+	}
+	for _, tag := range []string{ETag("2", 12), `W/` + ETag("1", 12), `"12"`, `12`, `"0-` + idHash("1") + `"`, `"x-` + idHash("1") + `"`} {
+		if version, ok := parseETag(tag, "1"); ok {
+			t.Errorf("parse %s: got version %d", tag, version)
+		}
+	}
+}
+
+func TestExpectedVersion(t *testing.T) {
+	current := func() (int64, error) { return 3, nil }
+	failed := errors.New("failed")
+
+	cases := []struct {
+		name    string
+		ifMatch string
+		current func() (int64, error)
+		want    int64
+		err     error
+	}{
+		{name: "no header", want: 0},
+		{name: "any", ifMatch: "*", want: 0},
+		{name: "single tag", ifMatch: ETag("1", 2), want: 2},
+		{name: "tag of another resource", ifMatch: ETag("2", 2), err: ErrPreconditionFailed},
+		{name: "weak tag", ifMatch: "W/" + ETag("1", 2), err: ErrPreconditionFailed},
+		{name: "malformed", ifMatch: `"abc`, err: ErrPreconditionFailed},
+		{name: "several tags with the current", ifMatch: ETag("1", 2) + ", " + ETag("1", 3), current: current, want: 3},
+		{name: "several tags without the current", ifMatch: ETag("1", 1) + ", " + ETag("1", 2), current: current, err: ErrPreconditionFailed},
+		{name: "several tags and current fails", ifMatch: ETag("1", 1) + ", " + ETag("1", 2), current: func() (int64, error) { return 0, failed }, err: failed},
+	}
+	for _, c := range cases {
+		ctx, _ := testContext(http.MethodPut, map[string]string{"If-Match": c.ifMatch})
+		if c.current == nil {
+			c.current = func() (int64, error) {
+				t.Fatalf("%s: current was called", c.name)
+				return 0, nil
+			}
+		}
+
+		version, err := ExpectedVersion(ctx, "1", c.current)
+		if version != c.want || !errors.Is(err, c.err) {
+			t.Errorf("%s: got %d, %v, want %d, %v", c.name, version, err, c.want, c.err)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	updated := time.Date(2024, 1, 1, 12, 0, 0, 500, time.UTC)
+	etag := ETag("1", 2)
+
+	cases := []struct {
+		name         string
+		method       string
+		headers      map[string]string
+		lastModified time.Time
+		want         bool
+	}{
+		{name: "no conditions", method: http.MethodGet, lastModified: updated, want: false},
+		{name: "same tag", method: http.MethodGet, headers: map[string]string{"If-None-Match": etag}, want: true},
+		{name: "weak tag", method: http.MethodGet, headers: map[string]string{"If-None-Match": "W/" + etag}, want: true},
+		{name: "one of the tags", method: http.MethodHead, headers: map[string]string{"If-None-Match": ETag("1", 1) + ", " + etag}, want: true},
+		{name: "any", method: http.MethodGet, headers: map[string]string{"If-None-Match": "*"}, want: true},
+		{name: "other tag", method: http.MethodGet, headers: map[string]string{"If-None-Match": ETag("2", 2)}, want: false},
+		{name: "not a read", method: http.MethodPut, headers: map[string]string{"If-None-Match": etag}, want: false},
+		{
+			name:         "not modified since",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)},
+			lastModified: updated,
+			want:         true,
+		},
+		{
+			name:         "modified since",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-Modified-Since": updated.Add(-time.Second).Format(http.TimeFormat)},
+			lastModified: updated,
+			want:         false,
+		},
+		{
+			// If-None-Match wins over If-Modified-Since
+			name:         "other tag not modified since",
+			method:       http.MethodGet,
+			headers:      map[string]string{"If-None-Match": ETag("1", 1), "If-Modified-Since": updated.Format(http.TimeFormat)},
+			lastModified: updated,
+			want:         false,
+		},
+		{
+			// Without a Last-Modified there is nothing to compare
+			name:    "no last modified",
+			method:  http.MethodGet,
+			headers: map[string]string{"If-Modified-Since": updated.Format(http.TimeFormat)},
+			want:    false,
+		},
+	}
+	for _, c := range cases {
+		ctx, recorder := testContext(c.method, c.headers)
+
+		got := NotModified(ctx, etag, c.lastModified)
+		if got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+		if got && recorder.Code != http.StatusNotModified {
+			t.Errorf("%s: got status %d, want 304", c.name, recorder.Code)
+		}
+		if recorder.Header().Get("ETag") != etag || (recorder.Header().Get("Last-Modified") != "") != !c.lastModified.IsZero() {
+			t.Errorf("%s: got headers %v", c.name, recorder.Header())
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package identity
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Anonymous is the subject of the requests nobody authenticated
+const Anonymous = "anonymous"
+
+// System is the subject of the jobs of the server, like the purge of the trash
+const System = "system"
+
+// subjectKey is the key of the subject in a context
+type subjectKey struct{}
+
+// WithSubject is a function that returns a copy of ctx that carries the
+// subject, the one who makes the request
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// Subject is a function that returns the subject carried by ctx, Anonymous if there is none
+func Subject(ctx context.Context) string {
+	if subject, ok := ctx.Value(subjectKey{}).(string); ok && subject != "" {
+		return subject
+	}
+	return Anonymous
+}
+
+// SetSubject is a function that stores the subject in the context of the request,
+// so the handlers and the services it calls can read it
+func SetSubject(ctx *gin.Context, subject string) {
+	ctx.Request = ctx.Request.WithContext(WithSubject(ctx.Request.Context(), subject))
+}
@@ -1,25 +1,97 @@
 package middleware
 
 import (
-	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+	"github.com/burgosfacundo/ApiGo.git/pkg/identity"
+	"github.com/burgosfacundo/ApiGo.git/pkg/requestinfo"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func Logger() gin.HandlerFunc {
+// redacted replaces the value of the sensitive headers in the log
+const redacted = "[REDACTED]"
+
+// DefaultRedactedHeaders are the headers with credentials, never logged
+var DefaultRedactedHeaders = []string{"token", "Authorization", "Proxy-Authorization", auth.APIKeyHeader, "Cookie"}
+
+// LoggerConfig is a struct that contains the settings of the request log
+type LoggerConfig struct {
+	// SampleRate is the fraction of the successful requests that are logged,
+	// from 0 to 1. The requests that fail are always logged.
+	SampleRate float64
+
+	// Headers logs the headers of the requests, the Redact ones are replaced
+	Headers bool
+	Redact  []string
+}
+
+// Logger is a function that logs one line per request with the method, the
+// route, the status, the latency, the bytes written, the client ip, the
+// request id, the subject and the trace id when the request is traced. The
+// requests that fail with 5xx are logged as errors, the ones with 4xx as
+// warnings and the rest as info.
+func Logger(logger *slog.Logger, config LoggerConfig) gin.HandlerFunc {
+	redact := map[string]bool{}
+	for _, name := range config.Redact {
+		redact[http.CanonicalHeaderKey(name)] = true
+	}
+
 	return func(ctx *gin.Context) {
-		verb := ctx.Request.Method
-		time := time.Now()
-		url := ctx.Request.URL
-		var size int
+		start := time.Now()
 
 		ctx.Next()
 
-		if ctx.Writer != nil {
-			size = ctx.Writer.Size()
+		status := ctx.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		// We skip the line before building it when it won't be written
+		if !logger.Enabled(ctx.Request.Context(), level) {
+			return
+		}
+		if level == slog.LevelInfo && config.SampleRate < 1 && rand.Float64() >= config.SampleRate {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("route", ctx.FullPath()),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(0, ctx.Writer.Size())),
+			slog.String("client_ip", ctx.ClientIP()),
+			slog.String("request_id", requestinfo.RequestID(ctx.Request.Context())),
+			slog.String("subject", identity.Subject(ctx.Request.Context())),
+		}
+
+		if span := trace.SpanContextFromContext(ctx.Request.Context()); span.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", span.TraceID().String()))
+		}
+
+		if config.Headers {
+			headers := make([]any, 0, len(ctx.Request.Header))
+			for name, values := range ctx.Request.Header {
+				value := strings.Join(values, ", ")
+				if redact[http.CanonicalHeaderKey(name)] {
+					value = redacted
+				}
+				headers = append(headers, slog.String(name, value))
+			}
+			attrs = append(attrs, slog.Group("headers", headers...))
 		}
 
-		fmt.Printf("\nPath:%s\n	Verb: %s\n	Time: %v\n	Size: %d\n", url, verb, time, size)
+		logger.LogAttrs(ctx.Request.Context(), level, "request", attrs...)
 	}
 }
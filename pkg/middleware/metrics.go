@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// unmatchedRoute is the route label of the requests that matched no route,
+// so the paths of the clients don't make new series
+const unmatchedRoute = "unmatched"
+
+// otherMethod is the method label of the requests with a method that is not
+// standard, for the same reason
+const otherMethod = "other"
+
+// standardMethods are the methods that are labeled by their name
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// methodLabel is a function that returns the method label of a request
+func methodLabel(method string) string {
+	if standardMethods[method] {
+		return method
+	}
+	return otherMethod
+}
+
+// Metrics is a function that counts the requests and observes their latency
+// labeled by method, route template and status, with the collectors
+// registered in the registerer. It must run before problem.Recovery to see
+// the status of the requests that panic.
+func Metrics(registerer prometheus.Registerer) (gin.HandlerFunc, error) {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Latency of the HTTP requests by method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	if err := registerer.Register(requests); err != nil {
+		return nil, err
+	}
+	if err := registerer.Register(duration); err != nil {
+		registerer.Unregister(requests)
+		return nil, err
+	}
+
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := methodLabel(ctx.Request.Method)
+		status := strconv.Itoa(ctx.Writer.Status())
+
+		requests.WithLabelValues(method, route, status).Inc()
+		duration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}, nil
+}
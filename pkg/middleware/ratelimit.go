@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
+	"github.com/burgosfacundo/ApiGo.git/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitKey is a function that returns who a request is limited as: the
+// subject it authenticated as, which is the api key, the subject of the JWT or
+// TOKEN_ENV, or else the ip of the client
+func RateLimitKey(ctx *gin.Context) string {
+	if claims, ok := auth.FromContext(ctx.Request.Context()); ok {
+		return "sub:" + claims.Subject
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+// RateLimit is a function that limits the requests of every client to the
+// routes of the group, each group named differently has its own buckets. It
+// must run after the authentication to tell the clients apart by subject.
+//
+// The responses have the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset
+// and RateLimit-Policy headers, and the rejected ones a Retry-After. When the
+// store fails the request is let through.
+func RateLimit(store ratelimit.Store, name string, limit ratelimit.Limit) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Period.Seconds()))
+	if limit.Burst > 0 {
+		policy += fmt.Sprintf(";burst=%d", limit.Burst)
+	}
+
+	return func(ctx *gin.Context) {
+		result, err := store.Take(ctx, name+":"+RateLimitKey(ctx), limit, time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "[RateLimit] error taking a request", "group", name, "error", err)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		ctx.Header("RateLimit-Policy", policy)
+
+		if !result.Allowed {
+			ctx.Header("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+			problem.Abort(ctx, fmt.Errorf("%w: %s allows %s", ratelimit.ErrLimitExceeded, name, limit))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// AuthFailureLimit is a function that limits the failed authentications of
+// every client ip. It must run before the authentication, which the limits
+// of the groups can't: a client that used up its failures is answered 429
+// before its credentials are checked, so they can't be guessed at any pace.
+//
+// Every request takes from the bucket of its ip before it's authenticated,
+// so the guesses sent at once are counted too, and the ones that don't fail
+// with 401 give it back. When the store fails the request is let through.
+func AuthFailureLimit(store ratelimit.Store, limit ratelimit.Limit) gin.HandlerFunc {
+	if !limit.Enabled() {
+		return func(ctx *gin.Context) {
+			ctx.Next()
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		key := "auth:ip:" + ctx.ClientIP()
+
+		result, err := store.Take(ctx, key, limit, time.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "[AuthFailureLimit] error taking a request", "error", err)
+			ctx.Next()
+			return
+		}
+
+		if !result.Allowed {
+			ctx.Header("Retry-After", strconv.Itoa(max(1, ceilSeconds(result.RetryAfter))))
+			problem.Abort(ctx, fmt.Errorf("%w: authentication allows %s failures", ratelimit.ErrLimitExceeded, limit))
+			return
+		}
+
+		ctx.Next()
+
+		if ctx.Writer.Status() != http.StatusUnauthorized {
+			if err := store.Refund(ctx, key, limit, time.Now()); err != nil {
+				slog.ErrorContext(ctx, "[AuthFailureLimit] error refunding a request", "error", err)
+			}
+		}
+	}
+}
+
+// ceilSeconds is a function that returns the duration in whole seconds, rounded up
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/burgosfacundo/ApiGo.git/pkg/requestinfo"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header with the id of a request
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request id accepted from a client
+const maxRequestIDLength = 128
+
+// ClientIP is a function that stores the ip of the client in the context of every request
+func ClientIP() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestinfo.SetClientIP(ctx)
+		ctx.Next()
+	}
+}
+
+// RequestID is a function that gives every request an id, the one of its
+// X-Request-ID header or a new one, stores it in the context of the request
+// and answers it in the X-Request-ID header of the response
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		requestinfo.SetRequestID(ctx, id)
+		ctx.Header(RequestIDHeader, id)
+		ctx.Next()
+	}
+}
+
+// validRequestID is a function that returns if the id sent by a client can be
+// used, it only has visible ascii characters so it can't forge a log line
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, char := range id {
+		if char < '!' || char > '~' {
+			return false
+		}
+	}
+	return true
+}
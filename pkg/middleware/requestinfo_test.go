@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	cases := []struct {
+		id   string
+		want bool
+	}{
+		{id: "0191b7a2-6c3e-7d4f-9a1b-2c3d4e5f6a7b", want: true},
+		{id: "req_1:retry-2", want: true},
+		{id: strings.Repeat("a", maxRequestIDLength), want: true},
+		{id: "", want: false},
+		{id: strings.Repeat("a", maxRequestIDLength+1), want: false},
+		{id: "with space", want: false},
+		{id: "line\nbreak", want: false},
+		{id: "tab\t", want: false},
+		{id: "del\x7f", want: false},
+		{id: "ñandú", want: false},
+	}
+	for _, c := range cases {
+		if got := validRequestID(c.id); got != c.want {
+			t.Errorf("%q: got %v, want %v", c.id, got, c.want)
+		}
+	}
+}
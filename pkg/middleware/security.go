@@ -1,25 +1,217 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/burgosfacundo/ApiGo.git/pkg/auth"
+	"github.com/burgosfacundo/ApiGo.git/pkg/identity"
+	"github.com/burgosfacundo/ApiGo.git/pkg/problem"
 	"github.com/gin-gonic/gin"
 )
 
-// Auth is a function that authenticates token requests
-func Auth() gin.HandlerFunc {
+// ErrInvalidToken is returned when the token header is missing or wrong
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenSubject is the subject of the requests authenticated with TOKEN_ENV
+const TokenSubject = "token"
+
+// JWTSubjectPrefix goes before the sub claim of a JWT in the subject of its
+// requests, so an issuer can't sign the subject of TOKEN_ENV or of an api key
+const JWTSubjectPrefix = "jwt:"
+
+// Access is the authentication a route asks for
+type Access string
+
+// Accesses of the routes
+const (
+	// AccessPublic doesn't look at the token
+	AccessPublic Access = "public"
+
+	// AccessOptional authenticates the requests that send a token, the rest are anonymous
+	AccessOptional Access = "optional"
+
+	// AccessRequired rejects the requests without a valid token
+	AccessRequired Access = "required"
+)
+
+// ParseAccess is a function that reads the name of an access
+func ParseAccess(value string) (Access, error) {
+	switch access := Access(value); access {
+	case AccessPublic, AccessOptional, AccessRequired:
+		return access, nil
+	default:
+		return "", fmt.Errorf("unknown access %q", value)
+	}
+}
+
+// AuthPolicy is a struct that decides the access of the routes of a group.
+// Every write requires a token and the reads get the access of the policy,
+// unless a route is set apart with Public or Protect. The token is the
+// TOKEN_ENV in the token header, a JWT in the Authorization header or an api
+// key in the X-API-Key header.
+type AuthPolicy struct {
+	reads  Access
+	routes map[string]Access
+
+	verifier    *auth.Verifier
+	keys        auth.KeyAuthenticator
+	tokenScopes []string
+}
+
+// NewAuthPolicy is a function that returns a policy that gives reads the access
+func NewAuthPolicy(reads Access) *AuthPolicy {
+	return &AuthPolicy{reads: reads, routes: map[string]Access{}}
+}
+
+// WithVerifier is a function that makes the policy accept the bearer JWTs the verifier trusts
+func (p *AuthPolicy) WithVerifier(verifier *auth.Verifier) *AuthPolicy {
+	p.verifier = verifier
+	return p
+}
+
+// WithKeys is a function that makes the policy accept the api keys the authenticator trusts
+func (p *AuthPolicy) WithKeys(keys auth.KeyAuthenticator) *AuthPolicy {
+	p.keys = keys
+	return p
+}
+
+// WithTokenScopes is a function that sets the scopes granted to the requests
+// authenticated with TOKEN_ENV
+func (p *AuthPolicy) WithTokenScopes(scopes ...string) *AuthPolicy {
+	p.tokenScopes = scopes
+	return p
+}
+
+// Public is a function that opts the route out of the authentication. The path
+// is the full path of the route as registered, like /api/v1/product/:id.
+func (p *AuthPolicy) Public(method, path string) *AuthPolicy {
+	p.routes[method+" "+path] = AccessPublic
+	return p
+}
+
+// Protect is a function that makes the route require a token, even if it's a read
+func (p *AuthPolicy) Protect(method, path string) *AuthPolicy {
+	p.routes[method+" "+path] = AccessRequired
+	return p
+}
+
+// Access is a function that returns the access of the route
+func (p *AuthPolicy) Access(method, path string) Access {
+	if access, ok := p.routes[method+" "+path]; ok {
+		return access
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return p.reads
+	default:
+		return AccessRequired
+	}
+}
+
+// Middleware is a function that authenticates the requests of the group by the access of their route
+func (p *AuthPolicy) Middleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		tokenHeader := ctx.GetHeader("token")
-		tokenEnv := os.Getenv("TOKEN_ENV")
-
-		if tokenHeader == "" || tokenHeader != tokenEnv {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"message": "Invalid token",
-			})
-			return
-		} else {
+		switch p.Access(ctx.Request.Method, ctx.FullPath()) {
+		case AccessPublic:
 			ctx.Next()
+
+		case AccessOptional:
+			// We only check the token if the client sent one
+			if ctx.GetHeader("token") == "" && ctx.GetHeader("Authorization") == "" && ctx.GetHeader(auth.APIKeyHeader) == "" {
+				ctx.Next()
+				return
+			}
+			p.authenticate(ctx)
+
+		default:
+			p.authenticate(ctx)
 		}
 	}
 }
+
+// Auth is a function that authenticates token requests
+func Auth() gin.HandlerFunc {
+	return NewAuthPolicy(AccessRequired).authenticate
+}
+
+// authenticate is a function that checks the token of the request and stores
+// who made it and the claims of the token
+func (p *AuthPolicy) authenticate(ctx *gin.Context) {
+	if bearer, ok := auth.BearerToken(ctx); ok {
+		p.authenticateBearer(ctx, bearer)
+		return
+	}
+
+	if key := ctx.GetHeader(auth.APIKeyHeader); key != "" {
+		p.authenticateKey(ctx, key)
+		return
+	}
+
+	tokenHeader := ctx.GetHeader("token")
+	tokenEnv := os.Getenv("TOKEN_ENV")
+
+	if tokenHeader == "" || subtle.ConstantTimeCompare([]byte(tokenHeader), []byte(tokenEnv)) != 1 {
+		problem.Abort(ctx, ErrInvalidToken)
+		return
+	} else {
+		claims := &auth.Claims{Scope: strings.Join(p.tokenScopes, " ")}
+		claims.Subject = TokenSubject
+
+		auth.SetClaims(ctx, claims)
+		identity.SetSubject(ctx, TokenSubject)
+		ctx.Next()
+	}
+}
+
+// authenticateBearer is a function that checks the JWT of the request, the
+// subject of the request is the one of the token after JWTSubjectPrefix
+func (p *AuthPolicy) authenticateBearer(ctx *gin.Context, bearer string) {
+	if p.verifier == nil {
+		problem.Abort(ctx, fmt.Errorf("%w: bearer tokens are not enabled", ErrInvalidToken))
+		return
+	}
+
+	claims, err := p.verifier.Verify(bearer)
+	if err != nil {
+		ctx.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		problem.Abort(ctx, fmt.Errorf("%w: %v", ErrInvalidToken, err))
+		return
+	}
+
+	// The subject is the one every other part of the api sees: the audit, the
+	// roles and the rate limits
+	claims.Subject = JWTSubjectPrefix + claims.Subject
+
+	auth.SetClaims(ctx, claims)
+	identity.SetSubject(ctx, claims.Subject)
+	ctx.Next()
+}
+
+// authenticateKey is a function that checks the api key of the request, the
+// subject of the request is the one of the key
+func (p *AuthPolicy) authenticateKey(ctx *gin.Context, key string) {
+	if p.keys == nil {
+		problem.Abort(ctx, fmt.Errorf("%w: api keys are not enabled", ErrInvalidToken))
+		return
+	}
+
+	claims, err := p.keys.AuthenticateKey(ctx, key)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		problem.Abort(ctx, fmt.Errorf("%w: %v", ErrInvalidToken, err))
+		return
+	}
+	if err != nil {
+		problem.Abort(ctx, err)
+		return
+	}
+
+	auth.SetClaims(ctx, claims)
+	identity.SetSubject(ctx, claims.Subject)
+	ctx.Next()
+}
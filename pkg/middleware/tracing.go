@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/burgosfacundo/ApiGo.git/pkg/requestinfo"
+	"github.com/burgosfacundo/ApiGo.git/pkg/tracing"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing is a function that starts a server span for every request, child of
+// the one of the W3C traceparent header when the client sent it. The span is
+// named by the method and the route template and it's stored in the context of
+// the request, so the spans of the services and the repositories are its children.
+// It must run after RequestID to record the id of the request.
+func Tracing(provider trace.TracerProvider) gin.HandlerFunc {
+	tracer := provider.Tracer(tracing.InstrumentationName)
+
+	return func(ctx *gin.Context) {
+		parent := tracing.Propagator.Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+		// The route is only known once gin matched it, before the handlers run
+		route := ctx.FullPath()
+		name := ctx.Request.Method + " " + route
+		if route == "" {
+			name = ctx.Request.Method + " " + unmatchedRoute
+		}
+
+		spanCtx, span := tracer.Start(parent, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", ctx.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", ctx.Request.URL.Path),
+				attribute.String("client.address", ctx.ClientIP()),
+				attribute.String("request.id", requestinfo.RequestID(ctx.Request.Context())),
+			),
+		)
+		defer span.End()
+
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+
+		ctx.Next()
+
+		// Only the errors of the server are errors of its span
+		status := ctx.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
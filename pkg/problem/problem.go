@@ -0,0 +1,135 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of the RFC 7807 problem details
+const ContentType = "application/problem+json"
+
+// Codes of the problems that are not mapped from an error
+const (
+	CodeInternal         = "internal_error"
+	CodeRouteNotFound    = "route_not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+)
+
+// typePrefix is prepended to the code to build the type URI of a problem
+const typePrefix = "urn:problem-type:"
+
+// Problem is a struct that represents an error response in the RFC 7807 format.
+// Code is a stable machine-readable identifier of the problem.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+
+	// Errors is an extension member with the details of each invalid field
+	Errors any `json:"errors,omitempty"`
+}
+
+// Mapping is a struct that describes how the errors that match Target are answered
+type Mapping struct {
+	// Target is compared with errors.Is
+	Target error
+	Status int
+	Code   string
+
+	// Extend is optional and adds the members specific to the error
+	Extend func(err error, problem *Problem)
+}
+
+// mu guards mappings, which are registered on startup and read on every error
+var (
+	mu       sync.RWMutex
+	mappings []Mapping
+)
+
+// Register is a function that adds mappings to the table used by FromError.
+// The first mapping that matches an error wins.
+func Register(newMappings ...Mapping) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	mappings = append(mappings, newMappings...)
+}
+
+// New is a function that returns a problem with the status and code
+func New(status int, code, detail string) Problem {
+	return Problem{
+		Type:   typePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// FromError is a function that returns the problem of the first mapping that
+// matches err. Errors without a mapping are internal errors and their message
+// is not exposed.
+func FromError(err error) Problem {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, mapping := range mappings {
+		if !errors.Is(err, mapping.Target) {
+			continue
+		}
+
+		problem := New(mapping.Status, mapping.Code, err.Error())
+		if mapping.Extend != nil {
+			mapping.Extend(err, &problem)
+		}
+		return problem
+	}
+
+	return New(http.StatusInternalServerError, CodeInternal, "Internal server error")
+}
+
+// Abort is a function that answers the request with the problem of err
+func Abort(ctx *gin.Context, err error) {
+	AbortWith(ctx, FromError(err))
+}
+
+// AbortWith is a function that answers the request with the problem
+func AbortWith(ctx *gin.Context, problem Problem) {
+	if problem.Instance == "" && ctx.Request != nil {
+		problem.Instance = ctx.Request.URL.Path
+	}
+
+	// gin keeps the Content-Type already set instead of the json one
+	ctx.Header("Content-Type", ContentType)
+
+	// The cache policy of the route is only for its successful responses
+	ctx.Writer.Header().Del("Cache-Control")
+	ctx.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// NoRoute is a handler that answers the requests without a route
+func NoRoute() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		AbortWith(ctx, New(http.StatusNotFound, CodeRouteNotFound, "Route not found"))
+	}
+}
+
+// NoMethod is a handler that answers the requests with a method the route doesn't have
+func NoMethod() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		AbortWith(ctx, New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed"))
+	}
+}
+
+// Recovery is a function that answers a panic with an internal error problem
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(ctx *gin.Context, recovered any) {
+		AbortWith(ctx, New(http.StatusInternalServerError, CodeInternal, "Internal server error"))
+	})
+}
@@ -0,0 +1,136 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// withMappings is a function that registers the mappings only for the test
+func withMappings(t *testing.T, newMappings ...Mapping) {
+	t.Helper()
+
+	mu.Lock()
+	previous := mappings
+	mappings = nil
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		mappings = previous
+		mu.Unlock()
+	})
+
+	Register(newMappings...)
+}
+
+func TestFromError(t *testing.T) {
+	errNotFound, errGone := errors.New("not found"), errors.New("gone")
+	withMappings(t,
+		Mapping{Target: errNotFound, Status: http.StatusNotFound, Code: "not_found"},
+		Mapping{
+			Target: errGone,
+			Status: http.StatusGone,
+			Code:   "gone",
+			Extend: func(err error, problem *Problem) { problem.Errors = []string{"extended"} },
+		},
+		// The first mapping that matches wins
+		Mapping{Target: errNotFound, Status: http.StatusBadRequest, Code: "shadowed"},
+	)
+
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{name: "mapped", err: errNotFound, status: http.StatusNotFound, code: "not_found", detail: "not found"},
+		{name: "wrapped", err: fmt.Errorf("product 1: %w", errNotFound), status: http.StatusNotFound, code: "not_found", detail: "product 1: not found"},
+		{name: "extended", err: errGone, status: http.StatusGone, code: "gone", detail: "gone"},
+		// The message of an unknown error can have internals, it's not exposed
+		{name: "unmapped", err: errors.New("dial tcp 10.0.0.1: refused"), status: http.StatusInternalServerError, code: CodeInternal, detail: "Internal server error"},
+	}
+	for _, c := range cases {
+		got := FromError(c.err)
+		if got.Status != c.status || got.Code != c.code || got.Detail != c.detail ||
+			got.Type != typePrefix+c.code || got.Title != http.StatusText(c.status) {
+			t.Errorf("%s: got %+v", c.name, got)
+		}
+	}
+
+	if got := FromError(errGone); fmt.Sprint(got.Errors) != "[extended]" {
+		t.Fatalf("extended: got errors %v", got.Errors)
+	}
+}
+
+// serve is a function that returns the response of a request to path of an
+// engine with the handler in /problem and the problem handlers of the package
+func serve(method, path string, handler gin.HandlerFunc) (*httptest.ResponseRecorder, Problem) {
+	// The stack of the panic is not printed
+	gin.SetMode(gin.TestMode)
+	gin.DefaultErrorWriter = io.Discard
+
+	engine := gin.New()
+	engine.HandleMethodNotAllowed = true
+	engine.Use(Recovery())
+	engine.NoRoute(NoRoute())
+	engine.NoMethod(NoMethod())
+	engine.GET("/problem", handler)
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+
+	var problem Problem
+	json.Unmarshal(recorder.Body.Bytes(), &problem)
+	return recorder, problem
+}
+
+func TestAbort(t *testing.T) {
+	errNotFound := errors.New("not found")
+	withMappings(t, Mapping{Target: errNotFound, Status: http.StatusNotFound, Code: "not_found"})
+
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		handler gin.HandlerFunc
+		status  int
+		code    string
+	}{
+		{
+			name:   "mapped error",
+			method: http.MethodGet,
+			path:   "/problem",
+			handler: func(ctx *gin.Context) {
+				// The cache policy of the route is not for the problem
+				ctx.Header("Cache-Control", "public, max-age=60")
+				ctx.Header("Content-Type", "text/plain")
+				Abort(ctx, errNotFound)
+			},
+			status: http.StatusNotFound,
+			code:   "not_found",
+		},
+		{name: "panic", method: http.MethodGet, path: "/problem", handler: func(ctx *gin.Context) { panic("boom") }, status: http.StatusInternalServerError, code: CodeInternal},
+		{name: "no route", method: http.MethodGet, path: "/missing", status: http.StatusNotFound, code: CodeRouteNotFound},
+		{name: "no method", method: http.MethodPost, path: "/problem", status: http.StatusMethodNotAllowed, code: CodeMethodNotAllowed},
+	}
+	for _, c := range cases {
+		if c.handler == nil {
+			c.handler = func(ctx *gin.Context) {}
+		}
+
+		recorder, problem := serve(c.method, c.path, c.handler)
+		if recorder.Code != c.status || problem.Status != c.status || problem.Code != c.code || problem.Instance != c.path {
+			t.Errorf("%s: got %d %+v", c.name, recorder.Code, problem)
+		}
+		if recorder.Header().Get("Content-Type") != ContentType || recorder.Header().Get("Cache-Control") != "" {
+			t.Errorf("%s: got headers %v", c.name, recorder.Header())
+		}
+	}
+}
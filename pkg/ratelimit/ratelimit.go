@@ -0,0 +1,163 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors that can be returned in the response
+var (
+	ErrLimitExceeded = errors.New("rate limit exceeded")
+	ErrInvalidLimit  = errors.New("invalid rate limit")
+)
+
+// Limit is a struct that contains the size of a token bucket: it holds Burst
+// requests and refills Requests every Period. Burst is Requests when it's 0.
+// The zero Limit is disabled.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// ParseLimit is a function that reads a limit written as requests/period,
+// like 100/1m, optionally followed by :burst, like 100/1m:150. An empty value
+// or off disables the limit.
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "off" {
+		return Limit{}, nil
+	}
+
+	value, burst, hasBurst := strings.Cut(value, ":")
+	requests, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q must be requests/period", ErrInvalidLimit, value)
+	}
+
+	var limit Limit
+	var err error
+	if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests < 1 {
+		return Limit{}, fmt.Errorf("%w: %q must be a positive number of requests", ErrInvalidLimit, requests)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q must be a positive duration", ErrInvalidLimit, period)
+	}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 1 {
+			return Limit{}, fmt.Errorf("%w: %q must be a positive burst", ErrInvalidLimit, burst)
+		}
+	}
+
+	return limit, nil
+}
+
+// Enabled is a function that returns if the limit restricts something
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// Capacity is a function that returns how many requests the bucket holds
+func (l Limit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// String is a function that returns the limit as ParseLimit reads it
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	if l.Burst > 0 {
+		return fmt.Sprintf("%d/%s:%d", l.Requests, l.Period, l.Burst)
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// rate is a function that returns how many requests the bucket refills per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result is a struct that contains the answer of the bucket to a request
+type Result struct {
+	Allowed bool
+
+	// Limit is the capacity of the bucket and Remaining the requests left in it
+	Limit     int
+	Remaining int
+
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+
+	// RetryAfter is how long until the next request is allowed, 0 if it's now
+	RetryAfter time.Duration
+}
+
+// State is a struct that contains the tokens left in a bucket when it was
+// last updated. A new bucket is the zero State, which is full.
+type State struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// Take is a function that refills the bucket up to now and takes a request
+// from it. It returns the new state, the stores only have to keep it.
+func Take(state State, limit Limit, now time.Time) (State, Result) {
+	capacity := float64(limit.Capacity())
+	rate := limit.rate()
+	tokens := refill(state, limit, now)
+
+	result := Result{Limit: limit.Capacity()}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = seconds((capacity - tokens) / rate)
+
+	return State{Tokens: tokens, Updated: now}, result
+}
+
+// Refund is a function that refills the bucket up to now and gives back a
+// request taken from it, for the requests that turned out not to count
+func Refund(state State, limit Limit, now time.Time) State {
+	if state.Updated.IsZero() {
+		return state
+	}
+	return State{Tokens: math.Min(float64(limit.Capacity()), refill(state, limit, now)+1), Updated: now}
+}
+
+// refill is a function that returns the tokens of the bucket at now
+func refill(state State, limit Limit, now time.Time) float64 {
+	capacity := float64(limit.Capacity())
+	if state.Updated.IsZero() {
+		return capacity
+	}
+
+	elapsed := now.Sub(state.Updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(capacity, state.Tokens+elapsed*limit.rate())
+}
+
+// Full is a function that returns if the bucket would be full at the time,
+// then forgetting it changes nothing
+func Full(state State, limit Limit, at time.Time) bool {
+	return state.Tokens+at.Sub(state.Updated).Seconds()*limit.rate() >= float64(limit.Capacity())
+}
+
+// seconds is a function that returns the duration of a number of seconds
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
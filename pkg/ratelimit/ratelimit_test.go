@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	valid := map[string]Limit{
+		"":          {},
+		"off":       {},
+		"100/1m":    {Requests: 100, Period: time.Minute},
+		"5/1s:20":   {Requests: 5, Period: time.Second, Burst: 20},
+		" 10/30s  ": {Requests: 10, Period: 30 * time.Second},
+	}
+	for value, want := range valid {
+		if limit, err := ParseLimit(value); err != nil || limit != want {
+			t.Errorf("parse %q: got %+v, %v, want %+v", value, limit, err, want)
+		}
+	}
+
+	for _, value := range []string{"100", "0/1m", "x/1m", "10/soon", "10/-1m", "10/1m:0", "10/1m:x"} {
+		if _, err := ParseLimit(value); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("parse %q: got %v, want ErrInvalidLimit", value, err)
+		}
+	}
+}
+
+func TestMemoryStoreRefills(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: time.Second}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, want := range []bool{true, true, false} {
+		if result, err := store.Take(ctx, "a", limit, now); err != nil || result.Allowed != want {
+			t.Fatalf("take %d: got %+v, %v", i, result, err)
+		}
+	}
+
+	// Half a second refills one request of the bucket, other keys have their own
+	result, err := store.Take(ctx, "a", limit, now.Add(time.Millisecond))
+	if err != nil || result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > 500*time.Millisecond {
+		t.Fatalf("take before the refill: got %+v, %v", result, err)
+	}
+	if result, err := store.Take(ctx, "a", limit, now.Add(500*time.Millisecond)); err != nil || !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("take after the refill: got %+v, %v", result, err)
+	}
+	if result, err := store.Take(ctx, "b", limit, now); err != nil || !result.Allowed || result.Remaining != 1 || result.Reset != 500*time.Millisecond {
+		t.Fatalf("take of another key: got %+v, %v", result, err)
+	}
+}
+
+func TestFull(t *testing.T) {
+	limit := Limit{Requests: 2, Period: time.Second}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// A bucket with one request taken is full again after half a second
+	state, _ := Take(State{}, limit, now)
+	if Full(state, limit, now) || Full(state, limit, now.Add(499*time.Millisecond)) {
+		t.Fatal("the bucket is full before the refill")
+	}
+	if !Full(state, limit, now.Add(500*time.Millisecond)) {
+		t.Fatal("the bucket is not full after the refill")
+	}
+
+	// The burst is the capacity to refill
+	burst := Limit{Requests: 2, Period: time.Second, Burst: 4}
+	state, _ = Take(State{}, burst, now)
+	if Full(state, burst, now.Add(499*time.Millisecond)) || !Full(state, burst, now.Add(500*time.Millisecond)) {
+		t.Fatal("the bucket with burst is not full after refilling its capacity")
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore().(*memoryStore)
+	limit := Limit{Requests: 1, Period: time.Hour}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// The idle client is full again after an hour, the busy one called later
+	if _, err := store.Take(ctx, "idle", limit, now); err != nil {
+		t.Fatalf("take idle: %v", err)
+	}
+	if _, err := store.Take(ctx, "busy", limit, now.Add(59*time.Minute)); err != nil {
+		t.Fatalf("take busy: %v", err)
+	}
+	if len(store.buckets) != 2 {
+		t.Fatalf("got %d buckets before the refill, want 2", len(store.buckets))
+	}
+
+	// The sweep forgets the full buckets only, and only once every sweepInterval
+	if _, err := store.Take(ctx, "other", limit, now.Add(time.Hour)); err != nil {
+		t.Fatalf("take other: %v", err)
+	}
+	if _, ok := store.buckets["idle"]; ok || len(store.buckets) != 2 {
+		t.Fatalf("got buckets %v after the sweep, want busy and other", store.buckets)
+	}
+
+	// A forgotten bucket is a full one, the client loses nothing
+	if result, err := store.Take(ctx, "idle", limit, now.Add(time.Hour)); err != nil || !result.Allowed {
+		t.Fatalf("take idle after the sweep: got %+v, %v", result, err)
+	}
+
+	store.Take(ctx, "busy", limit, now.Add(time.Hour+sweepInterval/2))
+	if _, ok := store.buckets["other"]; !ok {
+		t.Fatal("the buckets were swept before sweepInterval")
+	}
+}
+
+func TestMemoryStoreRefund(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: time.Minute}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// A request given back can be taken again
+	for i := 0; i < 2; i++ {
+		store.Take(ctx, "a", limit, now)
+	}
+	if err := store.Refund(ctx, "a", limit, now); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	if result, err := store.Take(ctx, "a", limit, now); err != nil || !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("take after the refund: got %+v, %v", result, err)
+	}
+
+	// The bucket never holds more than its capacity, and a missing one stays full
+	for i := 0; i < 3; i++ {
+		store.Refund(ctx, "a", limit, now)
+		store.Refund(ctx, "b", limit, now)
+	}
+	for _, key := range []string{"a", "b"} {
+		if result, err := store.Take(ctx, key, limit, now); err != nil || result.Remaining != 1 {
+			t.Fatalf("take %s after refunding too much: got %+v, %v", key, result, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store forgets the full buckets
+const sweepInterval = time.Minute
+
+// Store represents a contract with all the functions that need to be implemented.
+// Take must refill the bucket of the key and take a request from it at once,
+// and Refund give one back, so a store shared by several servers has to do
+// them atomically.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+	Refund(ctx context.Context, key string, limit Limit, now time.Time) error
+}
+
+// entry is a struct that contains a bucket of the memory store and its limit
+type entry struct {
+	state State
+	limit Limit
+}
+
+// memoryStore is a struct that contains the buckets of a single server by key
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]entry
+	lastSweep time.Time
+}
+
+// NewMemoryStore is a function that returns an empty store in memory
+func NewMemoryStore() Store {
+	return &memoryStore{buckets: map[string]entry{}}
+}
+
+// Take is a function that takes a request from the bucket of the key
+func (s *memoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	state, result := Take(s.buckets[key].state, limit, now)
+	s.buckets[key] = entry{state: state, limit: limit}
+
+	return result, nil
+}
+
+// Refund is a function that gives back a request to the bucket of the key
+func (s *memoryStore) Refund(ctx context.Context, key string, limit Limit, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if bucket, ok := s.buckets[key]; ok {
+		s.buckets[key] = entry{state: Refund(bucket.state, limit, now), limit: limit}
+	}
+	return nil
+}
+
+// sweep is a function that forgets the buckets that are full again, so the
+// clients that stopped calling don't take memory. It must be called with mu held.
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.buckets {
+		if Full(bucket.state, bucket.limit, now) {
+			delete(s.buckets, key)
+		}
+	}
+}
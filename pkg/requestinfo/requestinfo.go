@@ -0,0 +1,133 @@
+package requestinfo
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIPKey is the key of the client ip in a context
+type clientIPKey struct{}
+
+// WithClientIP is a function that returns a copy of ctx that carries the ip
+// of the client that makes the request
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIP is a function that returns the client ip carried by ctx, empty if there is none
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// SetClientIP is a function that stores the ip gin resolved for the request in
+// its context, so the services it calls can read it. The forwarded headers are
+// only honoured from the trusted proxies of the engine.
+func SetClientIP(ctx *gin.Context) {
+	ctx.Request = ctx.Request.WithContext(WithClientIP(ctx.Request.Context(), ctx.ClientIP()))
+}
+
+// requestIDKey is the key of the request id in a context
+type requestIDKey struct{}
+
+// WithRequestID is a function that returns a copy of ctx that carries the id of the request
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID is a function that returns the request id carried by ctx, empty if there is none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// SetRequestID is a function that stores the id of the request in its
+// context, so the services and repositories it calls can read it
+func SetRequestID(ctx *gin.Context, id string) {
+	ctx.Request = ctx.Request.WithContext(WithRequestID(ctx.Request.Context(), id))
+}
+
+// logHandler is a slog.Handler that adds the request id of the context to every record
+type logHandler struct {
+	slog.Handler
+
+	// root is the handler before the first group and steps are the groups and
+	// attributes added since. The request id is added to root and the steps
+	// are applied again over it, so the id is never nested in a group.
+	root  slog.Handler
+	steps []func(slog.Handler) slog.Handler
+}
+
+// NewLogHandler is a function that wraps the handler so the records logged
+// with a context that carries a request id have it as request_id
+func NewLogHandler(handler slog.Handler) slog.Handler {
+	return logHandler{Handler: handler}
+}
+
+// Handle is a function that adds the request id to the record, unless it
+// already has one, and passes it on
+func (h logHandler) Handle(ctx context.Context, record slog.Record) error {
+	id := RequestID(ctx)
+	if id == "" {
+		return h.Handler.Handle(ctx, record)
+	}
+
+	if len(h.steps) == 0 {
+		if !hasRequestID(record) {
+			record.AddAttrs(slog.String("request_id", id))
+		}
+		return h.Handler.Handle(ctx, record)
+	}
+
+	// The attributes of the record go in the open group, so the id goes before it
+	handler := h.root.WithAttrs([]slog.Attr{slog.String("request_id", id)})
+	for _, step := range h.steps {
+		handler = step(handler)
+	}
+	return handler.Handle(ctx, record)
+}
+
+// hasRequestID is a function that returns if the record has the request id
+func hasRequestID(record slog.Record) bool {
+	found := false
+	record.Attrs(func(attr slog.Attr) bool {
+		found = attr.Key == "request_id"
+		return !found
+	})
+	return found
+}
+
+// WithAttrs is a function that returns the handler with the attributes, still adding the request id
+func (h logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(h.steps) == 0 {
+		return logHandler{Handler: h.Handler.WithAttrs(attrs)}
+	}
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithAttrs(attrs)
+	})
+}
+
+// WithGroup is a function that returns the handler with the group, still
+// adding the request id outside of it
+func (h logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(func(handler slog.Handler) slog.Handler {
+		return handler.WithGroup(name)
+	})
+}
+
+// with is a function that returns the handler after the step, remembering it
+// to apply it again over the request id
+func (h logHandler) with(step func(slog.Handler) slog.Handler) logHandler {
+	root := h.root
+	if len(h.steps) == 0 {
+		root = h.Handler
+	}
+
+	steps := append(h.steps[:len(h.steps):len(h.steps)], step)
+	return logHandler{Handler: step(h.Handler), root: root, steps: steps}
+}
@@ -0,0 +1,81 @@
+package requestinfo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+// logLine is a function that logs a message with the logger and returns the line it wrote
+func logLine(t *testing.T, ctx context.Context, buffer *bytes.Buffer, logger *slog.Logger, args ...any) map[string]any {
+	t.Helper()
+
+	buffer.Reset()
+	logger.InfoContext(ctx, "message", args...)
+
+	var line map[string]any
+	if err := json.Unmarshal(buffer.Bytes(), &line); err != nil {
+		t.Fatalf("got line %q: %v", buffer.String(), err)
+	}
+	return line
+}
+
+func TestLogHandler(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buffer, nil)))
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	// Without a request id nothing is added
+	if line := logLine(t, context.Background(), &buffer, logger); line["request_id"] != nil {
+		t.Errorf("no request id: got %v", line)
+	}
+
+	if line := logLine(t, ctx, &buffer, logger, "key", "value"); line["request_id"] != "req-1" || line["key"] != "value" {
+		t.Errorf("request id: got %v", line)
+	}
+
+	// A request id logged explicitly is kept and not written twice
+	buffer.Reset()
+	logger.InfoContext(ctx, "message", "request_id", "explicit")
+	if line := buffer.String(); bytes.Count([]byte(line), []byte(`"request_id"`)) != 1 || !bytes.Contains([]byte(line), []byte(`"explicit"`)) {
+		t.Errorf("explicit request id: got %s", line)
+	}
+
+	if line := logLine(t, ctx, &buffer, logger.With("service", "products")); line["request_id"] != "req-1" || line["service"] != "products" {
+		t.Errorf("with attributes: got %v", line)
+	}
+}
+
+func TestLogHandlerWithGroup(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buffer, nil))).With("service", "products")
+	ctx := WithRequestID(context.Background(), "req-1")
+
+	// The request id stays at the top, the attributes go in the group
+	grouped := logger.WithGroup("http").With("method", "GET")
+	line := logLine(t, ctx, &buffer, grouped, "status", 200)
+	group, _ := line["http"].(map[string]any)
+	if line["request_id"] != "req-1" || line["service"] != "products" || group["method"] != "GET" || group["status"] != float64(200) || group["request_id"] != nil {
+		t.Fatalf("group: got %v", line)
+	}
+
+	// Nested groups too
+	line = logLine(t, ctx, &buffer, grouped.WithGroup("response"), "bytes", 10)
+	group, _ = line["http"].(map[string]any)
+	response, _ := group["response"].(map[string]any)
+	if line["request_id"] != "req-1" || group["method"] != "GET" || response["bytes"] != float64(10) {
+		t.Fatalf("nested group: got %v", line)
+	}
+
+	// An empty group is no group
+	if line := logLine(t, ctx, &buffer, logger.WithGroup(""), "key", "value"); line["request_id"] != "req-1" || line["key"] != "value" {
+		t.Fatalf("empty group: got %v", line)
+	}
+
+	// Without a request id the group is written as is
+	if line := logLine(t, context.Background(), &buffer, grouped); line["request_id"] != nil || line["http"] == nil {
+		t.Fatalf("group without request id: got %v", line)
+	}
+}
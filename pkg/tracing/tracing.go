@@ -0,0 +1,47 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// InstrumentationName is the name of the tracers of the api
+const InstrumentationName = "github.com/burgosfacundo/ApiGo.git"
+
+// Propagator reads and writes the W3C traceparent, tracestate and baggage headers
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
+
+// transport is a struct that contains the http.RoundTripper that sends the
+// requests after the trace of their context is written in their headers
+type transport struct {
+	base http.RoundTripper
+}
+
+// NewTransport is a function that returns a http.RoundTripper that continues
+// the trace of the context of each request in the server it calls. base sends
+// the requests, http.DefaultTransport when nil.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+// RoundTrip is a function that sends a copy of the request with the headers of its trace
+func (t *transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it receives
+	request = request.Clone(request.Context())
+	Propagator.Inject(request.Context(), propagation.HeaderCarrier(request.Header))
+
+	return t.base.RoundTrip(request)
+}
+
+// NewHTTPClient is a function that returns a client whose requests continue
+// the trace of their context, for the calls the api makes to other services
+func NewHTTPClient() *http.Client {
+	return &http.Client{Transport: NewTransport(nil)}
+}